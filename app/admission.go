@@ -0,0 +1,198 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// ownerHeader names the client the jobs and uploads belong to for the quotas. It's honored only from a trusted reverse
+// proxy, which authenticates the clients, see Configuration.TrustForwardedHeaders. The client's IP address is used
+// otherwise.
+const ownerHeader = "X-Owner"
+
+// admissionError rejects a submission. Clients can retry after Configuration.AdmissionRetryAfter.
+type admissionError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *admissionError) Error() string {
+	return e.Message
+}
+
+// errQueueFull is returned by AddJob when the queue has reached Configuration.MaxPendingJobs.
+var errQueueFull = &admissionError{StatusCode: http.StatusTooManyRequests, Message: "the queue is full, try again later"}
+
+// requestOwner returns the owner of the jobs and uploads submitted with r, see ownerHeader, or the client's address. The
+// owner and the forwarded client address are used only if the configuration trusts the forwarded headers, any client
+// could take another's quota or evade its own otherwise.
+func (app *Application) requestOwner(r *http.Request) string {
+	if app.config.TrustForwardedHeaders {
+		if owner := strings.TrimSpace(r.Header.Get(ownerHeader)); owner != "" {
+			return owner
+		}
+		if client := forwardedValue(r.Header.Get("X-Forwarded-For")); client != "" {
+			return client
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// admitJob checks whether the owner can submit a job with an event log of size bytes, or of an unknown size if it's
+// not positive.
+func (app *Application) admitJob(owner string, size int64) error {
+	if err := app.checkDiskSpace(size); err != nil {
+		return err
+	}
+	if err := app.checkStorageQuota(owner, size); err != nil {
+		return err
+	}
+	return app.checkPendingJobs(owner, 1)
+}
+
+// admitUpload checks whether the owner can start an upload session of size bytes.
+func (app *Application) admitUpload(owner string, size int64) error {
+	if err := app.checkDiskSpace(size); err != nil {
+		return err
+	}
+	if err := app.checkStorageQuota(owner, size); err != nil {
+		return err
+	}
+
+	if limit := app.config.MaxUploadsPerOwner; limit > 0 && len(app.uploads.ByOwner(owner)) >= limit {
+		return &admissionError{
+			StatusCode: http.StatusTooManyRequests,
+			Message:    fmt.Sprintf("too many open uploads, the limit is %d", limit),
+		}
+	}
+
+	return nil
+}
+
+// checkDiskSpace rejects size more bytes if the free space under the results directory would fall below
+// Configuration.MinFreeDiskSpace.
+func (app *Application) checkDiskSpace(size int64) error {
+	if app.config.MinFreeDiskSpace <= 0 {
+		return nil
+	}
+
+	free, err := diskFree(app.config.ResultsDir)
+	if err != nil {
+		return fmt.Errorf("cannot check free disk space: %s", err.Error())
+	}
+
+	if free-positive(size) < app.config.MinFreeDiskSpace {
+		return &admissionError{StatusCode: http.StatusInsufficientStorage, Message: "not enough disk space, try again later"}
+	}
+	return nil
+}
+
+// checkStorageQuota rejects size more bytes if the owner's storage would exceed Configuration.OwnerStorageQuota.
+func (app *Application) checkStorageQuota(owner string, size int64) error {
+	quota := app.config.OwnerStorageQuota
+	if quota <= 0 {
+		return nil
+	}
+
+	if app.ownerUsage(owner).StorageBytes+positive(size) > quota {
+		return &admissionError{
+			StatusCode: http.StatusInsufficientStorage,
+			Message:    fmt.Sprintf("storage quota of %d bytes is exceeded, delete jobs or wait until they expire", quota),
+		}
+	}
+	return nil
+}
+
+// checkPendingJobs rejects count more jobs if the owner would have more than Configuration.MaxPendingJobsPerOwner jobs
+// waiting.
+func (app *Application) checkPendingJobs(owner string, count int) error {
+	limit := app.config.MaxPendingJobsPerOwner
+	if limit <= 0 {
+		return nil
+	}
+
+	if app.ownerUsage(owner).PendingJobs+count > limit {
+		return &admissionError{
+			StatusCode: http.StatusTooManyRequests,
+			Message:    fmt.Sprintf("too many pending jobs, the limit is %d", limit),
+		}
+	}
+	return nil
+}
+
+// ownerUsage counts the owner's jobs and upload sessions and the bytes they take. Upload sessions take their whole
+// length, because the space is reserved when they're created.
+func (app *Application) ownerUsage(owner string) *model.Usage {
+	usage := &model.Usage{Owner: owner}
+
+	for _, job := range app.queue.Snapshot() {
+		if job.Owner != owner {
+			continue
+		}
+
+		usage.Jobs++
+		if job.Status == model.JobStatusPending {
+			usage.PendingJobs++
+		}
+		if job.Dir != "" {
+			usage.StorageBytes += dirSize(job.Dir)
+		}
+	}
+
+	for _, upload := range app.uploads.ByOwner(owner) {
+		usage.Uploads++
+		usage.StorageBytes += upload.Length
+	}
+
+	return usage
+}
+
+// replyAdmissionError replies to a rejected submission with the status code of an admissionError and the Retry-After
+// header. Other errors are replied with 500 and the message prefixed.
+func replyAdmissionError(app *Application, w http.ResponseWriter, err error, prefix string) {
+	rejection, ok := err.(*admissionError)
+	if !ok {
+		message := fmt.Sprintf("%s; %s", prefix, err)
+		reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+		return
+	}
+
+	retryAfter := int64(math.Ceil(app.config.AdmissionRetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	reply(w, rejection.StatusCode, model.ApiResponseError{Error: rejection.Message}, app.logger)
+}
+
+// dirSize returns the total size of the files in dir. Files which can't be read are skipped.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func positive(n int64) int64 {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestAdmission(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	// the owners are given by a trusted proxy
+	app.config.TrustForwardedHeaders = true
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, owner string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(eventLog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set(ownerHeader, owner)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = res.Body.Close() })
+		return res
+	}
+
+	submit := func(owner string, wantStatus int) {
+		t.Helper()
+
+		res := do("POST", "/jobs", owner, nil)
+		if res.StatusCode != wantStatus {
+			t.Fatalf("expected status code %d, got %d", wantStatus, res.StatusCode)
+		}
+
+		switch wantStatus {
+		case http.StatusCreated:
+			var response model.ApiSingleJobResponse
+			if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			job := app.queue.FindByID(response.ID)
+			if job.Owner != owner {
+				t.Fatalf("expected the job to belong to %s, got %q", owner, job.Owner)
+			}
+			t.Cleanup(func() {
+				if err := app.queue.Remove(job, true); err != nil {
+					t.Fatal(err)
+				}
+			})
+		case http.StatusTooManyRequests, http.StatusInsufficientStorage:
+			if res.Header.Get("Retry-After") != "60" {
+				t.Fatalf("expected Retry-After of 60 seconds, got %q", res.Header.Get("Retry-After"))
+			}
+		}
+	}
+
+	// pending jobs per owner
+
+	app.config.MaxPendingJobsPerOwner = 1
+	submit("alice", http.StatusCreated)
+	submit("alice", http.StatusTooManyRequests)
+	submit("bob", http.StatusCreated)
+	app.config.MaxPendingJobsPerOwner = 0
+
+	res := do("GET", "/usage", "alice", nil)
+	var usage model.Usage
+	if err = json.NewDecoder(res.Body).Decode(&usage); err != nil {
+		t.Fatal(err)
+	}
+	if usage.Owner != "alice" || usage.Jobs != 1 || usage.PendingJobs != 1 || usage.StorageBytes < int64(len(eventLog)) || usage.DiskFreeBytes <= 0 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// storage quota, checked before and after receiving the event log
+
+	app.config.OwnerStorageQuota = usage.StorageBytes + int64(len(eventLog))/2
+	submit("alice", http.StatusInsufficientStorage)
+
+	// a chunked body has no length up front
+	req, err := http.NewRequest("POST", ts.URL+"/jobs", io.MultiReader(bytes.NewReader(eventLog)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set(ownerHeader, "alice")
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusInsufficientStorage || len(app.queue.Jobs) != 2 {
+		t.Fatalf("expected status code %d and no new job, got %d and %d jobs", http.StatusInsufficientStorage, res.StatusCode, len(app.queue.Jobs))
+	}
+	app.config.OwnerStorageQuota = 0
+
+	// free disk space
+
+	app.config.MinFreeDiskSpace = 1 << 62
+	submit("carol", http.StatusInsufficientStorage)
+	res = do("POST", "/uploads", "carol", map[string]string{"Tus-Resumable": tusVersion, "Upload-Length": strconv.Itoa(len(eventLog))})
+	if res.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("expected status code %d, got %d", http.StatusInsufficientStorage, res.StatusCode)
+	}
+	app.config.MinFreeDiskSpace = 0
+
+	// event log size
+
+	app.config.UploadMaxSize = int64(len(eventLog)) - 1
+	submit("carol", http.StatusRequestEntityTooLarge)
+	app.config.UploadMaxSize = 0
+
+	// open uploads per owner
+
+	app.config.MaxUploadsPerOwner = 1
+	createUpload := func(wantStatus int) {
+		t.Helper()
+
+		res := do("POST", "/uploads", "dave", map[string]string{"Tus-Resumable": tusVersion, "Upload-Length": strconv.Itoa(len(eventLog))})
+		if res.StatusCode != wantStatus {
+			t.Fatalf("expected status code %d, got %d", wantStatus, res.StatusCode)
+		}
+		if res.StatusCode == http.StatusCreated {
+			var upload model.Upload
+			if err := json.NewDecoder(res.Body).Decode(&upload); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				if err := app.uploads.Remove(app.uploads.Get(upload.ID)); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+	createUpload(http.StatusCreated)
+	createUpload(http.StatusTooManyRequests)
+}
+
+func TestRequestOwner(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	tests := []struct {
+		name    string
+		trusted bool
+		headers map[string]string
+		want    string
+	}{
+		{name: "client address", want: "192.0.2.1"},
+		{name: "untrusted owner", headers: map[string]string{ownerHeader: "alice"}, want: "192.0.2.1"},
+		{name: "untrusted forwarded client", headers: map[string]string{"X-Forwarded-For": "198.51.100.7"}, want: "192.0.2.1"},
+		{name: "trusted owner", trusted: true, headers: map[string]string{ownerHeader: "alice", "X-Forwarded-For": "198.51.100.7"}, want: "alice"},
+		{name: "trusted forwarded client", trusted: true, headers: map[string]string{"X-Forwarded-For": "198.51.100.7"}, want: "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.config.TrustForwardedHeaders = tt.trusted
+
+			r := httptest.NewRequest("GET", "/usage", nil)
+			r.RemoteAddr = "192.0.2.1:41234"
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := app.requestOwner(r); got != tt.want {
+				t.Fatalf("expected the owner %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/gorilla/mux"
+)
+
+// analysisDefinition describes a kind of analysis: its parameters, the command which runs it on a job's event log,
+// the file the command writes the result to, and how the result is read and served.
+type analysisDefinition struct {
+	Kind model.AnalysisKind
+	// Params decodes and validates the parameters of a job, see model.DecodeAnalysisParams, and returns them with the
+	// defaults filled in, or nil if the analysis has no parameters.
+	Params func(params json.RawMessage) (interface{}, error)
+	// Executable and Args are templates executed with an analysisInput. Arguments which come out empty are left out.
+	Executable string
+	Args       []string
+	// OutputSuffix replaces the extension of the event log's name to name the output file.
+	OutputSuffix string
+	// Parse reads the output file of a job.
+	Parse func(app *Application, job *model.Job, filePath string) (interface{}, error)
+	// Endpoints serve the results.
+	Endpoints []analysisEndpoint
+
+	executable *template.Template
+	args       []*template.Template
+}
+
+// analysisEndpoint is a route which serves the results of an analysis, its pattern is relative to /jobs/{id}.
+type analysisEndpoint struct {
+	Name    string
+	Pattern string
+	Handler func(app *Application) http.HandlerFunc
+}
+
+// analysisInput is what the command templates of an analysis are executed with.
+type analysisInput struct {
+	// EventLog, JobDir and Output are the absolute paths of the event log, the job's directory and the output file.
+	EventLog string
+	JobDir   string
+	Output   string
+	// Params is the JSON of the job's parameters, "{}" if there are none.
+	Params string
+	// ColumnsFile is the absolute path of the JSON file with the column mapping's roles, the canonical ones if the job
+	// has no mapping, which is told by CustomColumns. The mapping is passed in a file rather than as an argument, so
+	// that its column names never end up in a command line.
+	ColumnsFile   string
+	CustomColumns bool
+	// Dev is set in the development mode.
+	Dev bool
+}
+
+// newAnalysisDefinitions returns the registry of the kinds of analysis.
+func newAnalysisDefinitions() map[model.AnalysisKind]*analysisDefinition {
+	analyses := map[model.AnalysisKind]*analysisDefinition{}
+	for _, analysis := range []*analysisDefinition{
+		waitingTimeAnalysis(),
+		calendarDiscoveryAnalysis(),
+		batchingDiscoveryAnalysis(),
+		prioritizationDiscoveryAnalysis(),
+	} {
+		analysis.executable = template.Must(template.New(string(analysis.Kind)).Parse(analysis.Executable))
+		for _, arg := range analysis.Args {
+			analysis.args = append(analysis.args, template.Must(template.New(string(analysis.Kind)).Parse(arg)))
+		}
+		analyses[analysis.Kind] = analysis
+	}
+	return analyses
+}
+
+// waitingTimeAnalysis is the waiting time analysis. Its results are served from the transitions report, see jobReport.
+func waitingTimeAnalysis() *analysisDefinition {
+	return &analysisDefinition{
+		Kind: model.AnalysisKindWaitingTime,
+		Params: func(params json.RawMessage) (interface{}, error) {
+			return nil, model.DecodeAnalysisParams(params, &noAnalysisParams{})
+		},
+		Executable:   "{{if .Dev}}run_analysis_dev{{else}}run_analysis{{end}}{{if .CustomColumns}}_columns{{end}}.bash",
+		Args:         []string{"{{.EventLog}}", "{{.JobDir}}", "{{if .CustomColumns}}{{.ColumnsFile}}{{end}}"},
+		OutputSuffix: "_transitions_report.csv",
+		Parse: func(app *Application, job *model.Job, filePath string) (interface{}, error) {
+			return app.jobResultsFromPath(filePath)
+		},
+		Endpoints: []analysisEndpoint{
+			{"GetJobCte", "/cte", GetJobCte},
+			{"GetJobCteTransitions", "/cte/transitions", GetJobCteTransitions},
+			{"GetJobOverview", "/overview", GetJobOverview},
+			{"GetJobWaitingTime", "/waiting-time/{cause}", GetJobWaitingTime},
+		},
+	}
+}
+
+// noAnalysisParams are the parameters of the analyses which have none, only an empty object is accepted.
+type noAnalysisParams struct{}
+
+func (p *noAnalysisParams) Validate() error {
+	return nil
+}
+
+// analysisRoutes returns the routes of the analyses' endpoints.
+func (app *Application) analysisRoutes() Routes {
+	var routes Routes
+	for _, kind := range app.analysisKinds() {
+		for _, endpoint := range app.analyses[model.AnalysisKind(kind)].Endpoints {
+			routes = append(routes, Route{endpoint.Name, "GET", "/jobs/{id}" + endpoint.Pattern, "", endpoint.Handler(app)})
+		}
+	}
+	return routes
+}
+
+// analysisKinds returns the registered kinds in the order of their names.
+func (app *Application) analysisKinds() []string {
+	var kinds []string
+	for kind := range app.analyses {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// resolveAnalysis returns the kind of analysis, the waiting time analysis if it's empty, and its validated parameters
+// with the defaults filled in.
+func (app *Application) resolveAnalysis(kind model.AnalysisKind, params json.RawMessage) (model.AnalysisKind, json.RawMessage, error) {
+	if kind == "" {
+		kind = model.AnalysisKindWaitingTime
+	}
+	analysis, ok := app.analyses[kind]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown kind %q, expected one of %s", kind, strings.Join(app.analysisKinds(), ", "))
+	}
+
+	v, err := analysis.Params(params)
+	if err != nil {
+		return "", nil, fmt.Errorf("params are invalid: %s", err.Error())
+	}
+	if v == nil {
+		return kind, nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", nil, err
+	}
+	return kind, b, nil
+}
+
+// outputName returns the name of the file the analysis of the event log writes its result to.
+func (a *analysisDefinition) outputName(eventLogName string) string {
+	return strings.TrimSuffix(eventLogName, path.Ext(eventLogName)) + a.OutputSuffix
+}
+
+// columnsFileName is the file in the job's directory the column mapping is passed to the analysis in.
+const columnsFileName = "analysis_columns.json"
+
+// command returns the executable and the arguments which run the analysis of the job's event log in dir, the job's
+// directory or the sandbox's one.
+func (a *analysisDefinition) command(job *model.Job, dir, eventLogName string, dev bool) (string, []string, error) {
+	jobDir, err := abspath(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := "{}"
+	if len(job.Params) > 0 {
+		params = string(job.Params)
+	}
+
+	data := analysisInput{
+		EventLog:      path.Join(jobDir, eventLogName),
+		JobDir:        jobDir,
+		Output:        path.Join(jobDir, a.outputName(eventLogName)),
+		Params:        params,
+		ColumnsFile:   path.Join(jobDir, columnsFileName),
+		CustomColumns: job.ColumnMapping != nil,
+		Dev:           dev,
+	}
+
+	var buf bytes.Buffer
+	if err = a.executable.Execute(&buf, data); err != nil {
+		return "", nil, fmt.Errorf("error building the command: %s", err.Error())
+	}
+	executable := buf.String()
+
+	var args []string
+	for _, arg := range a.args {
+		buf.Reset()
+		if err = arg.Execute(&buf, data); err != nil {
+			return "", nil, fmt.Errorf("error building the command: %s", err.Error())
+		}
+		if buf.Len() > 0 {
+			args = append(args, buf.String())
+		}
+	}
+
+	return executable, args, nil
+}
+
+// writeColumnsFile writes the roles of the job's column mapping, or of the canonical one if it has none, to the
+// columnsFileName in dir.
+func writeColumnsFile(job *model.Job, dir string) error {
+	columnMapping := job.ColumnMapping
+	if columnMapping == nil {
+		columnMapping = &canonicalColumnMapping
+	}
+	b, err := json.Marshal(columnMapping.Roles())
+	if err != nil {
+		return fmt.Errorf("error marshalling column mapping: %s", err.Error())
+	}
+	return os.WriteFile(path.Join(dir, columnsFileName), b, 0644)
+}
+
+// runAnalysis runs the analysis of the job's kind on the event log in the job's directory with the application's
+// runner. The scripts of the analyses are run with bash. With Configuration.AnalysisSandbox, the analysis runs in an
+// analysisSandbox and its output is collected into the job's directory when it succeeds. With
+// Configuration.RemoteWorkers, the analysis is handed out to a Worker, which uploads the output into the job's
+// directory.
+func (app *Application) runAnalysis(ctx context.Context, eventLogName string, job *model.Job) error {
+	analysis, ok := app.analyses[job.AnalysisKind()]
+	if !ok {
+		return fmt.Errorf("unknown kind %q", job.AnalysisKind())
+	}
+
+	if app.config.RemoteWorkers {
+		return app.leases.Run(ctx, job, eventLogName)
+	}
+
+	dir := job.Dir
+	var sandbox *analysisSandbox
+	if app.config.AnalysisSandbox {
+		var err error
+		if sandbox, err = newAnalysisSandbox(job.ID, job.Dir, eventLogName); err != nil {
+			return fmt.Errorf("error setting up the sandbox: %s", err.Error())
+		}
+		defer func() {
+			if err := sandbox.Close(); err != nil {
+				app.logger.Printf("error deleting the sandbox of job %s: %s", job.ID, err.Error())
+			}
+		}()
+		dir = sandbox.Dir
+	}
+
+	if err := writeColumnsFile(job, dir); err != nil {
+		return err
+	}
+	executable, args, err := analysis.command(job, dir, eventLogName, app.config.DevelopmentMode)
+	if err != nil {
+		return err
+	}
+	// the scripts are next to the application, the sandboxed analyses don't run from there
+	if executable, err = abspath(executable); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := &AnalysisCommand{
+		JobID:   job.ID,
+		Program: "bash",
+		Args:    append([]string{executable}, args...),
+		Limits:  app.analysisLimits(),
+		Stdout:  app.logger.Writer(),
+		Stderr:  io.MultiWriter(app.logger.Writer(), &stderr),
+	}
+	if sandbox != nil {
+		cmd.Dir = sandbox.Dir
+		cmd.Sandbox = &Sandbox{
+			User: app.config.AnalysisUser,
+			Env:  sandboxEnvironment(sandbox.Dir, app.config.AnalysisSandboxEnv),
+		}
+	}
+
+	app.logger.Printf("Job %s executing", job.ID)
+
+	if err = app.runner.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("error executing analysis: %s; stderr: %s", err.Error(), stderr.String())
+	}
+
+	if sandbox != nil {
+		if err = sandbox.collect(job.Dir, eventLogName, columnsFileName); err != nil {
+			return fmt.Errorf("error collecting the output of the sandbox: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// analysisLimits returns the resource limits of the analyses from the configuration.
+func (app *Application) analysisLimits() ResourceLimits {
+	return ResourceLimits{
+		Memory:   app.config.AnalysisMemoryLimit,
+		CPUTime:  app.config.AnalysisCPULimit,
+		WallTime: app.config.JobTimeout,
+	}
+}
+
+// jobResult reads the result of a completed job of the kind and returns it with the job which owns it, i.e., the
+// original of a duplicate.
+func (app *Application) jobResult(job *model.Job, kind model.AnalysisKind) (*model.Job, interface{}, error) {
+	if job.AnalysisKind() != kind {
+		return nil, nil, fmt.Errorf("%w, it's a %s job", errNoResults, job.AnalysisKind())
+	}
+	if job.Status != model.JobStatusCompleted && job.Status != model.JobStatusDuplicate {
+		return nil, nil, fmt.Errorf("%w, its status is %s", errNoResults, job.Status)
+	}
+
+	owner := job
+	if job.DuplicateOf != "" {
+		if owner = app.queue.FindByID(job.DuplicateOf); owner == nil {
+			return nil, nil, fmt.Errorf("%w, the original job %s has been deleted", errNoResults, job.DuplicateOf)
+		}
+	}
+
+	// the waiting time analysis links to its report, which has been there before the other analyses
+	resultFile := owner.ResultFile
+	if kind == model.AnalysisKindWaitingTime {
+		resultFile = owner.ReportCSV
+	}
+	if resultFile == nil || resultFile.URL == nil {
+		return nil, nil, fmt.Errorf("%w, it has no result file", errNoResults)
+	}
+
+	result, err := app.analyses[kind].Parse(app, job, path.Join(owner.Dir, path.Base(resultFile.URL.Path)))
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w, the result file has been deleted", errNoResults)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("error reading the result file: %s", err.Error())
+	}
+
+	return owner, result, nil
+}
+
+// jobResultHandler serves the result of the jobs of the kind, see jobResult.
+func jobResultHandler(app *Application, kind model.AnalysisKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		job := app.queue.FindByID(id)
+		if job == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("job with id %s not found", id)}, app.logger)
+			return
+		}
+
+		_, result, err := app.jobResult(job, kind)
+		if err != nil {
+			replyReportError(app, w, err)
+			return
+		}
+
+		reply(w, http.StatusOK, result, app.logger)
+	}
+}
+
+// readJSONFile decodes the JSON file at filePath into v.
+func readJSONFile(filePath string, v interface{}) error {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestResolveAnalysis(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	tests := []struct {
+		kind       model.AnalysisKind
+		params     string
+		wantKind   model.AnalysisKind
+		wantParams string
+		wantErr    bool
+	}{
+		{kind: "", wantKind: model.AnalysisKindWaitingTime},
+		{kind: model.AnalysisKindWaitingTime, params: `{}`, wantKind: model.AnalysisKindWaitingTime},
+		{kind: model.AnalysisKindWaitingTime, params: `{"granularity":60}`, wantErr: true},
+		{
+			kind:       model.AnalysisKindCalendarDiscovery,
+			wantKind:   model.AnalysisKindCalendarDiscovery,
+			wantParams: `{"granularity":60,"confidence":0.6,"support":0.2,"participation":0.4}`,
+		},
+		{
+			kind:       model.AnalysisKindBatchingDiscovery,
+			params:     `{"min_batch_size":3}`,
+			wantKind:   model.AnalysisKindBatchingDiscovery,
+			wantParams: `{"min_batch_size":3,"min_batch_instances":1,"min_rule_support":0.1}`,
+		},
+		{
+			kind:       model.AnalysisKindPrioritizationDiscovery,
+			params:     `{"attributes":["loan_amount"]}`,
+			wantKind:   model.AnalysisKindPrioritizationDiscovery,
+			wantParams: `{"attributes":["loan_amount"]}`,
+		},
+		{kind: model.AnalysisKindCalendarDiscovery, params: `{"granularity":7}`, wantErr: true},
+		{kind: model.AnalysisKindCalendarDiscovery, params: `{"granularity_minutes":60}`, wantErr: true},
+		{kind: model.AnalysisKindBatchingDiscovery, params: `{"min_batch_size":1}`, wantErr: true},
+		{kind: "simulation", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind)+tt.params, func(t *testing.T) {
+			kind, params, err := app.resolveAnalysis(tt.kind, json.RawMessage(tt.params))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got the params %s", params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != tt.wantKind || string(params) != tt.wantParams {
+				t.Fatalf("expected the kind %s with the params %s, got %s with %s", tt.wantKind, tt.wantParams, kind, params)
+			}
+		})
+	}
+}
+
+func TestAnalysisRunner_Command(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	dir := t.TempDir()
+	custom := canonicalColumnMapping
+	custom.Resource = "org:role"
+	columnsFile := path.Join(dir, columnsFileName)
+
+	tests := []struct {
+		name           string
+		job            *model.Job
+		dev            bool
+		wantExecutable string
+		wantArgs       []string
+	}{
+		{
+			name:           "waiting time",
+			job:            &model.Job{Dir: dir},
+			wantExecutable: "run_analysis.bash",
+			wantArgs:       []string{path.Join(dir, "log.csv"), dir},
+		},
+		{
+			name:           "waiting time with a column mapping in development mode",
+			job:            &model.Job{Dir: dir, ColumnMapping: &custom},
+			dev:            true,
+			wantExecutable: "run_analysis_dev_columns.bash",
+			wantArgs:       []string{path.Join(dir, "log.csv"), dir, columnsFile},
+		},
+		{
+			name:           "calendar discovery",
+			job:            &model.Job{Dir: dir, Kind: model.AnalysisKindCalendarDiscovery, Params: json.RawMessage(`{"granularity":30}`)},
+			wantExecutable: "run_calendar_discovery.bash",
+			wantArgs: []string{
+				path.Join(dir, "log.csv"),
+				path.Join(dir, "log_calendars.json"),
+				`{"granularity":30}`,
+				columnsFile,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executable, args, err := app.analyses[tt.job.AnalysisKind()].command(tt.job, tt.job.Dir, "log.csv", tt.dev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if executable != tt.wantExecutable || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("expected %s %q, got %s %q", tt.wantExecutable, tt.wantArgs, executable, args)
+			}
+		})
+	}
+}
+
+func TestParsePriorityLevels(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "log_prioritization.json")
+	levels := `[{"priority_level":1,"rules":[[{"attribute":"loan_amount","comparison":">","value":"1000"}]]}]`
+	if err := os.WriteFile(filePath, []byte(levels), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := &model.Job{Kind: model.AnalysisKindPrioritizationDiscovery, Params: json.RawMessage(`{"attributes":["loan_amount"]}`)}
+	v, err := parsePriorityLevels(nil, job, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := v.(*model.PrioritizationDiscoveryResult)
+	if len(result.Parameters.Attributes) != 1 || len(result.PriorityLevels) != 1 || result.PriorityLevels[0].PriorityLevel != 1 ||
+		result.PriorityLevels[0].Rules[0][0].Attribute != "loan_amount" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+// TestParseDiscoveryFixtures parses outputs of discovery.py kept in assets/tests to catch changes of their format.
+func TestParseDiscoveryFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		analysis *analysisDefinition
+		fixture  string
+		check    func(v interface{}) bool
+	}{
+		{
+			name:     "calendars",
+			analysis: calendarDiscoveryAnalysis(),
+			fixture:  "../assets/tests/manual_log_5_calendars.json",
+			check: func(v interface{}) bool {
+				result := v.(*model.CalendarDiscoveryResult)
+				return result.Parameters.Granularity == model.CalendarDiscoveryGranularity && len(result.Calendars) == 2 &&
+					len(result.Calendars[0].TimePeriods) == 3 && result.Calendars[0].TimePeriods[2].EndTime == "17:00:00"
+			},
+		},
+		{
+			name:     "batching",
+			analysis: batchingDiscoveryAnalysis(),
+			fixture:  "../assets/tests/manual_log_5_batching.json",
+			check: func(v interface{}) bool {
+				result := v.(*model.BatchingDiscoveryResult)
+				if len(result.Strategies) != 1 {
+					return false
+				}
+				strategy := result.Strategies[0]
+				return strategy.Type == "Sequential" && len(strategy.Resources) == 2 && strategy.SizeDistribution["3"] == 4 &&
+					len(strategy.FiringRules) == 2 && strategy.FiringRules[1][1].Value == "Friday"
+			},
+		},
+		{
+			name:     "prioritization",
+			analysis: prioritizationDiscoveryAnalysis(),
+			fixture:  "../assets/tests/manual_log_5_prioritization.json",
+			check: func(v interface{}) bool {
+				result := v.(*model.PrioritizationDiscoveryResult)
+				return len(result.PriorityLevels) == 2 && result.PriorityLevels[1].PriorityLevel == 2 &&
+					len(result.PriorityLevels[1].Rules) == 2 && result.PriorityLevels[1].Rules[0][0].Attribute == "type"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &model.Job{Kind: tt.analysis.Kind}
+			v, err := tt.analysis.Parse(nil, job, tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(v) {
+				b, _ := json.Marshal(v)
+				t.Fatalf("unexpected result %s", b)
+			}
+		})
+	}
+}
+
+func TestPostJob_InvalidAnalysis(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	bodies := []string{
+		`{"event_log":"http://example.com/log.csv","kind":"simulation"}`,
+		`{"event_log":"http://example.com/log.csv","kind":42}`,
+		`{"event_log":"http://example.com/log.csv","kind":"calendar_discovery","params":{"granularity":7}}`,
+		`{"event_log":"http://example.com/log.csv","kind":"calendar_discovery","params":{"confidence":1.5}}`,
+		`{"event_log":"http://example.com/log.csv","kind":"calendar_discovery","params":[60]}`,
+		`{"event_log":"http://example.com/log.csv","params":{"granularity":60}}`,
+	}
+
+	for _, body := range bodies {
+		res, err := http.Post(ts.URL+"/jobs", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status code %d for %s, got %d", http.StatusBadRequest, body, res.StatusCode)
+		}
+	}
+}
@@ -28,7 +28,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -37,22 +36,40 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Application struct {
-	router *mux.Router
-	queue  *Queue
-	config *Configuration
-	logger *log.Logger
-
-	analysisCancelFunc context.CancelFunc
+	router  *mux.Router
+	queue   *Queue
+	config  *Configuration
+	logger  *log.Logger
+	fetcher *Fetcher
+	uploads *UploadStore
+
+	auditLog *auditLog
+
+	// analyses are the kinds of analysis a job can run, their commands are run by runner
+	analyses map[model.AnalysisKind]*analysisDefinition
+	runner   AnalysisRunner
+	// leases hand the analyses out to the remote workers with Configuration.RemoteWorkers
+	leases *LeaseStore
+
+	// cancelFuncs stop the downloads and analyses of the running jobs by the job's ID
+	cancelFuncs     map[string]context.CancelFunc
+	cancelFuncsLock sync.Mutex
 }
 
 func NewApplication(config *Configuration) (*Application, error) {
 	app := &Application{
-		config: config,
-		queue:  NewQueue(),
+		config:      config,
+		queue:       NewQueue(),
+		cancelFuncs: map[string]context.CancelFunc{},
+		auditLog:    &auditLog{path: config.AuditLogPath},
+		analyses:    newAnalysisDefinitions(),
+		runner:      &ExecRunner{GracePeriod: config.AnalysisGracePeriod},
+		leases:      NewLeaseStore(config.WorkerLeaseDuration, config.WorkerMaxAttempts),
 	}
 
 	err := app.LoadQueue()
@@ -67,10 +84,30 @@ func NewApplication(config *Configuration) (*Application, error) {
 
 	app.logger = log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
+	app.fetcher, err = NewFetcher(config, app.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadsDir := config.UploadsDir
+	if uploadsDir == "" {
+		uploadsDir = path.Join(config.ResultsDir, "..", "uploads")
+	}
+	app.uploads, err = NewUploadStore(uploadsDir, app.logger)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := mkdir(config.ResultsDir); err != nil {
 		return nil, err
 	}
 
+	if config.AnalysisSandbox {
+		if err := restrictDataDirs(config.AssetsDir, config.ResultsDir, uploadsDir); err != nil {
+			return nil, err
+		}
+	}
+
 	return app, nil
 }
 
@@ -86,23 +123,67 @@ func (app *Application) GetRouter() *mux.Router {
 }
 
 func (app *Application) AddJob(job *model.Job) error {
-	return app.queue.Add(job)
+	return app.AddJobs(job)
+}
+
+// AddJobs adds the jobs to the queue at once, or none of them if they'd exceed the limits of pending jobs.
+func (app *Application) AddJobs(jobs ...*model.Job) error {
+	if err := app.checkQueueLimits(jobs); err != nil {
+		return err
+	}
+	return app.queue.Add(jobs...)
+}
+
+// AddBatch adds the batch with its jobs to the queue, or nothing if the jobs would exceed the limits of pending jobs.
+func (app *Application) AddBatch(batch *model.Batch, jobs ...*model.Job) error {
+	if err := app.checkQueueLimits(jobs); err != nil {
+		return err
+	}
+	return app.queue.AddBatch(batch, jobs...)
+}
+
+// checkQueueLimits rejects the jobs if the queue or their owners would have too many pending jobs with them.
+func (app *Application) checkQueueLimits(jobs []*model.Job) error {
+	if app.config.MaxPendingJobs > 0 && app.queue.CountPending()+len(jobs) > app.config.MaxPendingJobs {
+		return errQueueFull
+	}
+
+	counts := map[string]int{}
+	for _, job := range jobs {
+		counts[job.Owner]++
+	}
+	for owner, count := range counts {
+		if err := app.checkPendingJobs(owner, count); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // ProcessQueue should be started in a separate goroutine to run the queue processing alongside the web server.
-// It checks for any pending job and process one if available. It also saves the queue to disk when processing is done,
-// and clears old records periodically.
+// It starts Configuration.Workers workers, each of which checks for a pending job and processes one if available and
+// saves the queue to disk when processing is done. It also deletes expired jobs and uploads every
+// Configuration.RetentionSweepInterval.
 func (app *Application) ProcessQueue() {
-	app.logger.Printf("Queue processing started")
+	app.logger.Printf("Queue processing started with %d worker(s)", app.config.Workers)
 
-	for {
-		// empties queue and disk monthly
-		if err := app.queue.ClearOld(-24 * 31 * time.Hour); err != nil {
-			app.logger.Printf("Error clearing old jobs: %s", err.Error())
-		}
+	for i := 0; i < app.config.Workers; i++ {
+		go app.processQueueWorker()
+	}
+
+	app.sweep()
+
+	ticker := time.NewTicker(app.config.RetentionSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		app.sweep()
+	}
+}
 
-		// checks if there are jobs in the queue
-		job := app.queue.Next()
+func (app *Application) processQueueWorker() {
+	for {
+		// claims a pending job, so that no other worker takes it
+		job := app.queue.Claim()
 		if job == nil {
 			time.Sleep(app.config.QueueSleepTime)
 			continue
@@ -110,12 +191,40 @@ func (app *Application) ProcessQueue() {
 
 		// executes the job and saves the result on disk
 		app.processJob(job)
+		app.queue.Release(job)
 		if err := app.SaveQueue(); err != nil {
 			app.logger.Printf("error saving queue: %s", err.Error())
 		}
 	}
 }
 
+// registerCancelFunc lets cancelJob stop the job's download or analysis with cancel until the returned function is
+// called.
+func (app *Application) registerCancelFunc(id string, cancel context.CancelFunc) func() {
+	app.cancelFuncsLock.Lock()
+	app.cancelFuncs[id] = cancel
+	app.cancelFuncsLock.Unlock()
+
+	return func() {
+		app.cancelFuncsLock.Lock()
+		delete(app.cancelFuncs, id)
+		app.cancelFuncsLock.Unlock()
+	}
+}
+
+// cancelJob stops the download or the analysis of a running job. It returns false if the job isn't being downloaded or
+// analysed.
+func (app *Application) cancelJob(id string) bool {
+	app.cancelFuncsLock.Lock()
+	defer app.cancelFuncsLock.Unlock()
+
+	cancel, ok := app.cancelFuncs[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
 func (app *Application) SaveQueue() error {
 	app.queue.lock.Lock()
 	defer app.queue.lock.Unlock()
@@ -126,6 +235,12 @@ func (app *Application) LoadQueue() error {
 	app.queue.lock.Lock()
 	defer app.queue.lock.Unlock()
 	err := readGob(app.config.QueuePath, app.queue, app.logger)
+	if err != nil && !os.IsNotExist(err) {
+		// the queue may have been saved by a version with the column mappings as plain maps
+		if readLegacyQueue(app.config.QueuePath, app.queue, app.logger) == nil {
+			err = nil
+		}
+	}
 	if os.IsNotExist(err) {
 		err = nil
 	} else if err != nil {
@@ -146,6 +261,10 @@ func (app *Application) processJob(job *model.Job) {
 			app.logger.Printf("Error calling callback endpoint for job %s: %s", job.ID, err.Error())
 			job.SetError(err)
 		}
+
+		if job.BatchID != "" {
+			app.finishBatch(job.BatchID)
+		}
 	}()
 
 	// check for a pending job
@@ -156,49 +275,117 @@ func (app *Application) processJob(job *model.Job) {
 		return
 	}
 
+	// a job whose parent has failed fails as well, its own dependents fail in turn
+	if parent := app.queue.FailedDependency(job); parent != "" {
+		err := fmt.Errorf("dependency %s has failed or has been deleted", parent)
+		app.logger.Printf("Job %s failed; %s", job.ID, err.Error())
+		job.SetError(err)
+		job.SetStatus(model.JobStatusFailed)
+		return
+	}
+
 	// pre-work
-	var eventLogName = path.Base(job.EventLogURL.String())
+	var eventLogName = job.EventLogFileName()
 	{
 		app.logger.Printf("Job %s started", job.ID)
 		job.SetStatus(model.JobStatusRunning)
 
-		eventLogPath := path.Join(job.Dir, eventLogName)
+		// a job which takes its input from a parent analyses the parent's event log, which is prepared already, and if
+		// the job was created from a request body, then the even log file is already downloaded
+		if job.InputFrom != "" {
+			name, err := app.copyInputEventLog(job)
+			if err != nil {
+				app.logger.Printf("error copying input event log: %s", err.Error())
+				job.SetError(err)
+				job.SetStatus(model.JobStatusFailed)
+				return
+			}
+			eventLogName = name
+			job.SetEventLogName(name)
+		} else if !job.EventLogFromRequestBody && !job.EventLogDownloaded() {
+			// the event logs of the jobs submitted with POST /jobs are downloaded before they're queued, the ones of the
+			// batches' jobs only now. The download can be stopped like the analysis.
+			ctx, cancel := context.WithCancel(context.Background())
+			unregister := app.registerCancelFunc(job.ID, cancel)
+			err := app.downloadEventLog(ctx, job)
+			unregister()
+			interrupted := ctx.Err() != nil
+			cancel()
+			if interrupted {
+				app.logger.Printf("Job %s has been interrupted", job.ID)
+				job.SetError(fmt.Errorf("job has been interrupted"))
+				job.SetStatus(model.JobStatusFailed)
+				return
+			} else if validation, ok := err.(*eventLogValidation); ok {
+				app.logger.Printf("Job %s has an invalid event log", job.ID)
+				job.SetDiagnostics(validation.Diagnostics)
+				job.SetError(validation)
+				job.SetStatus(model.JobStatusFailed)
+				return
+			} else if err != nil {
+				app.logger.Printf("error preparing event log: %s", err.Error())
+				job.SetError(err)
+				job.SetStatus(model.JobStatusFailed)
+				return
+			}
 
-		// if the job was created from a request body, then the even log file is already downloaded
-		if !job.EventLogFromRequestBody {
-			// job's directory
-			if err := mkdir(job.Dir); err != nil {
-				app.logger.Printf("error creating job's directory: %s", err.Error())
+			// the size of a downloaded event log isn't known when the job is submitted, so the owner's storage quota
+			// is checked again now that it takes its space
+			if err = app.checkStorageQuota(job.Owner, 0); err != nil {
+				app.logger.Printf("Job %s is over its owner's storage quota", job.ID)
+				if err := os.RemoveAll(job.Dir); err != nil {
+					app.logger.Printf("error removing job's directory: %s", err.Error())
+				}
 				job.SetError(err)
+				job.SetStatus(model.JobStatusFailed)
 				return
 			}
+			eventLogName = job.EventLogFileName()
+		}
+
+		// timestamps are normalized to UTC before the preprocessing compares them with its time range
+		columnMapping, err := normalizeEventLogTimestamps(path.Join(job.Dir, eventLogName), job.ColumnMapping, job.Timezone)
+		if err != nil {
+			app.logger.Printf("error normalizing timestamps: %s", err.Error())
+			job.SetError(fmt.Errorf("error normalizing timestamps: %s", err.Error()))
+			job.SetStatus(model.JobStatusFailed)
+			return
+		}
+		job.SetColumnMapping(columnMapping)
 
-			// download log into job.Dir
-			if err := download(job.EventLogURL.String(), eventLogPath, app.logger); err != nil {
-				app.logger.Printf("error downloading event log: %s", err.Error())
+		if job.Preprocessing != nil {
+			summary, err := preprocessEventLog(job.Dir, eventLogName, job.ColumnMapping, job.Preprocessing)
+			if summary != nil {
+				job.SetPreprocessingSummary(summary)
+			}
+			if err != nil {
+				app.logger.Printf("error preprocessing event log: %s", err.Error())
 				job.SetError(err)
+				job.SetStatus(model.JobStatusFailed)
 				return
 			}
 		}
 
+		eventLogPath := path.Join(job.Dir, eventLogName)
+
 		// make MD5 hash of the log to check for uniqueness of the file
 		job.EventLogMD5, _ = md5sum(eventLogPath) // NOTE: we can ignore the error here
 
-		// if the log has been processed before, skip analysis and assign the result to the job
-		// foundJob := app.queue.FindByMD5(job.EventLogMD5)
-		// if foundJob != nil && foundJob.ID != job.ID &&
-		// 	foundJob.Status != model.JobStatusPending &&
-		// 	foundJob.Status != model.JobStatusFailed { // allow re-processing of failed jobs
-		// 	app.logger.Printf("Job %s skipped; log has been processed before", job.ID)
-		// 	job.SetStatus(model.JobStatusDuplicate)
-		// 	job.SetResult(foundJob.Result)
-		// 	job.SetReportCSV(foundJob.ReportCSV)
-		// 	job.SetCompletedAt(time.Now())
-		// 	if foundJob.Error != "" {
-		// 		job.SetError(errors.New(foundJob.Error))
-		// 	}
-		// 	return
-		// }
+		// the cache key covers the normalized and preprocessed log and the settings which change the result
+		cacheKey, err := analysisCacheKey(eventLogPath, job)
+		if err != nil {
+			app.logger.Printf("error computing cache key: %s", err.Error())
+		}
+		job.SetCacheKey(cacheKey)
+
+		// if the log has been analysed before with the same settings, skip analysis and link to the original's result
+		if !job.Force && cacheKey != "" {
+			if original := app.queue.FindCompletedByCacheKey(cacheKey, job.ID); original != nil {
+				app.logger.Printf("Job %s skipped; it's a duplicate of job %s", job.ID, original.ID)
+				job.SetDuplicateOf(original)
+				return
+			}
+		}
 	}
 
 	// work
@@ -206,22 +393,17 @@ func (app *Application) processJob(job *model.Job) {
 		ctx, cancel := context.WithTimeout(context.Background(), app.config.JobTimeout)
 		defer cancel()
 		// gives control over the running analysis process to the whole app
-		app.analysisCancelFunc = cancel
+		defer app.registerCancelFunc(job.ID, cancel)()
 
-		host := os.Getenv("WEBAPP_HOST")
-		if len(host) == 0 {
-			host = app.config.Host
-		}
-
-		jobErrorChan := make(chan error)
+		jobErrorChan := make(chan error, 1)
 		go func() {
 			jobErrorChan <- app.runAnalysis(ctx, eventLogName, job)
 		}()
 
-		const reportSuffixCSV = "_transitions_report.csv"
-
 		select {
 		case <-ctx.Done():
+			// the runner stops the analysis within its grace period, the job is finished once it has
+			<-jobErrorChan
 			app.logger.Printf("Job %s has been interrupted", job.ID)
 			job.SetError(fmt.Errorf("job has been interrupted"))
 			job.SetStatus(model.JobStatusFailed)
@@ -235,17 +417,23 @@ func (app *Application) processJob(job *model.Job) {
 				app.logger.Printf("Job %s completed", job.ID)
 				job.SetStatus(model.JobStatusCompleted)
 
+				// the analyses besides the waiting time one only link to their output
+				outputName := app.analyses[job.AnalysisKind()].outputName(eventLogName)
+				if job.AnalysisKind() != model.AnalysisKindWaitingTime {
+					job.SetResultFile(&model.URL{URL: app.links(nil).jobFile(job.ID, outputName)})
+					return
+				}
+
 				// assign report CSV
-				ext := path.Ext(eventLogName)
-				reportName := strings.TrimSuffix(eventLogName, ext) + reportSuffixCSV
-				reportURL, err := url.Parse(
-					fmt.Sprintf("http://%s/assets/results/%s/%s",
-						host, job.ID, reportName))
+				job.SetReportCSV(&model.URL{URL: app.links(nil).jobFile(job.ID, outputName)})
+
+				// the report has no processing times, they're needed for the CTE after the event log is deleted
+				processingTime, err := eventLogProcessingTime(path.Join(job.Dir, eventLogName), job.ColumnMapping)
 				if err != nil {
-					app.logger.Printf("error creating report URL: %s", err.Error())
-					job.SetError(err)
+					app.logger.Printf("error reading processing time: %s", err.Error())
+				} else {
+					job.SetProcessingTime(processingTime)
 				}
-				job.SetReportCSV(&model.URL{URL: reportURL})
 
 				// assign result
 				_, err = app.prepareJobResult(job)
@@ -260,6 +448,50 @@ func (app *Application) processJob(job *model.Job) {
 	}
 }
 
+// downloadEventLog downloads the job's event log from its URL into the job's directory, decompresses and normalizes
+// it like an uploaded one and validates it. The problems of an invalid event log are returned as an
+// *eventLogValidation. The directory is left for the caller to remove.
+func (app *Application) downloadEventLog(ctx context.Context, job *model.Job) error {
+	if err := mkdir(job.Dir); err != nil {
+		return fmt.Errorf("error creating job's directory: %s", err.Error())
+	}
+
+	eventLogName := sanitizeFileName(job.EventLogFileName())
+	download, err := app.fetcher.Fetch(ctx, job.EventLogURL.String(), path.Join(job.Dir, eventLogName), job.Download)
+	if download != nil {
+		job.SetDownload(download)
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading event log: %s", err.Error())
+	}
+
+	upload := &jobUpload{
+		EventLogName:   eventLogName,
+		ContentType:    download.ContentType,
+		ColumnMapping:  job.ColumnMapping,
+		EventLogFormat: job.EventLogFormat,
+		OCELObjectType: job.OCELObjectType,
+		AutoMap:        job.AutoMap,
+		Timezone:       job.Timezone,
+	}
+	if err = app.normalizeEventLog(job.Dir, upload); err != nil {
+		return err
+	}
+	job.SetEventLogName(upload.EventLogName)
+	job.SetEventLogFormat(upload.EventLogFormat)
+	job.SetColumnMapping(upload.ColumnMapping)
+
+	validation, err := validateEventLog(path.Join(job.Dir, upload.EventLogName), job.ColumnMapping)
+	if err != nil {
+		return fmt.Errorf("error validating event log: %s", err.Error())
+	}
+	if !validation.Valid() {
+		return validation
+	}
+
+	return nil
+}
+
 func (app *Application) callback(job *model.Job) error {
 	if job.CallbackEndpointURL == nil {
 		return nil
@@ -297,7 +529,7 @@ func (app *Application) prepareJobResult(job *model.Job) ([]model.JobResultItem,
 		reportSuffixCSV = "_transitions_report.csv"
 	)
 
-	eventLogName := path.Base(job.EventLogURL.String())
+	eventLogName := job.EventLogFileName()
 	eventLogExt := path.Ext(eventLogName)
 	resultName := strings.TrimSuffix(eventLogName, eventLogExt) + reportSuffixCSV
 	resultPath := path.Join(job.Dir, resultName)
@@ -334,11 +566,17 @@ func (app *Application) jobResultsFromPath(filePath string) ([]model.JobResultIt
 	}
 
 	var results []model.JobResultItem
-	timeLayout := "2006-01-02 15:04:05-07:00"
 
-	for _, record := range records[1:] {
-		startTime, _ := time.Parse(timeLayout, record[0])
-		endTime, _ := time.Parse(timeLayout, record[1])
+	for i, record := range records[1:] {
+		// the report's timestamps have offsets, so the parser's timezone doesn't matter
+		startTime, err := parseEventLogTimestamp(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: start time: %s", i+2, err.Error())
+		}
+		endTime, err := parseEventLogTimestamp(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: end time: %s", i+2, err.Error())
+		}
 		wtTotal, _ := strconv.ParseFloat(record[7], 64)
 		wtContention, _ := strconv.ParseFloat(record[8], 64)
 		wtBatching, _ := strconv.ParseFloat(record[9], 64)
@@ -374,7 +612,7 @@ func sanitizeTableName(input string) string {
 }
 
 func (app *Application) storeJobResultsInDatabase(jobID string, results []model.JobResultItem) error {
-	connStr := os.Getenv("DATABASE_URL")
+	connStr := app.config.DatabaseURL
 	if connStr == "" {
 		return errors.New("DATABASE_URL is not set")
 	}
@@ -434,21 +672,13 @@ func (app *Application) storeJobResultsInDatabase(jobID string, results []model.
 	return nil
 }
 
-func (app *Application) newJobFromRequestBody(body io.ReadCloser, columnMapping map[string]string) (*model.Job, error) {
+func (app *Application) newJobFromRequestBody(r *http.Request) (*model.Job, error) {
 	defer func() {
-		if err := body.Close(); err != nil {
+		if err := r.Body.Close(); err != nil {
 			app.logger.Printf("error closing request body: %s", err.Error())
 		}
 	}()
 
-	bodyBytes, err := io.ReadAll(body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading request body: %s", err.Error())
-	}
-	if len(bodyBytes) == 0 {
-		return nil, fmt.Errorf("request body is empty")
-	}
-
 	jobID, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
@@ -456,49 +686,104 @@ func (app *Application) newJobFromRequestBody(body io.ReadCloser, columnMapping
 
 	jobDir := strings.Join([]string{app.config.ResultsDir, jobID.String()}, "/")
 
-	logName := "event_log.csv"
-
-	logPath := path.Join(jobDir, logName)
-
 	if err := mkdir(jobDir); err != nil {
 		return nil, err
 	}
 
-	f, err := os.Create(logPath)
+	upload, err := app.receiveEventLog(r, jobDir)
 	if err != nil {
+		if err := os.RemoveAll(jobDir); err != nil {
+			app.logger.Printf("error removing job's directory: %s", err.Error())
+		}
 		return nil, err
 	}
 
-	defer func() {
-		if err := f.Close(); err != nil {
-			app.logger.Printf("error closing file: %s", err.Error())
-		}
-	}()
+	return app.newUploadedJob(jobID.String(), jobDir, upload)
+}
 
-	if _, err := io.Copy(f, bytes.NewReader(bodyBytes)); err != nil {
+// newJobFromUploadSession links the data of a complete upload session into a new job's directory and creates the job
+// with the session's settings, the session keeps its data until it's removed.
+func (app *Application) newJobFromUploadSession(session *model.Upload) (*model.Job, error) {
+	jobID, err := uuid.NewUUID()
+	if err != nil {
 		return nil, err
 	}
 
-	host := os.Getenv("WEBAPP_HOST")
-	if len(host) == 0 {
-		host = app.config.Host
+	jobDir := strings.Join([]string{app.config.ResultsDir, jobID.String()}, "/")
+
+	if err := mkdir(jobDir); err != nil {
+		return nil, err
 	}
 
-	eventLog := fmt.Sprintf("http://%s/assets/results/%s/%s", host, jobID, logName)
+	upload := &jobUpload{
+		EventLogName:     sanitizeFileName(session.FileName),
+		CallbackEndpoint: session.CallbackEndpoint,
+		ColumnMapping:    session.ColumnMapping,
+		EventLogFormat:   session.EventLogFormat,
+		OCELObjectType:   session.OCELObjectType,
+		AutoMap:          session.AutoMap,
+		Preprocessing:    session.Preprocessing,
+		Timezone:         session.Timezone,
+		Force:            session.Force,
+		RetainUntil:      session.RetainUntil,
+		Pinned:           session.Pinned,
+		Kind:             session.Kind,
+		Params:           session.Params,
+	}
 
-	eventLogURL, err := url.Parse(eventLog)
+	var job *model.Job
+	err = linkFile(app.uploads.DataPath(session), path.Join(jobDir, upload.EventLogName))
+	if err == nil {
+		err = app.normalizeEventLog(jobDir, upload)
+	}
+	if err == nil {
+		job, err = app.newUploadedJob(jobID.String(), jobDir, upload)
+	}
 	if err != nil {
+		if err := os.RemoveAll(jobDir); err != nil {
+			app.logger.Printf("error removing job's directory: %s", err.Error())
+		}
 		return nil, err
 	}
+	job.Owner = session.Owner
 
-	return &model.Job{
-		ID:                      jobID.String(),
+	return job, nil
+}
+
+// newUploadedJob creates a job for the event log which has been uploaded to the job's directory.
+func (app *Application) newUploadedJob(jobID, jobDir string, upload *jobUpload) (*model.Job, error) {
+	eventLogURL := app.links(nil).jobFile(jobID, upload.EventLogName)
+
+	job := &model.Job{
+		ID:                      jobID,
 		Status:                  model.JobStatusPending,
-		EventLog:                eventLog,
+		EventLog:                eventLogURL.String(),
 		EventLogURL:             &model.URL{URL: eventLogURL},
+		EventLogName:            upload.EventLogName,
+		EventLogFormat:          upload.EventLogFormat,
+		OCELObjectType:          upload.OCELObjectType,
+		AutoMap:                 upload.AutoMap,
+		Preprocessing:           upload.Preprocessing,
+		Timezone:                upload.Timezone,
+		Force:                   upload.Force,
+		RetainUntil:             upload.RetainUntil,
+		Pinned:                  upload.Pinned,
 		EventLogFromRequestBody: true,
 		CreatedAt:               time.Now(),
 		Dir:                     jobDir,
-		ColumnMapping:           columnMapping,
-	}, nil
+		ColumnMapping:           upload.ColumnMapping,
+		Kind:                    upload.Kind,
+		Params:                  upload.Params,
+	}
+
+	if upload.CallbackEndpoint != "" {
+		callbackURL, err := url.Parse(upload.CallbackEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid callback_endpoint: %s", err.Error())
+		}
+		job.CallbackEndpoint = upload.CallbackEndpoint
+		job.CallbackEndpointURL = &model.URL{URL: callbackURL}
+	}
+
+	return job, nil
 }
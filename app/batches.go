@@ -0,0 +1,232 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/google/uuid"
+)
+
+// summarizeBatch returns a copy of the batch with its status and progress, and its jobs which are still in the queue.
+func (app *Application) summarizeBatch(batch *model.Batch) (*model.Batch, []*model.Job) {
+	summary := batch.Copy()
+	summary.Progress = &model.BatchProgress{}
+
+	jobs := make([]*model.Job, 0, len(summary.JobIDs))
+	for _, id := range summary.JobIDs {
+		job := app.queue.FindByID(id)
+		summary.Progress.Add(job)
+		if job != nil {
+			jobs = append(jobs, job)
+		}
+	}
+	summary.Status = summary.Progress.Status()
+
+	return summary, jobs
+}
+
+// finishBatch sends the batch's callback once all its jobs have finished. The callback is sent only once, also if the
+// batch's last jobs finish at the same time.
+func (app *Application) finishBatch(id string) {
+	batch := app.queue.FindBatchByID(id)
+	if batch == nil {
+		return
+	}
+
+	summary, _ := app.summarizeBatch(batch)
+	if summary.Status == model.JobStatusPending || summary.Status == model.JobStatusRunning {
+		return
+	}
+	if !batch.Finish(time.Now()) {
+		return
+	}
+	app.logger.Printf("Batch %s finished with status %s", batch.ID, summary.Status)
+
+	if err := app.batchCallback(summary); err != nil {
+		app.logger.Printf("Error calling callback endpoint for batch %s: %s", batch.ID, err.Error())
+	}
+}
+
+// batchCallbackClient sends the batches' callbacks. They're sent by the worker which finishes a batch's last job, so
+// the timeout keeps a slow endpoint from holding up the worker.
+var batchCallbackClient = &http.Client{Timeout: 10 * time.Second}
+
+// batchCallback sends the batch's status and progress to its callback endpoint as an ApiBatchCallbackRequest, if it has
+// one. The endpoint's response is ignored.
+func (app *Application) batchCallback(batch *model.Batch) error {
+	if batch.CallbackEndpointURL == nil {
+		return nil
+	}
+
+	payload := model.ApiBatchCallbackRequest{
+		BatchID:  batch.ID,
+		Status:   string(batch.Status),
+		Progress: batch.Progress,
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", batch.CallbackEndpointURL.String(), bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	res, err := batchCallbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// sweepBatches finishes the batches whose jobs have finished without being processed, e.g., cancelled pending jobs,
+// and removes the finished batches whose jobs have all been deleted.
+func (app *Application) sweepBatches() {
+	changed := false
+	for _, batch := range app.queue.BatchSnapshot() {
+		app.finishBatch(batch.ID)
+
+		if summary, jobs := app.summarizeBatch(batch); summary.CompletedAt != nil && len(jobs) == 0 {
+			app.queue.RemoveBatch(batch)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := app.SaveQueue(); err != nil {
+			app.logger.Printf("error saving queue: %s", err.Error())
+		}
+	}
+}
+
+// newJobsFromArchive creates a job for every event log in the zip archive of the request body. The archive comes with
+// the settings of its jobs like an event log for POST /jobs, see receiveUpload, except that the callback endpoint is
+// the batch's, which is returned. The jobs' directories are removed if a job can't be created.
+func (app *Application) newJobsFromArchive(r *http.Request) ([]*model.Job, string, error) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			app.logger.Printf("error closing request body: %s", err.Error())
+		}
+	}()
+
+	if err := mkdir(app.config.ResultsDir); err != nil {
+		return nil, "", err
+	}
+	dir, err := os.MkdirTemp(app.config.ResultsDir, ".batch-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			app.logger.Printf("error removing batch's directory: %s", err.Error())
+		}
+	}()
+
+	upload, err := app.receiveUpload(r, dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	zr, err := zip.OpenReader(path.Join(dir, upload.EventLogName))
+	if err != nil {
+		return nil, "", fmt.Errorf("request body is not a zip archive of event logs: %s", err.Error())
+	}
+	defer zr.Close()
+
+	var jobs []*model.Job
+	for _, file := range zr.File {
+		base := path.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+
+		job, err := app.newJobFromArchiveFile(file, *upload)
+		if err != nil {
+			app.removeJobDirs(jobs)
+			return nil, "", fmt.Errorf("event log %s: %w", file.Name, err)
+		}
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
+		return nil, "", fmt.Errorf("zip archive contains no event logs")
+	}
+
+	return jobs, upload.CallbackEndpoint, nil
+}
+
+// newJobFromArchiveFile creates a job for an event log of an archive with a copy of the archive's settings. The event
+// log is validated like an uploaded one, the problems are returned as an *eventLogValidation.
+func (app *Application) newJobFromArchiveFile(file *zip.File, upload jobUpload) (*model.Job, error) {
+	jobID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	jobDir := path.Join(app.config.ResultsDir, jobID.String())
+	if err = mkdir(jobDir); err != nil {
+		return nil, err
+	}
+
+	upload.EventLogName = sanitizeFileName(path.Base(file.Name))
+	upload.ContentType = ""
+	upload.CallbackEndpoint = ""
+
+	job, err := func() (*model.Job, error) {
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = app.saveEventLog(rc, jobDir, upload.EventLogName)
+		if closeErr := rc.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err = app.normalizeEventLog(jobDir, &upload); err != nil {
+			return nil, err
+		}
+
+		job, err := app.newUploadedJob(jobID.String(), jobDir, &upload)
+		if err != nil {
+			return nil, err
+		}
+
+		validation, err := validateEventLog(path.Join(jobDir, job.EventLogFileName()), job.ColumnMapping)
+		if err != nil {
+			return nil, err
+		}
+		if !validation.Valid() {
+			return nil, validation
+		}
+		return job, nil
+	}()
+	if err != nil {
+		if err := os.RemoveAll(jobDir); err != nil {
+			app.logger.Printf("error removing job's directory: %s", err.Error())
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// removeJobDirs removes the directories of the jobs which haven't been queued.
+func (app *Application) removeJobDirs(jobs []*model.Job) {
+	for _, job := range jobs {
+		if err := os.RemoveAll(job.Dir); err != nil {
+			app.logger.Printf("error removing job's directory: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestBatches(t *testing.T) {
+	logs := httptest.NewServer(http.FileServer(http.Dir("../assets/samples")))
+	defer logs.Close()
+
+	var (
+		callbacksLock sync.Mutex
+		callbacks     []model.ApiBatchCallbackRequest
+	)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload model.ApiBatchCallbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		callbacksLock.Lock()
+		callbacks = append(callbacks, payload)
+		callbacksLock.Unlock()
+	}))
+	defer callbackServer.Close()
+
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+	app.config.DownloadAllowList = []string{"127.0.0.1"}
+	if app.fetcher, err = NewFetcher(app.config, app.logger); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	// the analysis fails for the event logs named "failing"
+	app.runner = &fakeRunner{run: func(_ context.Context, cmd *AnalysisCommand) error {
+		if strings.Contains(path.Base(cmd.Args[1]), "failing") {
+			return fmt.Errorf("exit status 1")
+		}
+		return os.WriteFile(cmd.Args[2], []byte(`[]`), 0644)
+	}}
+
+	post := func(contentType string, body *bytes.Buffer) (int, *model.ApiBatchResponse) {
+		res, err := http.Post(ts.URL+"/batches", contentType, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		var response model.ApiBatchResponse
+		if res.StatusCode == http.StatusCreated {
+			if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			for _, job := range response.Jobs {
+				job := app.queue.FindByID(job.ID)
+				t.Cleanup(func() {
+					if err := app.queue.Remove(job, true); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		}
+		return res.StatusCode, &response
+	}
+	get := func(id string) *model.ApiBatchResponse {
+		res, err := http.Get(ts.URL + "/batches/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+		}
+		var response model.ApiBatchResponse
+		if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return &response
+	}
+	process := func() {
+		for job := app.queue.Claim(); job != nil; job = app.queue.Claim() {
+			app.processJob(job)
+			app.queue.Release(job)
+		}
+	}
+	batchCallbacks := func(id string) []model.ApiBatchCallbackRequest {
+		callbacksLock.Lock()
+		defer callbacksLock.Unlock()
+
+		var found []model.ApiBatchCallbackRequest
+		for _, callback := range callbacks {
+			if callback.BatchID == id {
+				found = append(found, callback)
+			}
+		}
+		return found
+	}
+	zipArchive := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range files {
+			f, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = f.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("job specs", func(t *testing.T) {
+		body := bytes.NewBufferString(fmt.Sprintf(`{"callback_endpoint": "%s", "jobs": [
+			{"event_log": "%s/manual_log_5.csv", "kind": "calendar_discovery", "force": true},
+			{"event_log": "%s/manual_log_5.csv", "kind": "batching_discovery", "force": true}
+		]}`, callbackServer.URL, logs.URL, logs.URL))
+		statusCode, response := post("application/json", body)
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, statusCode)
+		}
+		if response.Status != model.JobStatusPending || response.Progress.Total != 2 || len(response.Jobs) != 2 ||
+			response.Jobs[0].BatchID != response.ID {
+			t.Fatalf("expected a pending batch of 2 jobs, got %+v", response)
+		}
+
+		process()
+
+		batch := get(response.ID)
+		if batch.Status != model.JobStatusCompleted || batch.Progress.Completed != 2 || batch.Progress.Finished != 1 ||
+			batch.CompletedAt == nil {
+			t.Fatalf("expected a completed batch, got %+v with progress %+v", batch.Batch, batch.Progress)
+		}
+		if found := batchCallbacks(response.ID); len(found) != 1 || found[0].Status != "completed" {
+			t.Fatalf("expected one callback of the completed batch, got %+v", found)
+		}
+	})
+
+	t.Run("zip archive", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("event_log", "logs.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write(zipArchive(map[string]string{
+			"logs/first.csv":   string(eventLog),
+			"logs/failing.csv": string(eventLog),
+			"__MACOSX/._a.csv": "",
+		}))
+		_ = mw.WriteField("kind", "calendar_discovery")
+		_ = mw.WriteField("force", "true")
+		_ = mw.WriteField("callback_endpoint", callbackServer.URL)
+		_ = mw.Close()
+
+		statusCode, response := post(mw.FormDataContentType(), &body)
+		if statusCode != http.StatusCreated || len(response.Jobs) != 2 {
+			t.Fatalf("expected status code %d and 2 jobs, got %d and %+v", http.StatusCreated, statusCode, response.Jobs)
+		}
+		for _, job := range response.Jobs {
+			if job.Kind != model.AnalysisKindCalendarDiscovery || job.CallbackEndpoint != "" {
+				t.Fatalf("expected the shared settings without the batch's callback, got %+v", job)
+			}
+		}
+
+		process()
+
+		batch := get(response.ID)
+		if batch.Status != model.JobStatusFailed || batch.Progress.Completed != 1 || batch.Progress.Failed != 1 {
+			t.Fatalf("expected a failed batch with a completed job, got %+v with progress %+v", batch.Batch, batch.Progress)
+		}
+		if found := batchCallbacks(response.ID); len(found) != 1 || found[0].Progress.Failed != 1 {
+			t.Fatalf("expected one callback of the failed batch, got %+v", found)
+		}
+
+		// the callback isn't sent again
+		app.sweepBatches()
+		if found := batchCallbacks(response.ID); len(found) != 1 {
+			t.Fatalf("expected one callback, got %d", len(found))
+		}
+	})
+
+	t.Run("invalid batches", func(t *testing.T) {
+		jobs := len(app.queue.Snapshot())
+
+		for _, body := range []string{
+			`{"jobs": []}`,
+			`{"jobs": [{"event_log": "http://example.com/log.csv", "kind": "simulation"}]}`,
+		} {
+			if statusCode, _ := post("application/json", bytes.NewBufferString(body)); statusCode != http.StatusBadRequest {
+				t.Fatalf("expected status code %d for %s, got %d", http.StatusBadRequest, body, statusCode)
+			}
+		}
+
+		invalid := zipArchive(map[string]string{"first.csv": string(eventLog), "invalid.csv": "case:concept:name\n1\n"})
+		if statusCode, _ := post("application/zip", bytes.NewBuffer(invalid)); statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status code %d, got %d", http.StatusUnprocessableEntity, statusCode)
+		}
+		if statusCode, _ := post("application/zip", bytes.NewBuffer(eventLog)); statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, statusCode)
+		}
+
+		if len(app.queue.Snapshot()) != jobs {
+			t.Fatal("expected no jobs of invalid batches to be queued")
+		}
+		entries, err := os.ReadDir(app.config.ResultsDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != jobs {
+			t.Fatalf("expected the directories of the rejected jobs to be removed, got %d for %d jobs", len(entries), jobs)
+		}
+	})
+
+	t.Run("storage quota of downloaded event logs", func(t *testing.T) {
+		// the batch is admitted, its event log is over the quota once it's downloaded
+		app.config.OwnerStorageQuota = app.ownerUsage("127.0.0.1").StorageBytes + 10
+		defer func() { app.config.OwnerStorageQuota = 0 }()
+
+		body := bytes.NewBufferString(fmt.Sprintf(`{"jobs": [
+			{"event_log": "%s/manual_log_5.csv", "kind": "calendar_discovery", "force": true}
+		]}`, logs.URL))
+		statusCode, response := post("application/json", body)
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, statusCode)
+		}
+
+		process()
+
+		job := app.queue.FindByID(response.Jobs[0].ID)
+		if job.Status != model.JobStatusFailed || !strings.Contains(job.Error, "storage quota") {
+			t.Fatalf("expected the job to fail over the storage quota, got status %s and error %q", job.Status, job.Error)
+		}
+		if _, err := os.Stat(job.Dir); !os.IsNotExist(err) {
+			t.Fatalf("expected the downloaded event log to be removed, got %v", err)
+		}
+	})
+
+	t.Run("unknown batch", func(t *testing.T) {
+		res, err := http.Get(ts.URL + "/batches/unknown")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected status code %d, got %d", http.StatusNotFound, res.StatusCode)
+		}
+	})
+}
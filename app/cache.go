@@ -0,0 +1,57 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// analysisOptions are the settings besides the event log which change the result of the analysis. They're part of the
+// cache key, so that the same log analysed differently isn't taken for a duplicate.
+type analysisOptions struct {
+	ColumnMapping map[string]string  `json:"column_mapping"`
+	Kind          model.AnalysisKind `json:"kind,omitempty"`
+	Params        json.RawMessage    `json:"params,omitempty"`
+}
+
+// analysisCacheKey returns the SHA-256 of the event log at eventLogPath followed by the JSON of the analysis options.
+// The log is expected to be normalized and preprocessed already, so that logs which differ only in the timestamp format
+// or in the events filtered out share the key. Jobs without a column mapping use the canonical one. The kind of
+// analysis and its parameters are included, so that a job is only taken for a duplicate of the same analysis.
+func analysisCacheKey(eventLogPath string, job *model.Job) (string, error) {
+	f, err := os.Open(eventLogPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	columnMapping := job.ColumnMapping
+	if columnMapping == nil {
+		columnMapping = &canonicalColumnMapping
+	}
+
+	// the keys of the waiting time analysis don't include the kind and the parameters, so that the existing keys stay
+	// valid
+	options := analysisOptions{ColumnMapping: columnMapping.Roles()}
+	if job.AnalysisKind() != model.AnalysisKindWaitingTime {
+		options.Kind = job.Kind
+		options.Params = job.Params
+	}
+	b, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(b)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestAnalysisCacheKey(t *testing.T) {
+	dir := t.TempDir()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := path.Join(dir, "log.csv")
+	if err = os.WriteFile(logPath, eventLog, 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherPath := path.Join(dir, "other.csv")
+	if err = os.WriteFile(otherPath, append(eventLog, "9,A,2022-05-16T10:00:00.000,2022-05-16T10:15:00.000,Marcus\n"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	canonical := canonicalColumnMapping
+	custom := canonicalColumnMapping
+	custom.Resource = "org:role"
+
+	key := func(filePath string, columnMapping *model.ColumnMapping) string {
+		k, err := analysisCacheKey(filePath, &model.Job{ColumnMapping: columnMapping})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	if key(logPath, nil) != key(logPath, &canonical) {
+		t.Fatal("expected no mapping and the canonical mapping to share the key")
+	}
+	if key(logPath, nil) == key(otherPath, nil) {
+		t.Fatal("expected different logs to have different keys")
+	}
+	if key(logPath, nil) == key(logPath, &custom) {
+		t.Fatal("expected different mappings to have different keys")
+	}
+
+	kindKey := func(kind model.AnalysisKind, params string) string {
+		k, err := analysisCacheKey(logPath, &model.Job{Kind: kind, Params: json.RawMessage(params)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	if kindKey(model.AnalysisKindWaitingTime, "") != key(logPath, nil) {
+		t.Fatal("expected the waiting time analysis to keep the key of the jobs without a kind")
+	}
+	if kindKey(model.AnalysisKindCalendarDiscovery, `{"granularity":60}`) == key(logPath, nil) {
+		t.Fatal("expected a calendar discovery and a waiting time analysis to have different keys")
+	}
+	if kindKey(model.AnalysisKindCalendarDiscovery, `{"granularity":60}`) == kindKey(model.AnalysisKindCalendarDiscovery, `{"granularity":30}`) {
+		t.Fatal("expected different params to have different keys")
+	}
+}
+
+func TestProcessJob_Duplicate(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(query string) *model.Job {
+		res, err := http.Post(ts.URL+"/jobs"+query, "text/csv", bytes.NewReader(eventLog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, res.StatusCode)
+		}
+
+		var response model.ApiSingleJobResponse
+		if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return app.queue.FindByID(response.ID)
+	}
+
+	// the original has been analysed before, its key is computed like processJob does
+	original := submit("")
+	defer func() {
+		if err := app.queue.Remove(original, true); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if _, err = normalizeEventLogTimestamps(path.Join(original.Dir, original.EventLogName), nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	original.CacheKey, err = analysisCacheKey(path.Join(original.Dir, original.EventLogName), original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportURL, _ := url.Parse("http://localhost/assets/results/" + original.ID + "/report.csv")
+	original.Status = model.JobStatusCompleted
+	original.ReportCSV = &model.URL{URL: reportURL}
+
+	duplicate := submit("")
+	defer func() {
+		if err := app.queue.Remove(duplicate, true); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	app.processJob(duplicate)
+
+	if duplicate.Status != model.JobStatusDuplicate || duplicate.DuplicateOf != original.ID {
+		t.Fatalf("expected a duplicate of %s, got status %s and duplicate_of %q", original.ID, duplicate.Status, duplicate.DuplicateOf)
+	}
+	if duplicate.ReportCSV != original.ReportCSV || duplicate.CompletedAt == nil || duplicate.CompletedAt.After(time.Now()) {
+		t.Fatalf("expected the duplicate to link to the original's report, got %+v", duplicate)
+	}
+
+	forced := submit("?force=true")
+	defer func() {
+		if err := app.queue.Remove(forced, true); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if !forced.Force {
+		t.Fatal("expected the force flag to be set")
+	}
+}
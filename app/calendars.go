@@ -0,0 +1,44 @@
+package app
+
+import (
+	"encoding/json"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// calendarDiscoveryAnalysis discovers the resources' calendars with the parameters of model.CalendarDiscovery.
+func calendarDiscoveryAnalysis() *analysisDefinition {
+	return &analysisDefinition{
+		Kind: model.AnalysisKindCalendarDiscovery,
+		Params: func(params json.RawMessage) (interface{}, error) {
+			var p model.CalendarDiscovery
+			if err := model.DecodeAnalysisParams(params, &p); err != nil {
+				return nil, err
+			}
+			return p.WithDefaults(), nil
+		},
+		Executable:   "run_calendar_discovery.bash",
+		Args:         []string{"{{.EventLog}}", "{{.Output}}", "{{.Params}}", "{{.ColumnsFile}}"},
+		OutputSuffix: "_calendars.json",
+		Parse:        parseCalendars,
+		Endpoints: []analysisEndpoint{
+			{"GetJobCalendars", "/calendars", GetJobCalendars},
+		},
+	}
+}
+
+// parseCalendars reads the calendars discovered by a job with the parameters they've been discovered with.
+func parseCalendars(_ *Application, job *model.Job, filePath string) (interface{}, error) {
+	result := &model.CalendarDiscoveryResult{Parameters: &model.CalendarDiscovery{}}
+	if err := readJSONFile(filePath, &result.Calendars); err != nil {
+		return nil, err
+	}
+	if result.Calendars == nil {
+		result.Calendars = []*model.ResourceCalendar{}
+	}
+	if err := model.DecodeAnalysisParams(job.Params, result.Parameters); err != nil {
+		return nil, err
+	}
+	result.Parameters = result.Parameters.WithDefaults()
+	return result, nil
+}
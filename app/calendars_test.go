@@ -0,0 +1,121 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestGetJobCalendars(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	calendars := `[{"id":"Anya_calendar","name":"Anya_calendar","time_periods":[` +
+		`{"from":"MONDAY","to":"FRIDAY","beginTime":"09:00:00","endTime":"17:00:00"}]}]`
+
+	job := &model.Job{
+		ID:         "calendars",
+		Status:     model.JobStatusCompleted,
+		CreatedAt:  time.Now(),
+		Dir:        t.TempDir(),
+		Kind:       model.AnalysisKindCalendarDiscovery,
+		Params:     json.RawMessage(`{"granularity":30}`),
+		ResultFile: &model.URL{URL: &url.URL{Path: "/assets/results/calendars/log_calendars.json"}},
+	}
+	if err = os.WriteFile(path.Join(job.Dir, "log_calendars.json"), []byte(calendars), 0644); err != nil {
+		t.Fatal(err)
+	}
+	duplicate := &model.Job{
+		ID:          "calendars-duplicate",
+		Status:      model.JobStatusDuplicate,
+		CreatedAt:   time.Now(),
+		DuplicateOf: job.ID,
+		Kind:        job.Kind,
+		Params:      job.Params,
+		ResultFile:  job.ResultFile,
+	}
+	pending := &model.Job{
+		ID:        "calendars-pending",
+		Status:    model.JobStatusPending,
+		CreatedAt: time.Now(),
+		Kind:      model.AnalysisKindCalendarDiscovery,
+	}
+	analysis := &model.Job{ID: "calendars-analysis", Status: model.JobStatusCompleted, CreatedAt: time.Now()}
+	jobs := []*model.Job{job, duplicate, pending, analysis}
+	for _, j := range jobs {
+		if err = app.queue.Add(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, j := range jobs {
+			if err := app.queue.Remove(j, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}()
+
+	get := func(id string, wantStatus int) *model.CalendarDiscoveryResult {
+		t.Helper()
+
+		res, err := http.Get(ts.URL + "/jobs/" + id + "/calendars")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != wantStatus {
+			t.Fatalf("expected status code %d for %s, got %d", wantStatus, id, res.StatusCode)
+		}
+		if wantStatus != http.StatusOK {
+			return nil
+		}
+
+		var result model.CalendarDiscoveryResult
+		if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+
+	for _, id := range []string{job.ID, duplicate.ID} {
+		result := get(id, http.StatusOK)
+		wantParameters := model.CalendarDiscovery{Granularity: 30, Confidence: 0.6, Support: 0.2, Participation: 0.4}
+		if *result.Parameters != wantParameters {
+			t.Fatalf("expected the parameters %+v, got %+v", wantParameters, *result.Parameters)
+		}
+		if len(result.Calendars) != 1 || result.Calendars[0].ID != "Anya_calendar" || len(result.Calendars[0].TimePeriods) != 1 ||
+			result.Calendars[0].TimePeriods[0].BeginTime != "09:00:00" {
+			t.Fatalf("unexpected calendars %+v", result.Calendars)
+		}
+	}
+
+	get(pending.ID, http.StatusConflict)
+
+	// the results of the other kinds of analysis aren't served
+	res, err := http.Get(ts.URL + "/jobs/" + job.ID + "/cte")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected status code %d for the CTE of a calendar discovery job, got %d", http.StatusConflict, res.StatusCode)
+	}
+
+	get(analysis.ID, http.StatusConflict)
+	get("missing", http.StatusNotFound)
+}
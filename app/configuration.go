@@ -1,29 +1,483 @@
 package app
 
-import "time"
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration of the application. It's loaded in layers by LoadConfiguration, every field can be set in the YAML
+// configuration file by its yaml key, in an environment variable named after the key with the envPrefix, e.g.,
+// WAITING_TIME_JOB_TIMEOUT, unless the env tag names another one, and with a flag named after the key with dashes,
+// e.g., -job-timeout. Durations are given like "4h30m", lists are comma-separated in environment variables and flags.
 type Configuration struct {
-	DevelopmentMode bool
-	Host            string
-	Port            uint
-	AssetsDir       string
-	ResultsDir      string
-	QueueSleepTime  time.Duration
-	JobTimeout      time.Duration
-	LogPath         string
-	QueuePath       string
+	DevelopmentMode bool   `yaml:"development_mode"`
+	Host            string `yaml:"host"`
+	Port            uint   `yaml:"port"`
+	// WebappHost is the host, with the port if needed, in the links without PublicBaseURL. Host is used if it's empty.
+	WebappHost string `yaml:"webapp_host" env:"WEBAPP_HOST"`
+	// PublicBaseURL is the scheme, host and path prefix of the links the API returns, e.g.,
+	// https://example.com/waiting-time. It's http:// with WebappHost if it's empty.
+	PublicBaseURL string `yaml:"public_base_url"`
+	// TrustForwardedHeaders makes the links follow the X-Forwarded-Proto and X-Forwarded-Host headers of the request,
+	// and the owners of the submissions the X-Owner and X-Forwarded-For headers. Enable it only behind a reverse proxy
+	// which sets them, replacing the ones sent by the clients.
+	TrustForwardedHeaders bool `yaml:"trust_forwarded_headers"`
+
+	AssetsDir      string        `yaml:"assets_dir"`
+	ResultsDir     string        `yaml:"results_dir"`
+	QueueSleepTime time.Duration `yaml:"queue_sleep_time"`
+	JobTimeout     time.Duration `yaml:"job_timeout"`
+	LogPath        string        `yaml:"log_path"`
+	QueuePath      string        `yaml:"queue_path"`
+
+	// DatabaseURL is the PostgreSQL connection string the results are stored with.
+	DatabaseURL string `yaml:"database_url" env:"DATABASE_URL"`
+	// Workers is the number of jobs analysed at the same time.
+	Workers int `yaml:"workers"`
+	// AnalysisMemoryLimit limits the virtual memory of an analysis process in bytes, AnalysisCPULimit its CPU time.
+	// Zero means no limit. They're not applied on Windows. The wall time of an analysis is limited by JobTimeout.
+	AnalysisMemoryLimit int64         `yaml:"analysis_memory_limit"`
+	AnalysisCPULimit    time.Duration `yaml:"analysis_cpu_limit"`
+	// AnalysisGracePeriod is how long a stopped analysis has to exit after it's been asked to terminate before it's
+	// killed.
+	AnalysisGracePeriod time.Duration `yaml:"analysis_grace_period"`
+	// AnalysisSandbox isolates the analyses from the application: they run as AnalysisUser in a directory of their own
+	// with a copy of the event log, with the environment reduced to PATH, the locale and AnalysisSandboxEnv, without
+	// core dumps and, on Linux, without network and in a PID namespace, so that no process outlives it. The assets,
+	// results and uploads directories are made accessible only to the application. It needs the application to run as
+	// root and isn't supported on Windows. A job fails instead of running unisolated if the sandbox can't be set up.
+	AnalysisSandbox bool `yaml:"analysis_sandbox"`
+	// AnalysisUser is the name or the ID of the unprivileged user the sandboxed analyses run as.
+	AnalysisUser string `yaml:"analysis_user"`
+	// AnalysisSandboxEnv names the environment variables passed on to the sandboxed analyses.
+	AnalysisSandboxEnv []string `yaml:"analysis_sandbox_env"`
+	// RemoteWorkers hands the analyses out to the workers started with the "worker" command instead of running them in
+	// the application, Workers limits how many are handed out at the same time. The workers authenticate with
+	// WorkerToken.
+	RemoteWorkers bool `yaml:"remote_workers"`
+	// WorkerToken is the secret the workers send as a bearer token in the Authorization header.
+	WorkerToken string `yaml:"worker_token"`
+	// WorkerLeaseDuration is how long a worker's lease on an analysis lasts without a heartbeat. The analysis of an
+	// expired lease is handed out again, up to WorkerMaxAttempts times.
+	WorkerLeaseDuration time.Duration `yaml:"worker_lease_duration"`
+	WorkerMaxAttempts   int           `yaml:"worker_max_attempts"`
+	// ServerURL is the URL of the application a worker leases the analyses from, e.g., http://api:8080.
+	ServerURL string `yaml:"server_url"`
+	// WorkerDir keeps the event logs and the outputs of a worker's analyses while they run.
+	WorkerDir string `yaml:"worker_dir"`
+	// WorkerPollInterval is how long a worker waits before asking for a lease again when there's no analysis to run.
+	WorkerPollInterval time.Duration `yaml:"worker_poll_interval"`
+	// JobRetention is how long finished jobs and their results are kept after they've been created unless the job has
+	// its own retain_until or is pinned. Zero keeps them forever.
+	JobRetention time.Duration `yaml:"job_retention"`
+	// EventLogRetention is how long the event logs of finished jobs are kept after the jobs have been created, the
+	// results stay. Zero keeps them as long as the job.
+	EventLogRetention time.Duration `yaml:"event_log_retention"`
+	// RetentionSweepInterval is how often expired jobs, event logs and upload sessions are deleted.
+	RetentionSweepInterval time.Duration `yaml:"retention_sweep_interval"`
+	// AuditLogPath is the JSON-lines file with a record of every deleted job and event log.
+	AuditLogPath string `yaml:"audit_log_path"`
+	// MaxPendingJobs limits the number of jobs waiting in the queue, new jobs are rejected above it. Zero means no limit.
+	MaxPendingJobs int `yaml:"max_pending_jobs"`
+
+	// MinFreeDiskSpace is the free space in bytes under ResultsDir which submissions can't use up, they're rejected
+	// with 507 instead. Zero disables the check.
+	MinFreeDiskSpace int64 `yaml:"min_free_disk_space"`
+	// OwnerStorageQuota limits the bytes of the jobs and upload sessions of an owner. Zero means no limit.
+	OwnerStorageQuota int64 `yaml:"owner_storage_quota"`
+	// MaxPendingJobsPerOwner limits the jobs of an owner waiting in the queue. Zero means no limit.
+	MaxPendingJobsPerOwner int `yaml:"max_pending_jobs_per_owner"`
+	// MaxUploadsPerOwner limits the open upload sessions of an owner. Zero means no limit.
+	MaxUploadsPerOwner int `yaml:"max_uploads_per_owner"`
+	// AdmissionRetryAfter is sent in the Retry-After header of rejected submissions.
+	AdmissionRetryAfter time.Duration `yaml:"admission_retry_after"`
+
+	// DownloadTimeout limits the whole download of an event log from a URL including retries.
+	DownloadTimeout time.Duration `yaml:"download_timeout"`
+	// DownloadMaxSize is the maximum size of a downloaded event log in bytes. Zero means no limit.
+	DownloadMaxSize int64 `yaml:"download_max_size"`
+	// DownloadAllowList contains host names, IP addresses and CIDR ranges that event logs can be downloaded from even
+	// though they are private or loopback addresses, e.g., services in the same Docker network.
+	DownloadAllowList []string `yaml:"download_allow_list"`
+
+	// UploadMaxSize is the maximum size of an event log uploaded in a request body in bytes after decompression. Zero
+	// means no limit.
+	UploadMaxSize int64 `yaml:"upload_max_size"`
+	// UploadsDir keeps resumable upload sessions until they are finalized into jobs.
+	UploadsDir string `yaml:"uploads_dir"`
+	// UploadSessionTTL is how long an upload session is kept without receiving any data.
+	UploadSessionTTL time.Duration `yaml:"upload_session_ttl"`
 }
 
+// envPrefix is prepended to the upper-cased yaml keys to get the environment variables of the configuration.
+const envPrefix = "WAITING_TIME_"
+
+// configFileEnv names the configuration file if the -config flag isn't given.
+const configFileEnv = envPrefix + "CONFIG"
+
 func DefaultConfiguration() *Configuration {
 	return &Configuration{
-		AssetsDir:       "assets",
-		QueueSleepTime:  time.Second * 60,
-		JobTimeout:      time.Hour * 4,
-		LogPath:         "assets/app.log",
-		QueuePath:       "assets/queue.gob",
-		ResultsDir:      "assets/results",
-		Host:            "localhost",
-		Port:            8080,
-		DevelopmentMode: false,
+		AssetsDir:              "assets",
+		QueueSleepTime:         time.Second * 5,
+		JobTimeout:             time.Hour * 4,
+		LogPath:                "assets/app.log",
+		QueuePath:              "assets/queue.gob",
+		ResultsDir:             "assets/results",
+		Host:                   "localhost",
+		Port:                   8080,
+		DevelopmentMode:        false,
+		Workers:                1,
+		AnalysisGracePeriod:    time.Second * 10,
+		AnalysisUser:           "nobody",
+		WorkerLeaseDuration:    time.Minute,
+		WorkerMaxAttempts:      3,
+		WorkerDir:              "assets/worker",
+		WorkerPollInterval:     time.Second * 5,
+		JobRetention:           time.Hour * 24 * 31,
+		RetentionSweepInterval: time.Hour,
+		AuditLogPath:           "assets/audit.log",
+		MinFreeDiskSpace:       1 << 30,
+		AdmissionRetryAfter:    time.Minute,
+		DownloadTimeout:        time.Minute * 30,
+		DownloadMaxSize:        1 << 30,
+		UploadMaxSize:          1 << 30,
+		UploadsDir:             "assets/uploads",
+		UploadSessionTTL:       time.Hour * 24,
+	}
+}
+
+// LoadConfiguration builds the configuration from the defaults, the YAML file given with the -config flag or in the
+// WAITING_TIME_CONFIG environment variable, the environment variables and the flags in args, each layer overriding the
+// previous ones, and validates it. lookupEnv is usually os.LookupEnv.
+func LoadConfiguration(args []string, lookupEnv func(string) (string, bool)) (*Configuration, error) {
+	config := DefaultConfiguration()
+	fields := config.fields()
+
+	flags := flag.NewFlagSet("waiting-time-backend", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	configPath, _ := lookupEnv(configFileEnv)
+	flags.StringVar(&configPath, "config", configPath, "Path to the YAML configuration file")
+
+	// flags are parsed first to find the configuration file, but applied last
+	var flagValues []configFlag
+	for _, field := range fields {
+		flags.Var(&configFlag{field: field, values: &flagValues}, field.flag, field.usage())
+	}
+	// flags kept from the earlier versions
+	for name, alias := range map[string]string{"dev": "development_mode", "sleep": "queue_sleep_time"} {
+		for _, field := range fields {
+			if field.key == alias {
+				flags.Var(&configFlag{field: field, values: &flagValues, seconds: name == "sleep"}, name, "Alias of -"+field.flag)
+			}
+		}
+	}
+
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		flags.SetOutput(os.Stderr)
+		flags.PrintDefaults()
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if configPath != "" {
+		if err := config.loadFile(configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range fields {
+		value, ok := lookupEnv(field.env)
+		if !ok {
+			continue
+		}
+		if err := field.set(value, false); err != nil {
+			return nil, fmt.Errorf("environment variable %s: %s", field.env, err.Error())
+		}
+	}
+
+	for _, v := range flagValues {
+		if err := v.field.set(v.value, v.seconds); err != nil {
+			return nil, fmt.Errorf("flag -%s: %s", v.field.flag, err.Error())
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadFile overrides the configuration with the YAML file at filePath. Unknown keys are rejected.
+func (c *Configuration) loadFile(filePath string) error {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading configuration file: %s", err.Error())
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid configuration file %s: %s", filePath, err.Error())
+	}
+
+	return nil
+}
+
+// Validate checks the configuration at startup and reports all the problems found.
+func (c *Configuration) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(c.Host != "", "host is required")
+	check(c.Port > 0 && c.Port <= 65535, "port must be between 1 and 65535")
+	check(c.AssetsDir != "", "assets_dir is required")
+	check(c.ResultsDir != "", "results_dir is required")
+	check(c.QueuePath != "", "queue_path is required")
+	check(c.QueueSleepTime > 0, "queue_sleep_time must be positive")
+	check(c.JobTimeout > 0, "job_timeout must be positive")
+	check(c.Workers > 0, "workers must be positive")
+	check(c.AnalysisMemoryLimit >= 0, "analysis_memory_limit must not be negative")
+	check(c.AnalysisCPULimit >= 0, "analysis_cpu_limit must not be negative")
+	check(c.AnalysisGracePeriod > 0, "analysis_grace_period must be positive")
+	if c.AnalysisSandbox {
+		check(runtime.GOOS != "windows", "analysis_sandbox isn't supported on Windows")
+		check(c.AnalysisUser != "", "analysis_user is required with analysis_sandbox")
+	}
+	check(!c.RemoteWorkers || c.WorkerToken != "", "worker_token is required with remote_workers")
+	check(c.WorkerLeaseDuration > 0, "worker_lease_duration must be positive")
+	check(c.WorkerMaxAttempts > 0, "worker_max_attempts must be positive")
+	check(c.WorkerPollInterval > 0, "worker_poll_interval must be positive")
+	check(c.JobRetention >= 0, "job_retention must not be negative")
+	check(c.EventLogRetention >= 0, "event_log_retention must not be negative")
+	check(c.RetentionSweepInterval > 0, "retention_sweep_interval must be positive")
+	check(c.MaxPendingJobs >= 0, "max_pending_jobs must not be negative")
+	check(c.MinFreeDiskSpace >= 0, "min_free_disk_space must not be negative")
+	check(c.OwnerStorageQuota >= 0, "owner_storage_quota must not be negative")
+	check(c.MaxPendingJobsPerOwner >= 0, "max_pending_jobs_per_owner must not be negative")
+	check(c.MaxUploadsPerOwner >= 0, "max_uploads_per_owner must not be negative")
+	check(c.AdmissionRetryAfter > 0, "admission_retry_after must be positive")
+	check(c.DownloadTimeout > 0, "download_timeout must be positive")
+	check(c.DownloadMaxSize >= 0, "download_max_size must not be negative")
+	check(c.UploadMaxSize >= 0, "upload_max_size must not be negative")
+	check(c.UploadSessionTTL > 0, "upload_session_ttl must be positive")
+
+	for _, entry := range c.DownloadAllowList {
+		check(strings.TrimSpace(entry) != "", "download_allow_list contains an empty entry")
 	}
+
+	if c.DatabaseURL != "" && strings.Contains(c.DatabaseURL, "://") {
+		_, err := url.Parse(c.DatabaseURL)
+		check(err == nil, "database_url is not a valid URL")
+	}
+
+	if c.PublicBaseURL != "" {
+		u, err := url.Parse(c.PublicBaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"public_base_url must be an absolute http or https URL")
+		check(err == nil && u.RawQuery == "" && u.Fragment == "", "public_base_url must not have a query or a fragment")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// BaseURL returns the URL the links the API returns are resolved against, see PublicBaseURL.
+func (c *Configuration) BaseURL() *url.URL {
+	if c.PublicBaseURL != "" {
+		if u, err := url.Parse(c.PublicBaseURL); err == nil {
+			return u
+		}
+	}
+
+	host := c.WebappHost
+	if host == "" {
+		host = c.Host
+	}
+	return &url.URL{Scheme: "http", Host: host}
+}
+
+// YAML returns the configuration as a YAML document with the keys in the order of the fields. The password in the
+// database URL and the worker token are redacted.
+func (c *Configuration) YAML() ([]byte, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, field := range c.fields() {
+		value := &yaml.Node{Kind: yaml.ScalarNode}
+
+		switch v := field.value.Interface().(type) {
+		case []string:
+			// empty lists are printed as null, so that they load back as nil
+			if len(v) == 0 {
+				value.Tag, value.Value = "!!null", "null"
+				break
+			}
+			value = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, s := range v {
+				value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s})
+			}
+		case string:
+			value.Value = v
+			if field.key == "database_url" {
+				value.Value = redactURL(v)
+			} else if field.key == "worker_token" && v != "" {
+				value.Value = "xxxxx"
+			}
+			value.Style = yaml.DoubleQuotedStyle
+		default:
+			value.Value = fmt.Sprint(v)
+		}
+
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field.key}, value)
+	}
+
+	return yaml.Marshal(doc)
+}
+
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// configField is a field of the configuration with its names in the file, the environment and the flags.
+type configField struct {
+	key   string
+	env   string
+	flag  string
+	value reflect.Value
+}
+
+func (c *Configuration) fields() []configField {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("yaml")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		env := t.Field(i).Tag.Get("env")
+		if env == "" {
+			env = envPrefix + strings.ToUpper(key)
+		}
+
+		fields = append(fields, configField{
+			key:   key,
+			env:   env,
+			flag:  strings.ReplaceAll(key, "_", "-"),
+			value: v.Field(i),
+		})
+	}
+
+	return fields
+}
+
+func (f configField) usage() string {
+	return fmt.Sprintf("Sets %s, also set by %s", f.key, f.env)
+}
+
+// set parses s into the field. With seconds set, a duration given as a plain number is taken in seconds.
+func (f configField) set(s string, seconds bool) error {
+	s = strings.TrimSpace(s)
+
+	switch f.value.Interface().(type) {
+	case time.Duration:
+		if seconds {
+			if n, err := strconv.Atoi(s); err == nil {
+				f.value.SetInt(int64(time.Duration(n) * time.Second))
+				return nil
+			}
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case []string:
+		var values []string
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		f.value.Set(reflect.ValueOf(values))
+	case string:
+		f.value.SetString(s)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case uint:
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return err
+		}
+		f.value.SetUint(n)
+	case int, int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+// configFlag records the flags given, so that they're applied after the file and the environment.
+type configFlag struct {
+	field   configField
+	values  *[]configFlag
+	value   string
+	seconds bool
+}
+
+func (f *configFlag) String() string {
+	return ""
+}
+
+func (f *configFlag) Set(s string) error {
+	// the value is checked right away on a copy, so that a wrong flag is reported by the flag package
+	check := reflect.New(f.field.value.Type()).Elem()
+	if err := (configField{value: check}).set(s, f.seconds); err != nil {
+		return err
+	}
+
+	*f.values = append(*f.values, configFlag{field: f.field, value: s, seconds: f.seconds})
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.field.value.Kind() == reflect.Bool
 }
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfiguration(t *testing.T) {
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	content := "job_timeout: 2h\n" +
+		"results_dir: /srv/results\n" +
+		"workers: 2\n" +
+		"download_allow_list: [db, 10.0.0.0/8]\n" +
+		"port: 9000\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		configFileEnv:                     configPath,
+		"WAITING_TIME_WORKERS":            "3",
+		"WAITING_TIME_UPLOAD_SESSION_TTL": "30m",
+		"DATABASE_URL":                    "postgres://postgres:secret@db:5432/postgres",
+		"WEBAPP_HOST":                     "example.com",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	config, err := LoadConfiguration([]string{"-port", "9100", "-sleep", "10", "-dev", "-max-pending-jobs=50"}, lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DefaultConfiguration()
+	want.JobTimeout = 2 * time.Hour                       // file
+	want.ResultsDir = "/srv/results"                      // file
+	want.DownloadAllowList = []string{"db", "10.0.0.0/8"} // file
+	want.Workers = 3                                      // environment over file
+	want.UploadSessionTTL = 30 * time.Minute              // environment
+	want.DatabaseURL = env["DATABASE_URL"]                // legacy environment variable
+	want.WebappHost = "example.com"                       // legacy environment variable
+	want.Port = 9100                                      // flag over file
+	want.QueueSleepTime = 10 * time.Second                // legacy flag in seconds
+	want.DevelopmentMode = true                           // legacy flag
+	want.MaxPendingJobs = 50                              // flag
+
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("unexpected configuration\n got: %+v\nwant: %+v", config, want)
+	}
+
+	// the printed configuration loads back to the same one, except for the redacted password
+	b, err := config.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Fatalf("expected the database password to be redacted:\n%s", b)
+	}
+	if err = os.WriteFile(configPath, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	printed, err := LoadConfiguration([]string{"-config", configPath}, func(string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed.DatabaseURL = config.DatabaseURL
+	if !reflect.DeepEqual(printed, config) {
+		t.Fatalf("unexpected configuration from the printed one\n got: %+v\nwant: %+v", printed, config)
+	}
+}
+
+func TestLoadConfiguration_Invalid(t *testing.T) {
+	noEnv := func(string) (string, bool) { return "", false }
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("job_timeot: 2h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		lookupEnv func(string) (string, bool)
+		wantErr   string
+	}{
+		{name: "unknown file key", args: []string{"-config", configPath}, wantErr: "job_timeot"},
+		{name: "invalid flag value", args: []string{"-job-timeout", "forever"}, wantErr: "job-timeout"},
+		{name: "invalid environment variable", lookupEnv: func(key string) (string, bool) {
+			if key == "WAITING_TIME_WORKERS" {
+				return "many", true
+			}
+			return "", false
+		}, wantErr: "WAITING_TIME_WORKERS"},
+		{name: "relative public base URL", args: []string{"-public-base-url", "/waiting-time"}, wantErr: "public_base_url"},
+		{name: "sandbox without a user", args: []string{"-analysis-sandbox", "-analysis-user", ""}, wantErr: "analysis_user is required"},
+		{name: "validation", args: []string{"-workers", "0", "-port", "70000"}, wantErr: "port must be between 1 and 65535; workers must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupEnv := tt.lookupEnv
+			if lookupEnv == nil {
+				lookupEnv = noEnv
+			}
+
+			_, err := LoadConfiguration(tt.args, lookupEnv)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// errNoResults is returned for the jobs which haven't been analysed successfully.
+var errNoResults = errors.New("job has no results")
+
+// waitingTimes sums the waiting times of a set of transition instances by cause.
+type waitingTimes struct {
+	total, batching, prioritization, contention, unavailability, extraneous float64
+}
+
+func (wt *waitingTimes) add(item *model.JobResultItem) {
+	wt.total += item.WtTotal
+	wt.batching += item.WtBatching
+	wt.prioritization += item.WtPrioritization
+	wt.contention += item.WtContention
+	wt.unavailability += item.WtUnavailability
+	wt.extraneous += item.WtExtraneous
+}
+
+// cte returns the cycle time efficiency of the processing time pt and the waiting time wt. It's 0 rather than NaN if
+// both are 0.
+func cte(pt, wt float64) float64 {
+	if pt+wt <= 0 {
+		return 0
+	}
+	return pt / (pt + wt)
+}
+
+// potentialCTE returns the process CTE for each cause if the waiting times of the cause in eliminated, which are those
+// of the whole process or of some of its transitions, were eliminated.
+func potentialCTE(pt float64, process, eliminated waitingTimes) *model.JobCteImpact {
+	return &model.JobCteImpact{
+		BatchingImpact:       cte(pt, process.total-eliminated.batching),
+		ContentionImpact:     cte(pt, process.total-eliminated.contention),
+		PrioritizationImpact: cte(pt, process.total-eliminated.prioritization),
+		UnavailabilityImpact: cte(pt, process.total-eliminated.unavailability),
+		ExtraneousImpact:     cte(pt, process.total-eliminated.extraneous),
+	}
+}
+
+// cteAnalysis computes the CTE of a process with the processing time pt and the transitions of the report. The
+// transitions are only listed if withTransitions is set.
+func cteAnalysis(pt float64, items []model.JobResultItem, withTransitions bool) *model.CteAnalysis {
+	var process waitingTimes
+	for i := range items {
+		process.add(&items[i])
+	}
+
+	analysis := &model.CteAnalysis{
+		TotalPt:               pt,
+		TotalWt:               process.total,
+		TotalBatchingWt:       process.batching,
+		TotalPrioritizationWt: process.prioritization,
+		TotalContentionWt:     process.contention,
+		TotalUnavailabilityWt: process.unavailability,
+		TotalExtraneousWt:     process.extraneous,
+		ProcessCTE:            cte(pt, process.total),
+		PotentialCTE:          potentialCTE(pt, process, process),
+	}
+
+	if withTransitions {
+		analysis.Transitions = cteTransitions(pt, process, items)
+	}
+
+	return analysis
+}
+
+// cteTransitions aggregates the report by activity transition and by resource pair within the transitions. The
+// transitions are ordered by their CTE impact, the largest first, and otherwise by their first occurrence.
+func cteTransitions(pt float64, process waitingTimes, items []model.JobResultItem) []*model.JobResultReportItem {
+	type resourcePair struct {
+		item  *model.JobResultResourceItem
+		wt    waitingTimes
+		cases map[string]bool
+	}
+	type transition struct {
+		item      *model.JobResultReportItem
+		wt        waitingTimes
+		cases     map[string]bool
+		resources []*resourcePair
+		byPair    map[[2]string]*resourcePair
+	}
+
+	var transitions []*transition
+	byActivities := map[[2]string]*transition{}
+	allCases := map[string]bool{}
+
+	for i := range items {
+		item := &items[i]
+		allCases[item.CaseID] = true
+
+		key := [2]string{item.SourceActivity, item.DestinationActivity}
+		t, ok := byActivities[key]
+		if !ok {
+			t = &transition{
+				item:   &model.JobResultReportItem{SourceActivity: item.SourceActivity, TargetActivity: item.DestinationActivity},
+				cases:  map[string]bool{},
+				byPair: map[[2]string]*resourcePair{},
+			}
+			byActivities[key] = t
+			transitions = append(transitions, t)
+		}
+		t.wt.add(item)
+		t.cases[item.CaseID] = true
+		t.item.TotalFreq++
+
+		pairKey := [2]string{item.SourceResource, item.DestinationResource}
+		pair, ok := t.byPair[pairKey]
+		if !ok {
+			pair = &resourcePair{
+				item:  &model.JobResultResourceItem{SourceResource: item.SourceResource, TargetResource: item.DestinationResource},
+				cases: map[string]bool{},
+			}
+			t.byPair[pairKey] = pair
+			t.resources = append(t.resources, pair)
+		}
+		pair.wt.add(item)
+		pair.cases[item.CaseID] = true
+		pair.item.TotalFreq++
+	}
+
+	caseFreq := func(cases map[string]bool) float64 {
+		if len(allCases) == 0 {
+			return 0
+		}
+		return float64(len(cases)) / float64(len(allCases))
+	}
+
+	result := make([]*model.JobResultReportItem, 0, len(transitions))
+	for _, t := range transitions {
+		t.item.CaseFreq = caseFreq(t.cases)
+		t.item.TotalWt = t.wt.total
+		t.item.BatchingWt = t.wt.batching
+		t.item.PrioritizationWt = t.wt.prioritization
+		t.item.ContentionWt = t.wt.contention
+		t.item.UnavailabilityWt = t.wt.unavailability
+		t.item.ExtraneousWt = t.wt.extraneous
+		t.item.CTEImpactTotal = cte(pt, process.total-t.wt.total)
+		t.item.CTEImpact = potentialCTE(pt, process, t.wt)
+
+		for _, pair := range t.resources {
+			pair.item.CaseFreq = caseFreq(pair.cases)
+			pair.item.TotalWt = pair.wt.total
+			pair.item.BatchingWt = pair.wt.batching
+			pair.item.PrioritizationWt = pair.wt.prioritization
+			pair.item.ContentionWt = pair.wt.contention
+			pair.item.UnavailabilityWt = pair.wt.unavailability
+			pair.item.ExtraneousWt = pair.wt.extraneous
+			pair.item.CTEImpact = potentialCTE(pt, process, pair.wt)
+			t.item.WtByResource = append(t.item.WtByResource, *pair.item)
+		}
+
+		result = append(result, t.item)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CTEImpactTotal > result[j].CTEImpactTotal
+	})
+
+	return result
+}
+
+// jobCteAnalysis computes the CTE of a completed job from its report. Duplicates are computed from the original's
+// report.
+func (app *Application) jobCteAnalysis(job *model.Job, withTransitions bool) (*model.CteAnalysis, error) {
+	owner, items, err := app.jobReport(job)
+	if err != nil {
+		return nil, err
+	}
+
+	pt, err := jobProcessingTime(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return cteAnalysis(pt, items, withTransitions), nil
+}
+
+// jobReport reads the transitions report of a completed job and returns it with the job which owns it, i.e., the
+// original of a duplicate.
+func (app *Application) jobReport(job *model.Job) (*model.Job, []model.JobResultItem, error) {
+	owner, result, err := app.jobResult(job, model.AnalysisKindWaitingTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	return owner, result.([]model.JobResultItem), nil
+}
+
+// jobProcessingTime returns the processing time kept with the job or reads it from the job's event log for the jobs
+// which have been analysed before it's been kept.
+func jobProcessingTime(job *model.Job) (float64, error) {
+	if job.ProcessingTime > 0 {
+		return job.ProcessingTime, nil
+	}
+	if job.EventLogDeletedAt != nil {
+		return 0, fmt.Errorf("%w, the event log has been deleted", errNoResults)
+	}
+
+	pt, err := eventLogProcessingTime(path.Join(job.Dir, job.EventLogFileName()), job.ColumnMapping)
+	if os.IsNotExist(err) {
+		return 0, fmt.Errorf("%w, the event log has been deleted", errNoResults)
+	} else if err != nil {
+		return 0, fmt.Errorf("error reading the processing time: %s", err.Error())
+	}
+	return pt, nil
+}
+
+// eventLogProcessingTime sums the durations of the activities in the CSV event log at filePath in seconds. The columns
+// are found with the column mapping or the canonical one if it's nil.
+func eventLogProcessingTime(filePath string, columnMapping *model.ColumnMapping) (float64, error) {
+	mapping := columnMapping
+	if mapping == nil {
+		mapping = &canonicalColumnMapping
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return 0, fmt.Errorf("invalid CSV header: %s", err.Error())
+	}
+	start, end := -1, -1
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\xef\xbb\xbf")
+		}
+		switch strings.TrimSpace(name) {
+		case mapping.StartTimestamp:
+			start = i
+		case mapping.EndTimestamp:
+			end = i
+		}
+	}
+	if start < 0 || end < 0 {
+		return 0, fmt.Errorf("timestamp columns %q and %q are missing", mapping.StartTimestamp, mapping.EndTimestamp)
+	}
+
+	var pt float64
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("invalid CSV: %s", err.Error())
+		}
+
+		startTime, err := parseEventLogTimestamp(record[start])
+		if err != nil {
+			line, _ := r.FieldPos(start)
+			return 0, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		endTime, err := parseEventLogTimestamp(record[end])
+		if err != nil {
+			line, _ := r.FieldPos(end)
+			return 0, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		if d := endTime.Sub(startTime).Seconds(); d > 0 {
+			pt += d
+		}
+	}
+
+	return pt, nil
+}
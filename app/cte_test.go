@@ -0,0 +1,334 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestCteAnalysis(t *testing.T) {
+	pt, err := eventLogProcessingTime("../assets/samples/manual_log_5.csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the report of the same log by the analysis, the items are rebuilt from its resource pairs
+	b, err := os.ReadFile("../assets/tests/manual_log_5_transitions_report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want model.JobResult
+	if err = json.Unmarshal(b, &want); err != nil {
+		t.Fatal(err)
+	}
+	var wantTotals struct {
+		Report []struct {
+			CTEImpactTotal float64 `json:"cte_impact_total_wt"`
+		} `json:"report"`
+	}
+	if err = json.Unmarshal(b, &wantTotals); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []model.JobResultItem
+	for _, transition := range want.Report {
+		// cases 12 to 14 go through E, F and G, the others through A, B, C and D
+		first := 0
+		if transition.SourceActivity >= "E" {
+			first = 12
+		}
+		for _, pair := range transition.WtByResource {
+			n := int(pair.TotalFreq)
+			cases := int(math.Round(pair.CaseFreq * want.NumCases))
+			for k := 0; k < n; k++ {
+				items = append(items, model.JobResultItem{
+					CaseID:              fmt.Sprint(first + k%cases),
+					SourceActivity:      transition.SourceActivity,
+					SourceResource:      pair.SourceResource,
+					DestinationActivity: transition.TargetActivity,
+					DestinationResource: pair.TargetResource,
+					WtTotal:             pair.TotalWt / float64(n),
+					WtBatching:          pair.BatchingWt / float64(n),
+					WtPrioritization:    pair.PrioritizationWt / float64(n),
+					WtContention:        pair.ContentionWt / float64(n),
+					WtUnavailability:    pair.UnavailabilityWt / float64(n),
+					WtExtraneous:        pair.ExtraneousWt / float64(n),
+				})
+			}
+		}
+	}
+
+	analysis := cteAnalysis(pt, items, true)
+
+	checkFloat(t, "total_pt", analysis.TotalPt, want.TotalPt)
+	checkFloat(t, "total_wt", analysis.TotalWt, want.TotalWt)
+	checkFloat(t, "total_batching_wt", analysis.TotalBatchingWt, want.TotalBatchingWt)
+	checkFloat(t, "total_prioritization_wt", analysis.TotalPrioritizationWt, want.TotalPrioritizationWt)
+	checkFloat(t, "total_contention_wt", analysis.TotalContentionWt, want.TotalContentionWt)
+	checkFloat(t, "total_unavailability_wt", analysis.TotalUnavailabilityWt, want.TotalUnavailabilityWt)
+	checkFloat(t, "total_extraneous_wt", analysis.TotalExtraneousWt, want.TotalExtraneousWt)
+	checkFloat(t, "process_cte", analysis.ProcessCTE, want.ProcessCTE)
+	checkImpact(t, "potential_cte", analysis.PotentialCTE, want.CTEImpact)
+
+	if len(analysis.Transitions) != len(want.Report) {
+		t.Fatalf("expected %d transitions, got %d", len(want.Report), len(analysis.Transitions))
+	}
+	for i, transition := range analysis.Transitions {
+		if i > 0 && transition.CTEImpactTotal > analysis.Transitions[i-1].CTEImpactTotal {
+			t.Fatalf("expected the transitions to be ordered by their CTE impact, got %+v", analysis.Transitions)
+		}
+
+		var wantTransition *model.JobResultReportItem
+		var wantImpactTotal float64
+		for j, item := range want.Report {
+			if item.SourceActivity == transition.SourceActivity && item.TargetActivity == transition.TargetActivity {
+				wantTransition, wantImpactTotal = item, wantTotals.Report[j].CTEImpactTotal
+			}
+		}
+		if wantTransition == nil {
+			t.Fatalf("unexpected transition %s -> %s", transition.SourceActivity, transition.TargetActivity)
+		}
+
+		name := transition.SourceActivity + " -> " + transition.TargetActivity
+		checkFloat(t, name+" case_freq", transition.CaseFreq, wantTransition.CaseFreq)
+		checkFloat(t, name+" total_freq", transition.TotalFreq, wantTransition.TotalFreq)
+		checkFloat(t, name+" total_wt", transition.TotalWt, wantTransition.TotalWt)
+		checkFloat(t, name+" cte_impact_total", transition.CTEImpactTotal, wantImpactTotal)
+		checkImpact(t, name+" cte_impact", transition.CTEImpact, wantTransition.CTEImpact)
+
+		if len(transition.WtByResource) != len(wantTransition.WtByResource) {
+			t.Fatalf("expected %d resource pairs for %s, got %d", len(wantTransition.WtByResource), name, len(transition.WtByResource))
+		}
+		for j, pair := range transition.WtByResource {
+			wantPair := wantTransition.WtByResource[j]
+			pairName := name + " " + pair.SourceResource + " -> " + pair.TargetResource
+			if pair.SourceResource != wantPair.SourceResource || pair.TargetResource != wantPair.TargetResource {
+				t.Fatalf("expected resource pair %s -> %s, got %s", wantPair.SourceResource, wantPair.TargetResource, pairName)
+			}
+			checkFloat(t, pairName+" case_freq", pair.CaseFreq, wantPair.CaseFreq)
+			checkFloat(t, pairName+" total_wt", pair.TotalWt, wantPair.TotalWt)
+			checkImpact(t, pairName+" cte_impact", pair.CTEImpact, wantPair.CTEImpact)
+		}
+	}
+
+	if analysis = cteAnalysis(pt, items, false); analysis.Transitions != nil {
+		t.Fatalf("expected no transitions, got %d", len(analysis.Transitions))
+	}
+}
+
+func TestCteAnalysis_EdgeCases(t *testing.T) {
+	waiting := []model.JobResultItem{
+		{CaseID: "0", SourceActivity: "A", DestinationActivity: "B", WtTotal: 100, WtBatching: 100},
+	}
+
+	tests := []struct {
+		name              string
+		pt                float64
+		items             []model.JobResultItem
+		wantProcessCTE    float64
+		wantBatchingCTE   float64
+		wantTransitionCTE float64
+	}{
+		{
+			name:  "no processing and no waiting time",
+			pt:    0,
+			items: nil,
+		},
+		{
+			name:           "no transitions",
+			pt:             100,
+			items:          nil,
+			wantProcessCTE: 1, wantBatchingCTE: 1,
+		},
+		{
+			name:  "only waiting time",
+			pt:    0,
+			items: waiting,
+		},
+		{
+			name:           "waiting time of a single cause",
+			pt:             100,
+			items:          waiting,
+			wantProcessCTE: 0.5, wantBatchingCTE: 1, wantTransitionCTE: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := cteAnalysis(tt.pt, tt.items, true)
+
+			// NaN or infinite CTEs can't be encoded
+			if _, err := json.Marshal(analysis); err != nil {
+				t.Fatal(err)
+			}
+
+			checkFloat(t, "process_cte", analysis.ProcessCTE, tt.wantProcessCTE)
+			checkFloat(t, "batching_impact", analysis.PotentialCTE.BatchingImpact, tt.wantBatchingCTE)
+			checkFloat(t, "contention_impact", analysis.PotentialCTE.ContentionImpact, tt.wantProcessCTE)
+			if len(analysis.Transitions) != len(tt.items) {
+				t.Fatalf("expected %d transitions, got %d", len(tt.items), len(analysis.Transitions))
+			}
+			for _, transition := range analysis.Transitions {
+				checkFloat(t, "cte_impact_total", transition.CTEImpactTotal, tt.wantTransitionCTE)
+			}
+		})
+	}
+}
+
+func TestGetJobCte(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := "start_time,end_time,source_activity,source_resource,destination_activity,destination_resource,case_id," +
+		"wt_total,wt_contention,wt_batching,wt_prioritization,wt_unavailability,wt_extraneous\n" +
+		"2022-05-16T10:15:00Z,2022-05-16T12:00:00Z,A,Marcus,B,Anya,0,6300,6300,0,0,0,0\n" +
+		"2022-05-16T12:30:00Z,2022-05-16T12:30:00Z,B,Anya,C,Dom,0,0,0,0,0,0,0\n" +
+		"2022-05-16T10:30:00Z,2022-05-16T11:00:00Z,A,Marcus,B,Anya,1,1800,0,0,0,0,1800\n"
+
+	newJob := func(id string, status model.JobStatus) *model.Job {
+		job := &model.Job{
+			ID:           id,
+			Status:       status,
+			EventLogName: "log.csv",
+			CreatedAt:    time.Now(),
+			Dir:          path.Join(t.TempDir(), id),
+			ReportCSV:    &model.URL{URL: &url.URL{Scheme: "http", Host: "localhost", Path: "/assets/results/" + id + "/log_transitions_report.csv"}},
+		}
+		if err := os.MkdirAll(job.Dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.queue.Add(job); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := app.queue.Remove(job, false); err != nil {
+				t.Fatal(err)
+			}
+		})
+		return job
+	}
+
+	// the processing time of a job analysed before it's been kept is read from the event log
+	completed := newJob("cte-completed", model.JobStatusCompleted)
+	if err = os.WriteFile(path.Join(completed.Dir, "log.csv"), eventLog, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path.Join(completed.Dir, "log_transitions_report.csv"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+	duplicate := newJob("cte-duplicate", model.JobStatusDuplicate)
+	duplicate.DuplicateOf = completed.ID
+	pending := newJob("cte-pending", model.JobStatusPending)
+	deleted := newJob("cte-deleted", model.JobStatusCompleted)
+	deleted.EventLogDeletedAt = &deleted.CreatedAt
+	if err = os.WriteFile(path.Join(deleted.Dir, "log_transitions_report.csv"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(p string, wantStatus int) *model.CteAnalysis {
+		t.Helper()
+
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != wantStatus {
+			t.Fatalf("expected status code %d for %s, got %d", wantStatus, p, res.StatusCode)
+		}
+		if wantStatus != http.StatusOK {
+			return nil
+		}
+
+		var analysis model.CteAnalysis
+		if err = json.NewDecoder(res.Body).Decode(&analysis); err != nil {
+			t.Fatal(err)
+		}
+		return &analysis
+	}
+
+	for _, id := range []string{completed.ID, duplicate.ID} {
+		analysis := get("/jobs/"+id+"/cte", http.StatusOK)
+		checkFloat(t, "total_pt", analysis.TotalPt, 80820)
+		checkFloat(t, "total_wt", analysis.TotalWt, 8100)
+		checkFloat(t, "process_cte", analysis.ProcessCTE, 80820.0/88920)
+		checkFloat(t, "contention_impact", analysis.PotentialCTE.ContentionImpact, 80820.0/82620)
+		checkFloat(t, "extraneous_impact", analysis.PotentialCTE.ExtraneousImpact, 80820.0/87120)
+		if analysis.Transitions != nil {
+			t.Fatalf("expected no transitions, got %d", len(analysis.Transitions))
+		}
+	}
+
+	analysis := get("/jobs/"+completed.ID+"/cte/transitions", http.StatusOK)
+	if len(analysis.Transitions) != 2 || analysis.Transitions[0].SourceActivity != "A" || analysis.Transitions[1].SourceActivity != "B" {
+		t.Fatalf("unexpected transitions %+v", analysis.Transitions)
+	}
+	checkFloat(t, "A -> B cte_impact_total", analysis.Transitions[0].CTEImpactTotal, 1)
+	checkFloat(t, "A -> B case_freq", analysis.Transitions[0].CaseFreq, 1)
+	checkFloat(t, "B -> C cte_impact_total", analysis.Transitions[1].CTEImpactTotal, analysis.ProcessCTE)
+	checkFloat(t, "B -> C case_freq", analysis.Transitions[1].CaseFreq, 0.5)
+
+	analysis = get("/jobs/"+completed.ID+"/cte/transitions?source_activity=B&target_activity=C", http.StatusOK)
+	if len(analysis.Transitions) != 1 || analysis.Transitions[0].TargetActivity != "C" {
+		t.Fatalf("expected the transition B -> C, got %+v", analysis.Transitions)
+	}
+	if analysis = get("/jobs/"+completed.ID+"/cte/transitions?source_activity=Z", http.StatusOK); len(analysis.Transitions) != 0 {
+		t.Fatalf("expected no transitions, got %+v", analysis.Transitions)
+	}
+
+	get("/jobs/"+pending.ID+"/cte", http.StatusConflict)
+	get("/jobs/"+deleted.ID+"/cte", http.StatusConflict)
+	get("/jobs/missing/cte", http.StatusNotFound)
+
+	// the kept processing time outlives the event log
+	deleted.ProcessingTime = 80820
+	analysis = get("/jobs/"+deleted.ID+"/cte", http.StatusOK)
+	checkFloat(t, "process_cte", analysis.ProcessCTE, 80820.0/88920)
+
+	if err = os.Remove(path.Join(completed.Dir, "log_transitions_report.csv")); err != nil {
+		t.Fatal(err)
+	}
+	get("/jobs/"+completed.ID+"/cte", http.StatusConflict)
+}
+
+func checkFloat(t *testing.T, name string, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Fatalf("expected %s %v, got %v", name, want, got)
+	}
+}
+
+func checkImpact(t *testing.T, name string, got, want *model.JobCteImpact) {
+	t.Helper()
+
+	if got == nil || want == nil {
+		t.Fatalf("expected %s %+v, got %+v", name, want, got)
+	}
+	checkFloat(t, name+" batching_impact", got.BatchingImpact, want.BatchingImpact)
+	checkFloat(t, name+" contention_impact", got.ContentionImpact, want.ContentionImpact)
+	checkFloat(t, name+" prioritization_impact", got.PrioritizationImpact, want.PrioritizationImpact)
+	checkFloat(t, name+" unavailability_impact", got.UnavailabilityImpact, want.UnavailabilityImpact)
+	checkFloat(t, name+" extraneous_impact", got.ExtraneousImpact, want.ExtraneousImpact)
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"encoding/json"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// batchingDiscoveryAnalysis discovers the batching strategies of the activities with the parameters of
+// model.BatchingDiscovery.
+func batchingDiscoveryAnalysis() *analysisDefinition {
+	return &analysisDefinition{
+		Kind: model.AnalysisKindBatchingDiscovery,
+		Params: func(params json.RawMessage) (interface{}, error) {
+			var p model.BatchingDiscovery
+			if err := model.DecodeAnalysisParams(params, &p); err != nil {
+				return nil, err
+			}
+			return p.WithDefaults(), nil
+		},
+		Executable:   "run_batching_discovery.bash",
+		Args:         []string{"{{.EventLog}}", "{{.Output}}", "{{.Params}}", "{{.ColumnsFile}}"},
+		OutputSuffix: "_batching.json",
+		Parse:        parseBatchingStrategies,
+		Endpoints: []analysisEndpoint{
+			{"GetJobBatching", "/batching", GetJobBatching},
+		},
+	}
+}
+
+// parseBatchingStrategies reads the batching strategies discovered by a job with the parameters they've been
+// discovered with.
+func parseBatchingStrategies(_ *Application, job *model.Job, filePath string) (interface{}, error) {
+	result := &model.BatchingDiscoveryResult{Parameters: &model.BatchingDiscovery{}}
+	if err := readJSONFile(filePath, &result.Strategies); err != nil {
+		return nil, err
+	}
+	if result.Strategies == nil {
+		result.Strategies = []*model.BatchingStrategy{}
+	}
+	if err := model.DecodeAnalysisParams(job.Params, result.Parameters); err != nil {
+		return nil, err
+	}
+	result.Parameters = result.Parameters.WithDefaults()
+	return result, nil
+}
+
+// prioritizationDiscoveryAnalysis discovers the rules the cases are prioritized by with the parameters of
+// model.PrioritizationDiscovery.
+func prioritizationDiscoveryAnalysis() *analysisDefinition {
+	return &analysisDefinition{
+		Kind: model.AnalysisKindPrioritizationDiscovery,
+		Params: func(params json.RawMessage) (interface{}, error) {
+			var p model.PrioritizationDiscovery
+			if err := model.DecodeAnalysisParams(params, &p); err != nil {
+				return nil, err
+			}
+			return p.WithDefaults(), nil
+		},
+		Executable:   "run_prioritization_discovery.bash",
+		Args:         []string{"{{.EventLog}}", "{{.Output}}", "{{.Params}}", "{{.ColumnsFile}}"},
+		OutputSuffix: "_prioritization.json",
+		Parse:        parsePriorityLevels,
+		Endpoints: []analysisEndpoint{
+			{"GetJobPrioritization", "/prioritization", GetJobPrioritization},
+		},
+	}
+}
+
+// parsePriorityLevels reads the priority levels discovered by a job with the parameters they've been discovered with.
+func parsePriorityLevels(_ *Application, job *model.Job, filePath string) (interface{}, error) {
+	result := &model.PrioritizationDiscoveryResult{Parameters: &model.PrioritizationDiscovery{}}
+	if err := readJSONFile(filePath, &result.PriorityLevels); err != nil {
+		return nil, err
+	}
+	if result.PriorityLevels == nil {
+		result.PriorityLevels = []*model.PriorityLevel{}
+	}
+	if err := model.DecodeAnalysisParams(job.Params, result.Parameters); err != nil {
+		return nil, err
+	}
+	result.Parameters = result.Parameters.WithDefaults()
+	return result, nil
+}
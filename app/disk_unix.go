@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package app
+
+import "syscall"
+
+// diskFree returns the number of bytes available to the server on the file system of dir.
+func diskFree(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,418 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	fetcherMaxAttempts  = 3
+	fetcherMaxRedirects = 5
+	fetcherRetryDelay   = time.Second
+)
+
+// allowedEventLogContentTypes lists media types accepted for remote event logs. A missing Content-Type header is
+// accepted as well, because many file hosts don't set it.
+var allowedEventLogContentTypes = []string{
+	"text/csv",
+	"text/plain",
+	"application/csv",
+	"application/octet-stream",
+	"application/vnd.ms-excel",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/xml",
+	"text/xml",
+	"application/json",
+	"application/x-ndjson",
+	"application/jsonl",
+	"application/vnd.apache.parquet",
+}
+
+// blockedNetworks are address ranges which are not publicly routable but aren't covered by the net.IP helpers.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64
+)
+
+// Fetcher downloads event logs from user-supplied URLs. It refuses to connect to private, loopback and link-local
+// addresses unless they are allow-listed, limits the time and size of a download, checks the response's status code and
+// content type, and resumes interrupted transfers when the server supports range requests.
+type Fetcher struct {
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+	logger  *log.Logger
+
+	allowedHosts    map[string]bool
+	allowedNetworks []*net.IPNet
+}
+
+// NewFetcher creates a fetcher from the application's configuration. Allow-list entries can be host names, IP addresses
+// or CIDR ranges.
+func NewFetcher(config *Configuration, logger *log.Logger) (*Fetcher, error) {
+	f := &Fetcher{
+		timeout:      config.DownloadTimeout,
+		maxSize:      config.DownloadMaxSize,
+		logger:       logger,
+		allowedHosts: map[string]bool{},
+	}
+
+	for _, entry := range config.DownloadAllowList {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid download allow-list entry %q: %s", entry, err.Error())
+			}
+			f.allowedNetworks = append(f.allowedNetworks, network)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			f.allowedNetworks = append(f.allowedNetworks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			f.allowedHosts[strings.ToLower(entry)] = true
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		// a proxy would make the address check meaningless, so it's never used
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+
+			if f.allowedHosts[strings.ToLower(host)] {
+				return dialer.DialContext(ctx, network, addr)
+			}
+
+			// the check is done on the address that is actually dialed to prevent DNS rebinding
+			guarded := *dialer
+			guarded.Control = func(network, address string, _ syscall.RawConn) error {
+				return f.checkAddress(address)
+			}
+			return guarded.DialContext(ctx, network, addr)
+		},
+		// raw bytes are needed for range requests to line up with what's already on disk
+		DisableCompression:    true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	f.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= fetcherMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", fetcherMaxRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+
+	return f, nil
+}
+
+// Fetch downloads rawURL into dst and returns the response metadata. The data is written to dst.part first and renamed
+// when the download is complete. If the connection drops, the download is resumed from the received offset using
+// a range request validated by the response's ETag or Last-Modified header. A previous partial download is resumed
+// when the given metadata from the previous attempt is not nil.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL, dst string, previous *model.EventLogDownload) (*model.EventLogDownload, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event log URL: %s", err.Error())
+	}
+	if err = checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	partPath := dst + ".part"
+
+	meta := &model.EventLogDownload{}
+	if previous != nil {
+		*meta = *previous
+	}
+
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil && previous != nil && previous.Resumable() {
+		offset = info.Size()
+	} else {
+		meta = &model.EventLogDownload{}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= fetcherMaxAttempts; attempt++ {
+		meta.Attempts++
+
+		var retry bool
+		offset, retry, lastErr = f.fetchOnce(ctx, u, partPath, offset, meta)
+		if lastErr == nil {
+			break
+		}
+		if !retry || ctx.Err() != nil {
+			return meta, lastErr
+		}
+
+		f.logger.Printf("Download of %s interrupted at %d bytes, retrying; %s", u.Redacted(), offset, lastErr.Error())
+
+		select {
+		case <-ctx.Done():
+			return meta, ctx.Err()
+		case <-time.After(fetcherRetryDelay * time.Duration(attempt)):
+		}
+	}
+	if lastErr != nil {
+		return meta, lastErr
+	}
+
+	if err = os.Rename(partPath, dst); err != nil {
+		return meta, err
+	}
+
+	meta.Size = offset
+	meta.DownloadedAt = time.Now()
+	return meta, nil
+}
+
+// fetchOnce makes a single request starting at the given offset and appends the body to partPath. It returns the new
+// offset and whether the request can be retried.
+func (f *Fetcher) fetchOnce(ctx context.Context, u *url.URL, partPath string, offset int64, meta *model.EventLogDownload) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return offset, false, err
+	}
+	req.Header.Set("Accept", strings.Join(allowedEventLogContentTypes, ", ")+", */*;q=0.1")
+
+	if offset > 0 && meta.Resumable() {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if meta.ETag != "" && !strings.HasPrefix(meta.ETag, "W/") {
+			req.Header.Set("If-Range", meta.ETag)
+		} else {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
+	} else {
+		offset = 0
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		var addrErr *blockedAddressError
+		if errors.As(err, &addrErr) {
+			return offset, false, addrErr
+		}
+		return offset, true, fmt.Errorf("error requesting event log: %s", err.Error())
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			f.logger.Printf("error closing response body: %s", err.Error())
+		}
+	}()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the server ignored the range or the file has changed, starting over
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return offset, false, fmt.Errorf("unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), offset)
+		}
+		flags |= os.O_APPEND
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return offset, retry, fmt.Errorf("event log request failed with status %s", resp.Status)
+	}
+
+	if err = checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return offset, false, err
+	}
+
+	meta.URL = resp.Request.URL.String()
+	meta.StatusCode = resp.StatusCode
+	meta.ContentType = resp.Header.Get("Content-Type")
+	meta.ContentEncoding = resp.Header.Get("Content-Encoding")
+	meta.ETag = resp.Header.Get("ETag")
+	meta.LastModified = resp.Header.Get("Last-Modified")
+	meta.AcceptRanges = resp.Header.Get("Accept-Ranges") == "bytes" || resp.StatusCode == http.StatusPartialContent
+	if resp.StatusCode == http.StatusOK {
+		meta.ContentLength = resp.ContentLength
+	}
+
+	if f.maxSize > 0 && meta.ContentLength > f.maxSize {
+		return offset, false, fmt.Errorf("event log is too large: %d bytes, the limit is %d bytes", meta.ContentLength, f.maxSize)
+	}
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return offset, false, err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			f.logger.Printf("error closing file: %s", err.Error())
+		}
+	}()
+
+	var body io.Reader = resp.Body
+	if f.maxSize > 0 {
+		// reading one byte more than allowed detects bodies exceeding the limit
+		body = io.LimitReader(resp.Body, f.maxSize-offset+1)
+	}
+
+	n, err := io.Copy(out, body)
+	offset += n
+	if err != nil {
+		return offset, meta.Resumable(), fmt.Errorf("error reading event log: %s", err.Error())
+	}
+
+	if f.maxSize > 0 && offset > f.maxSize {
+		return offset, false, fmt.Errorf("event log is too large, the limit is %d bytes", f.maxSize)
+	}
+
+	return offset, false, nil
+}
+
+func (f *Fetcher) checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &blockedAddressError{address: address}
+	}
+
+	for _, network := range f.allowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	if isPublicIP(ip) {
+		return nil
+	}
+
+	return &blockedAddressError{address: address}
+}
+
+type blockedAddressError struct {
+	address string
+}
+
+func (e *blockedAddressError) Error() string {
+	return fmt.Sprintf("event log address %s is not allowed", e.address)
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported event log URL scheme %q, only http and https are allowed", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("event log URL has no host")
+	}
+	return nil
+}
+
+func checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid event log content type %q: %s", contentType, err.Error())
+	}
+
+	for _, allowed := range allowedEventLogContentTypes {
+		if mediaType == allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported event log content type %q", mediaType)
+}
+
+// contentRangeStart parses the first byte position of a "bytes first-last/length" header.
+func contentRangeStart(header string) (int64, bool) {
+	rest := strings.TrimPrefix(header, "bytes ")
+	if rest == header {
+		return 0, false
+	}
+
+	dash := strings.Index(rest, "-")
+	if dash < 0 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(rest[:dash], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func makeTestFetcher(t *testing.T, allowList []string, maxSize int64) *Fetcher {
+	config := DefaultConfiguration()
+	config.DownloadAllowList = allowList
+	config.DownloadMaxSize = maxSize
+	config.DownloadTimeout = 10 * time.Second
+
+	f, err := NewFetcher(config, log.New(os.Stdout, "", log.LstdFlags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/log.csv":
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = w.Write(eventLog)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		allowList []string
+		maxSize   int64
+		wantErr   string
+	}{
+		{
+			name:      "allowed loopback",
+			url:       ts.URL + "/log.csv",
+			allowList: []string{"127.0.0.0/8"},
+		},
+		{
+			name:    "blocked loopback",
+			url:     ts.URL + "/log.csv",
+			wantErr: "is not allowed",
+		},
+		{
+			name:      "not found",
+			url:       ts.URL + "/missing.csv",
+			allowList: []string{"127.0.0.1"},
+			wantErr:   "404",
+		},
+		{
+			name:      "html page",
+			url:       ts.URL + "/page.html",
+			allowList: []string{"127.0.0.1"},
+			wantErr:   "unsupported event log content type",
+		},
+		{
+			name:      "too large",
+			url:       ts.URL + "/log.csv",
+			allowList: []string{"127.0.0.1"},
+			maxSize:   16,
+			wantErr:   "too large",
+		},
+		{
+			name:    "unsupported scheme",
+			url:     "file:///etc/passwd",
+			wantErr: "unsupported event log URL scheme",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := makeTestFetcher(t, tt.allowList, tt.maxSize)
+			dst := path.Join(t.TempDir(), "event_log.csv")
+
+			meta, err := f.Fetch(context.Background(), tt.url, dst, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, eventLog) {
+				t.Fatalf("downloaded file differs from the original")
+			}
+			if meta.StatusCode != http.StatusOK || meta.Size != int64(len(eventLog)) || meta.ContentType != "text/csv" {
+				t.Fatalf("unexpected metadata %+v", meta)
+			}
+		})
+	}
+}
+
+func TestFetcher_FetchResumes(t *testing.T) {
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const etag = `"v1"`
+	var requests []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "text/csv")
+
+		if len(requests) > 1 {
+			http.ServeContent(w, r, "log.csv", time.Time{}, bytes.NewReader(eventLog))
+			return
+		}
+
+		// drops the connection in the middle of the first response
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(eventLog)))
+		_, _ = w.Write(eventLog[:len(eventLog)/2])
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = conn.Close()
+	}))
+	defer ts.Close()
+
+	f := makeTestFetcher(t, []string{"127.0.0.1"}, 0)
+	dst := path.Join(t.TempDir(), "event_log.csv")
+
+	meta, err := f.Fetch(context.Background(), ts.URL+"/log.csv", dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, eventLog) {
+		t.Fatalf("resumed file differs from the original")
+	}
+
+	if len(requests) != 2 || requests[1] != "bytes="+strconv.Itoa(len(eventLog)/2)+"-" {
+		t.Fatalf("expected a range request after the interruption, got %q", requests)
+	}
+	if meta.Attempts != 2 || meta.StatusCode != http.StatusPartialContent {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+}
+
+func TestProcessJob_CancelDownload(t *testing.T) {
+	requested := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(requested)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.fetcher = makeTestFetcher(t, []string{"127.0.0.1"}, 0)
+
+	eventLogURL, err := url.Parse(server.URL + "/slow.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := model.NewJob(&model.URL{URL: eventLogURL}, nil, nil, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processed := make(chan struct{})
+	go func() {
+		app.processJob(job)
+		close(processed)
+	}()
+
+	<-requested
+	if !app.cancelJob(job.ID) {
+		t.Fatal("expected the download to be cancellable")
+	}
+
+	select {
+	case <-processed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the download to stop when the job is cancelled")
+	}
+	if job.Status != model.JobStatusFailed || job.Error != "job has been interrupted" {
+		t.Fatalf("unexpected status %s and error %q", job.Status, job.Error)
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+)
+
+// EventLogReader converts event logs of one input format into CSV, the only format the analysis reads.
+type EventLogReader interface {
+	// Format is the name of the format which can be given explicitly in a job's settings.
+	Format() string
+	// Detect reports whether a file is in this format judging by its name, the content type it was received with and
+	// its first bytes.
+	Detect(fileName, contentType string, head []byte) bool
+	// ToCSV converts the file at srcPath into CSV at dstPath. Field names become column names, so the job's column
+	// mapping keeps working, unless the reader reports that it writes the canonical columns instead.
+	ToCSV(srcPath, dstPath string, options EventLogReaderOptions) (canonical bool, err error)
+}
+
+// EventLogReaderOptions holds format-specific settings of a job.
+type EventLogReaderOptions struct {
+	// OCELObjectType is the object type which becomes the case notion when an object-centric log is flattened.
+	OCELObjectType string
+}
+
+const formatCSV = "csv"
+
+// eventLogReaders are tried in order when the format isn't given explicitly. CSV goes last as the fallback.
+var eventLogReaders = []EventLogReader{
+	parquetReader{},
+	xesReader{},
+	ocelReader{},
+	jsonLinesReader{},
+}
+
+// detectEventLogFormat returns the reader for the file at filePath. An explicitly given format takes precedence over
+// the detection. A nil reader means the file is CSV already.
+func detectEventLogFormat(filePath, contentType, format string) (EventLogReader, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+
+	if format != "" {
+		if format == formatCSV {
+			return nil, nil
+		}
+		for _, reader := range eventLogReaders {
+			if reader.Format() == format {
+				return reader, nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported event log format %q", format)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 4096)
+	n, _ := io.ReadFull(f, head)
+	_ = f.Close()
+	head = bytes.TrimLeft(bytes.TrimPrefix(head[:n], []byte("\xef\xbb\xbf")), " \t\r\n")
+
+	if contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+	}
+
+	fileName := strings.ToLower(path.Base(filePath))
+	for _, reader := range eventLogReaders {
+		if reader.Detect(fileName, contentType, head) {
+			return reader, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type xesReader struct{}
+
+func (xesReader) Format() string {
+	return "xes"
+}
+
+func (xesReader) Detect(fileName, contentType string, head []byte) bool {
+	if strings.HasSuffix(fileName, ".xes") {
+		return true
+	}
+	if bytes.HasPrefix(head, []byte("<?xml")) {
+		return bytes.Contains(head, []byte("<log"))
+	}
+	return bytes.HasPrefix(head, []byte("<log"))
+}
+
+func (xesReader) ToCSV(srcPath, dstPath string, _ EventLogReaderOptions) (bool, error) {
+	return true, xesToCSV(srcPath, dstPath)
+}
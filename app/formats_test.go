@@ -0,0 +1,280 @@
+package app
+
+import (
+	"encoding/csv"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func readCSVFile(t *testing.T, filePath string) [][]string {
+	f, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestDetectEventLogFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		fileName    string
+		content     string
+		contentType string
+		format      string
+		want        string
+		wantErr     bool
+	}{
+		{name: "csv", fileName: "log.csv", content: "case,activity\n1,A\n", want: formatCSV},
+		{name: "xes by content", fileName: "log", content: "<?xml version=\"1.0\"?>\n<log></log>", want: "xes"},
+		{name: "parquet by magic bytes", fileName: "log.bin", content: "PAR1....", want: "parquet"},
+		{name: "parquet by content type", fileName: "log", content: "x", contentType: "application/vnd.apache.parquet", want: "parquet"},
+		{name: "jsonl by content", fileName: "log", content: "{\"case\":1}\n{\"case\":2}\n", want: "jsonl"},
+		{name: "jsonl by extension", fileName: "log.ndjson", content: "", want: "jsonl"},
+		{name: "ocel 1.0", fileName: "log.json", content: "{\"ocel:global-event\":{}}", want: "ocel"},
+		{name: "ocel 2.0", fileName: "log.json", content: "{\"objectTypes\":[],\"eventTypes\":[]}", want: "ocel"},
+		{name: "explicit format", fileName: "log.csv", content: "case,activity\n", format: "JSONL", want: "jsonl"},
+		{name: "unknown format", fileName: "log.csv", content: "", format: "xlsx", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := path.Join(dir, tt.fileName)
+			if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := detectEventLogFormat(filePath, tt.contentType, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := formatCSV
+			if reader != nil {
+				got = reader.Format()
+			}
+			if got != tt.want {
+				t.Fatalf("expected format %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJSONLinesToCSV(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "log.jsonl")
+	dst := path.Join(dir, "log.csv")
+
+	jsonl := `{"case_id": 1, "activity": "A", "start": "2022-05-16T10:00:00Z", "end": "2022-05-16T10:15:00Z"}
+
+{"case_id": 1, "activity": "B", "start": "2022-05-16T11:00:00Z", "end": "2022-05-16T11:30:00Z", "meta": {"cost": 10.5, "tags": ["x"]}, "done": true}
+`
+	if err := os.WriteFile(src, []byte(jsonl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	canonical, err := jsonLinesReader{}.ToCSV(src, dst, EventLogReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canonical {
+		t.Fatalf("expected the JSON-lines reader to keep the field names")
+	}
+
+	want := [][]string{
+		{"activity", "case_id", "end", "start", "done", "meta.cost", "meta.tags"},
+		{"A", "1", "2022-05-16T10:15:00Z", "2022-05-16T10:00:00Z", "", "", ""},
+		{"B", "1", "2022-05-16T11:30:00Z", "2022-05-16T11:00:00Z", "true", "10.5", `["x"]`},
+	}
+	if records := readCSVFile(t, dst); !reflect.DeepEqual(records, want) {
+		t.Fatalf("unexpected CSV\n got: %v\nwant: %v", records, want)
+	}
+
+	if err = os.WriteFile(src, []byte("{\"case_id\": 1}\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (jsonLinesReader{}).ToCSV(src, dst, EventLogReaderOptions{}); err == nil {
+		t.Fatalf("expected an error for an invalid line")
+	}
+}
+
+func TestParquetToCSV(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "log.parquet")
+	dst := path.Join(dir, "log.csv")
+
+	schema := `{
+		"Tag": "name=parquet_go_root",
+		"Fields": [
+			{"Tag": "name=case_id, type=INT64"},
+			{"Tag": "name=activity, type=BYTE_ARRAY, convertedtype=UTF8"},
+			{"Tag": "name=resource, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"},
+			{"Tag": "name=start_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"},
+			{"Tag": "name=end_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"},
+			{"Tag": "name=cost, type=DOUBLE"}
+		]
+	}`
+
+	fw, err := local.NewLocalFileWriter(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw, err := writer.NewJSONWriter(schema, fw, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []string{
+		`{"case_id": 1, "activity": "A", "resource": "Marcus", "start_time": 1652695200000, "end_time": 1652696100000, "cost": 10.5}`,
+		`{"case_id": 1, "activity": "B", "resource": null, "start_time": 1652698800000, "end_time": 1652700600000, "cost": 2}`,
+	}
+	for _, row := range rows {
+		if err = pw.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if reader, err := detectEventLogFormat(src, "", ""); err != nil || reader == nil || reader.Format() != "parquet" {
+		t.Fatalf("expected the file to be detected as Parquet, got %v, %v", reader, err)
+	}
+
+	if _, err = (parquetReader{}).ToCSV(src, dst, EventLogReaderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"case_id", "activity", "resource", "start_time", "end_time", "cost"},
+		{"1", "A", "Marcus", "2022-05-16T10:00:00.000Z", "2022-05-16T10:15:00.000Z", "10.5"},
+		{"1", "B", "", "2022-05-16T11:00:00.000Z", "2022-05-16T11:30:00.000Z", "2"},
+	}
+	if records := readCSVFile(t, dst); !reflect.DeepEqual(records, want) {
+		t.Fatalf("unexpected CSV\n got: %v\nwant: %v", records, want)
+	}
+}
+
+func TestOCELToCSV(t *testing.T) {
+	ocel1 := `{
+		"ocel:global-log": {"ocel:object-types": ["order", "item"]},
+		"ocel:events": {
+			"e2": {"ocel:activity": "pick item", "ocel:timestamp": "2022-05-16T11:00:00Z", "ocel:omap": ["o1", "i1", "i2"], "ocel:vmap": {"org:resource": "Anya"}},
+			"e1": {"ocel:activity": "place order", "ocel:timestamp": "2022-05-16T10:00:00Z", "ocel:omap": ["o1"], "ocel:vmap": {"org:resource": "Marcus", "price": 12.5}}
+		},
+		"ocel:objects": {
+			"o1": {"ocel:type": "order", "ocel:ovmap": {}},
+			"i1": {"ocel:type": "item", "ocel:ovmap": {}},
+			"i2": {"ocel:type": "item", "ocel:ovmap": {}}
+		}
+	}`
+
+	ocel2 := `{
+		"objectTypes": [{"name": "order", "attributes": []}],
+		"eventTypes": [{"name": "place order", "attributes": []}],
+		"objects": [{"id": "o1", "type": "order"}],
+		"events": [
+			{"id": "e1", "type": "place order", "time": "2022-05-16T10:00:00Z", "attributes": [{"name": "resource", "value": "Marcus"}], "relationships": [{"objectId": "o1", "qualifier": ""}]}
+		]
+	}`
+
+	header := []string{"case:concept:name", "concept:name", "org:resource", "start_timestamp", "time:timestamp"}
+
+	tests := []struct {
+		name       string
+		document   string
+		objectType string
+		want       [][]string
+		wantErr    bool
+	}{
+		{
+			name:       "ocel 1.0 by order",
+			document:   ocel1,
+			objectType: "order",
+			want: [][]string{
+				append(header, "price"),
+				{"o1", "place order", "Marcus", "2022-05-16T10:00:00.000Z", "2022-05-16T10:00:00.000Z", "12.5"},
+				{"o1", "pick item", "Anya", "2022-05-16T11:00:00.000Z", "2022-05-16T11:00:00.000Z", ""},
+			},
+		},
+		{
+			name:       "ocel 1.0 by item",
+			document:   ocel1,
+			objectType: "item",
+			want: [][]string{
+				append(header, "price"),
+				{"i1", "pick item", "Anya", "2022-05-16T11:00:00.000Z", "2022-05-16T11:00:00.000Z", ""},
+				{"i2", "pick item", "Anya", "2022-05-16T11:00:00.000Z", "2022-05-16T11:00:00.000Z", ""},
+			},
+		},
+		{
+			name:     "ocel 1.0 without object type",
+			document: ocel1,
+			wantErr:  true,
+		},
+		{
+			name:       "ocel 1.0 with unknown object type",
+			document:   ocel1,
+			objectType: "customer",
+			wantErr:    true,
+		},
+		{
+			name:     "ocel 2.0 with a single object type",
+			document: ocel2,
+			want: [][]string{
+				header,
+				{"o1", "place order", "Marcus", "2022-05-16T10:00:00.000Z", "2022-05-16T10:00:00.000Z"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := path.Join(dir, "log.jsonocel")
+			dst := path.Join(dir, "log.csv")
+
+			if err := os.WriteFile(src, []byte(tt.document), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			canonical, err := ocelReader{}.ToCSV(src, dst, EventLogReaderOptions{OCELObjectType: tt.objectType})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !canonical {
+				t.Fatalf("expected the OCEL reader to write the canonical columns")
+			}
+
+			if records := readCSVFile(t, dst); !reflect.DeepEqual(records, tt.want) {
+				t.Fatalf("unexpected CSV\n got: %v\nwant: %v", records, tt.want)
+			}
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	_ "embed"
@@ -92,20 +94,26 @@ func SampleCallback(app *Application) http.HandlerFunc {
 //	    $ref: '#/definitions/ApiJobsResponse'
 func GetJobs(app *Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		apiResponse := model.ApiJobsResponse{Jobs: app.queue.Jobs}
+		apiResponse := model.ApiJobsResponse{Jobs: app.links(r).jobs(app.queue.Jobs)}
 		reply(w, http.StatusOK, apiResponse, app.logger)
 	}
 }
 
 // swagger:operation POST /jobs postJob
 //
-// Submit a job for analysis. The endpoint accepts JSON and CSV request bodies. If the callback URL is provided, a GET
-// request with empty body is sent to this endpoint when analysis is complete.
+// Submit a job for analysis. The job is described by a JSON request with the event log's URL, or the event log is
+// uploaded as the CSV, XES, Parquet, JSON-lines or OCEL body or in the "event_log" part of a multipart request.
 //
 // ---
 // Consumes:
 //   - application/json
 //   - text/csv
+//   - application/xml
+//   - application/vnd.apache.parquet
+//   - application/x-ndjson
+//   - multipart/form-data
+//   - application/gzip
+//   - application/zip
 //
 // Produces:
 //   - application/json
@@ -113,19 +121,43 @@ func GetJobs(app *Application) http.HandlerFunc {
 // Parameters:
 //   - name: Body
 //     in: body
-//     description: Description of a job
+//     description: 'Description of a job. An uploaded event log comes with the settings in the query string or in the
+//     other parts of a multipart request, e.g., "column_mapping", "callback_endpoint" and "options". Event logs
+//     compressed with gzip or zip, up to twice, and bodies sent with "Content-Encoding: gzip" are decompressed. The
+//     format is detected from the content type, the file extension or the content unless "event_log_format" is
+//     given; XES logs need no column mapping, OCEL logs are flattened by "ocel_object_type". With "auto_map" the
+//     column mapping is inferred like POST /logs/inspect does. "preprocessing" filters and samples the event log
+//     before the analysis. Timestamps without an offset are read in the IANA "timezone", UTC by default. "force"
+//     runs an analysis which has been done before. "retain_until" and "pinned" keep the job past the global
+//     retention, see PUT /jobs/{id}/retention. "callback_endpoint" gets a GET request when the analysis is
+//     complete. A job with "depends_on" runs once these jobs have completed, with "input_from" it analyses the
+//     event log of one of them, see POST /pipelines.'
 //     required: true
 //     schema:
 //     $ref: '#/definitions/ApiRequest'
+//   - name: X-Owner
+//     in: header
+//     description: Owner of the job, honored only from a trusted reverse proxy, the client's IP address otherwise
+//     required: false
+//     type: string
 //
 // Responses:
 //
 //	default:
+//	  description: The request is invalid, or it's over the limits of GET /usage with 429 or 507 and the Retry-After
+//	    header
 //	  schema:
 //	    $ref: '#/definitions/ApiResponseError'
-//	200:
+//	201:
+//	  description: The job has been queued. It gets the "duplicate" status and links to the results of "duplicate_of"
+//	    if the same event log has been analysed before with the same settings.
 //	  schema:
 //	    $ref: '#/definitions/ApiSingleJobResponse'
+//	422:
+//	  description: The event log, uploaded or downloaded from its URL, or the column mapping is invalid, e.g., a column
+//	    is missing, timestamps are invalid or events end before they start
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseValidationError'
 func PostJob(app *Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Read the event log from the request body
@@ -145,40 +177,139 @@ func PostJob(app *Application) http.HandlerFunc {
 		}
 		_ = r.Body.Close()
 
-		job, err := model.NewJob(apiRequest.EventLogURL_, apiRequest.CallbackEndpointURL_, apiRequest.ColumnMapping, app.config.ResultsDir)
-		if err != nil {
-			message := fmt.Sprintf("cannot create a job; %s", err)
-			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+		job, err := app.newJobFromApiRequest(r, &apiRequest)
+		if validation, ok := err.(*eventLogValidation); ok {
+			reply(w, http.StatusUnprocessableEntity, validation.Response(), app.logger)
+			return
+		} else if err != nil {
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: err.Error()}, app.logger)
 			return
 		}
 
-		if err = job.Validate(); err != nil {
+		if err = app.checkDependencies(job); err != nil {
 			message := fmt.Sprintf("invalid job; %s", err)
 			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
 			return
 		}
 
+		// the size of a downloaded event log isn't known yet
+		if err = app.admitJob(job.Owner, 0); err != nil {
+			replyAddJobError(app, w, job, err)
+			return
+		}
+
+		// the event log is downloaded and validated before the job is queued, so that an invalid one is rejected
+		if job.InputFrom == "" {
+			if !downloadAndCheckEventLog(app, w, r, job) {
+				return
+			}
+
+			if err = app.checkStorageQuota(job.Owner, dirSize(job.Dir)); err != nil {
+				replyAddJobError(app, w, job, err)
+				return
+			}
+		}
+
 		if err = app.AddJob(job); err != nil {
-			message := fmt.Sprintf("failed to add a job to the queue; %s", err)
-			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			replyAddJobError(app, w, job, err)
 			return
 		}
 
-		apiResponse := model.ApiSingleJobResponse{Job: job}
+		apiResponse := model.ApiSingleJobResponse{Job: app.links(r).job(job)}
 		reply(w, http.StatusCreated, apiResponse, app.logger)
 	}
 }
 
+// newJobFromApiRequest creates a job from the JSON request of POST /jobs. The column mapping's problems are returned
+// as an *eventLogValidation.
+func (app *Application) newJobFromApiRequest(r *http.Request, apiRequest *model.ApiRequest) (*model.Job, error) {
+	job, err := model.NewJob(apiRequest.EventLogURL_, apiRequest.CallbackEndpointURL_, apiRequest.ColumnMapping, app.config.ResultsDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create a job; %s", err)
+	}
+
+	if validation := validateColumnMapping(apiRequest.ColumnMapping); !validation.Valid() {
+		return nil, validation
+	}
+
+	if apiRequest.EventLogFormat != "" {
+		if _, err = detectEventLogFormat("", "", apiRequest.EventLogFormat); err != nil {
+			return nil, fmt.Errorf("invalid job; %s", err)
+		}
+	}
+	job.EventLogFormat = apiRequest.EventLogFormat
+	job.OCELObjectType = apiRequest.OCELObjectType
+	job.AutoMap = apiRequest.AutoMap
+
+	if apiRequest.Preprocessing != nil {
+		if err = apiRequest.Preprocessing.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid job; preprocessing is invalid: %s", err)
+		}
+	}
+	job.Preprocessing = apiRequest.Preprocessing
+
+	if job.Kind, job.Params, err = app.resolveAnalysis(apiRequest.Kind, apiRequest.Params); err != nil {
+		return nil, fmt.Errorf("invalid job; %s", err)
+	}
+
+	if _, err = loadTimezone(apiRequest.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid job; %s", err)
+	}
+	job.Timezone = apiRequest.Timezone
+	job.Force = apiRequest.Force
+
+	if err = model.ValidateRetainUntil(apiRequest.RetainUntil); err != nil {
+		return nil, fmt.Errorf("invalid job; %s", err)
+	}
+	job.RetainUntil = apiRequest.RetainUntil
+	job.Pinned = apiRequest.Pinned
+	job.Owner = app.requestOwner(r)
+	job.DependsOn = apiRequest.DependsOn
+	job.InputFrom = apiRequest.InputFrom
+
+	if err = job.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid job; %s", err)
+	}
+
+	return job, nil
+}
+
 func PostJobFromBody(app *Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		columnMapping := columnMappingFromRequest(r)
+		// the body's size is known before reading it unless it's chunked or compressed
+		size := r.ContentLength
+		if limit := app.config.UploadMaxSize; limit > 0 && size > limit && r.Header.Get("Content-Encoding") == "" {
+			message := fmt.Sprintf("%s, the limit is %d bytes", errEventLogTooLarge, limit)
+			reply(w, http.StatusRequestEntityTooLarge, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
 
-		job, err := app.newJobFromRequestBody(r.Body, columnMapping)
-		if err != nil {
+		owner := app.requestOwner(r)
+		if err := app.admitJob(owner, size); err != nil {
+			replyAdmissionError(app, w, err, "failed to admit the job")
+			return
+		}
+
+		job, err := app.newJobFromRequestBody(r)
+		if errors.Is(err, errEventLogTooLarge) {
+			reply(w, http.StatusRequestEntityTooLarge, model.ApiResponseError{Error: err.Error()}, app.logger)
+			return
+		} else if err != nil {
 			message := fmt.Sprintf("failed to create a job from the request body; %s", err)
 			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
 			return
 		}
+		job.Owner = owner
+
+		// the size is known now, also for chunked and compressed bodies
+		if err = app.checkStorageQuota(owner, dirSize(job.Dir)); err != nil {
+			replyAddJobError(app, w, job, err)
+			return
+		}
+
+		if !checkEventLog(app, w, job) {
+			return
+		}
 
 		if err = job.Validate(); err != nil {
 			message := fmt.Sprintf("invalid job; %s", err)
@@ -187,19 +318,18 @@ func PostJobFromBody(app *Application) http.HandlerFunc {
 		}
 
 		if err = app.AddJob(job); err != nil {
-			message := fmt.Sprintf("failed to add a job to the queue; %s", err)
-			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			replyAddJobError(app, w, job, err)
 			return
 		}
 
-		apiResponse := model.ApiSingleJobResponse{Job: job}
+		apiResponse := model.ApiSingleJobResponse{Job: app.links(r).job(job)}
 		reply(w, http.StatusCreated, apiResponse, app.logger)
 	}
 }
 
 // swagger:route DELETE /jobs deleteJobs
 //
-// Delete all non-running jobs. If a job is running, it returns an error. Cancel the running jobs manually before deleting them.
+// Delete all non-running jobs. If a job is running or claimed by a worker, it returns an error. Cancel the running jobs manually before deleting them.
 //
 // ---
 // Responses:
@@ -212,12 +342,17 @@ func PostJobFromBody(app *Application) http.HandlerFunc {
 //	    $ref: '#/definitions/ApiJobsResponse'
 func DeleteJobs(app *Application) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		jobs := app.queue.Snapshot()
+
 		err := app.queue.Clear()
 		if err != nil {
 			message := fmt.Sprintf("failed to clear the queue; %s", err)
 			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
 			return
 		}
+		for _, job := range jobs {
+			app.audit(auditActionJobDeleted, job, "deleted with DELETE /jobs", job.Dir)
+		}
 
 		if err = app.SaveQueue(); err != nil {
 			message := fmt.Sprintf("failed to save the queue; %s", err)
@@ -231,7 +366,7 @@ func DeleteJobs(app *Application) http.HandlerFunc {
 			return
 		}
 
-		apiResponse := model.ApiJobsResponse{Jobs: app.queue.Jobs}
+		apiResponse := model.ApiJobsResponse{Jobs: app.links(r).jobs(app.queue.Jobs)}
 		reply(w, http.StatusOK, apiResponse, app.logger)
 	}
 }
@@ -270,7 +405,7 @@ func GetJobByID(app *Application) http.HandlerFunc {
 		id := vars["id"]
 
 		job := app.queue.FindByID(id)
-		apiResponse.Job = job
+		apiResponse.Job = app.links(r).job(job)
 
 		if job == nil {
 			var apiResponse model.ApiResponseError
@@ -322,17 +457,18 @@ func CancelJobByID(app *Application) http.HandlerFunc {
 		if job.Status == model.JobStatusPending {
 			job.SetStatus(model.JobStatusFailed)
 			job.SetError(errors.New("job cancelled by user"))
-			reply(w, http.StatusOK, model.ApiSingleJobResponse{Job: job}, app.logger)
+			if job.BatchID != "" {
+				app.finishBatch(job.BatchID)
+			}
+			reply(w, http.StatusOK, model.ApiSingleJobResponse{Job: app.links(r).job(job)}, app.logger)
 			return
 		}
 
 		if job.Status == model.JobStatusRunning {
-			// NOTE: this works because we have only one running job at a time and this cancel func cancels whichever
-			// job is running at the moment independent of the job ID
-			app.analysisCancelFunc()
+			app.cancelJob(job.ID)
 
 			job.SetStatus(model.JobStatusFailed)
-			reply(w, http.StatusOK, model.ApiSingleJobResponse{Job: job}, app.logger)
+			reply(w, http.StatusOK, model.ApiSingleJobResponse{Job: app.links(r).job(job)}, app.logger)
 			return
 		}
 
@@ -340,6 +476,499 @@ func CancelJobByID(app *Application) http.HandlerFunc {
 	}
 }
 
+// swagger:operation PUT /jobs/{id}/retention setJobRetention
+//
+// Set how long a job and its results are kept. The job is deleted at "retain_until" or, if it's omitted, when the
+// global job retention has passed since the job has been created. A pinned job is kept regardless. The job's event log
+// is deleted after the global event log retention even if the job is pinned.
+//
+// ---
+// Consumes:
+//   - application/json
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//   - name: Body
+//     in: body
+//     description: Retention of the job
+//     required: true
+//     schema:
+//     $ref: '#/definitions/ApiRetentionRequest'
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/ApiSingleJobResponse'
+func PutJobRetention(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		job := app.queue.FindByID(id)
+		if job == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("job with id %s not found", id)}, app.logger)
+			return
+		}
+
+		var retention model.ApiRetentionRequest
+		if err := json.NewDecoder(r.Body).Decode(&retention); err != nil {
+			message := fmt.Sprintf("invalid request body; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+		_ = r.Body.Close()
+
+		if err := model.ValidateRetainUntil(retention.RetainUntil); err != nil {
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: err.Error()}, app.logger)
+			return
+		}
+
+		job.SetRetention(retention.RetainUntil, retention.Pinned)
+
+		if err := app.SaveQueue(); err != nil {
+			message := fmt.Sprintf("failed to save the queue; %s", err)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		reply(w, http.StatusOK, model.ApiSingleJobResponse{Job: app.links(r).job(job)}, app.logger)
+	}
+}
+
+// swagger:operation GET /jobs/{id}/cte getJobCte
+//
+// Get the cycle time efficiency (CTE) of a completed job's process, i.e., the share of the processing time in the cycle
+// time, and the potential CTE of the process if the waiting times of each cause were eliminated. A duplicate gets the
+// CTE of the original job.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/CteAnalysis'
+func GetJobCte(app *Application) http.HandlerFunc {
+	return jobCteHandler(app, false)
+}
+
+// swagger:operation GET /jobs/{id}/cte/transitions getJobCteTransitions
+//
+// Get the CTE of a completed job's process with the improvement potential of every activity transition. The
+// transitions are ordered by the process CTE if their waiting times were eliminated, the largest improvement first,
+// and have the process CTE if their waiting times of each cause were eliminated. The transitions can be filtered by
+// their activities.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//   - name: source_activity
+//     in: query
+//     description: Source activity of the transitions
+//     required: false
+//     type: string
+//   - name: target_activity
+//     in: query
+//     description: Target activity of the transitions
+//     required: false
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/CteAnalysis'
+func GetJobCteTransitions(app *Application) http.HandlerFunc {
+	return jobCteHandler(app, true)
+}
+
+func jobCteHandler(app *Application, withTransitions bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		job := app.queue.FindByID(id)
+		if job == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("job with id %s not found", id)}, app.logger)
+			return
+		}
+
+		analysis, err := app.jobCteAnalysis(job, withTransitions)
+		if err != nil {
+			replyReportError(app, w, err)
+			return
+		}
+
+		source, target := r.URL.Query().Get("source_activity"), r.URL.Query().Get("target_activity")
+		if source != "" || target != "" {
+			transitions := analysis.Transitions[:0]
+			for _, t := range analysis.Transitions {
+				if (source == "" || t.SourceActivity == source) && (target == "" || t.TargetActivity == target) {
+					transitions = append(transitions, t)
+				}
+			}
+			analysis.Transitions = transitions
+		}
+
+		reply(w, http.StatusOK, analysis, app.logger)
+	}
+}
+
+// swagger:operation GET /jobs/{id}/overview getJobOverview
+//
+// Get an overview of a completed job's waiting times: the number of cases, activities and transitions and the total
+// and the average waiting time of every cause. The averages are per transition instance. A duplicate gets the overview
+// of the original job.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/JobOverview'
+func GetJobOverview(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		job := app.queue.FindByID(id)
+		if job == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("job with id %s not found", id)}, app.logger)
+			return
+		}
+
+		overview, err := app.jobOverview(job)
+		if err != nil {
+			replyReportError(app, w, err)
+			return
+		}
+
+		reply(w, http.StatusOK, overview, app.logger)
+	}
+}
+
+// swagger:operation GET /jobs/{id}/waiting-time/{cause} getJobWaitingTime
+//
+// Break the waiting time of a cause down by activity transition, by resource pair, by the resource the cases wait for
+// and by case. The cause is one of "batching", "prioritization", "contention", "unavailability" and "extraneous", or
+// "total" for all of them. The breakdown can be narrowed to the transitions between some activities, e.g., to get the
+// resource pairs and the cases of a single transition. The groups are ordered by the waiting time of the cause, the
+// largest first.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//   - name: cause
+//     in: path
+//     description: Cause of the waiting time
+//     required: true
+//     type: string
+//     enum: [total, batching, prioritization, contention, unavailability, extraneous]
+//   - name: source_activity
+//     in: query
+//     description: Source activity of the transitions
+//     required: false
+//     type: string
+//   - name: target_activity
+//     in: query
+//     description: Target activity of the transitions
+//     required: false
+//     type: string
+//   - name: limit
+//     in: query
+//     description: Maximum number of groups in every list, 10 by default, 0 for all of them
+//     required: false
+//     type: integer
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/WaitingTimeBreakdown'
+func GetJobWaitingTime(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, cause := vars["id"], vars["cause"]
+
+		if !isWaitingTimeCause(cause) {
+			message := fmt.Sprintf("invalid cause %q, expected one of %s", cause, strings.Join(waitingTimeCauses, ", "))
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		query := r.URL.Query()
+		limit := defaultWaitingTimeGroups
+		if value := query.Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+				reply(w, http.StatusBadRequest, model.ApiResponseError{Error: "limit must be a non-negative integer"}, app.logger)
+				return
+			}
+		}
+
+		job := app.queue.FindByID(id)
+		if job == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("job with id %s not found", id)}, app.logger)
+			return
+		}
+
+		_, items, err := app.jobReport(job)
+		if err != nil {
+			replyReportError(app, w, err)
+			return
+		}
+
+		breakdown := waitingTimeBreakdown(items, cause, query.Get("source_activity"), query.Get("target_activity"), limit)
+		reply(w, http.StatusOK, breakdown, app.logger)
+	}
+}
+
+// swagger:operation GET /jobs/{id}/calendars getJobCalendars
+//
+// Get the resources' calendars discovered by a completed calendar discovery job with the parameters they've been
+// discovered with. A duplicate gets the calendars of the original job.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/CalendarDiscoveryResult'
+func GetJobCalendars(app *Application) http.HandlerFunc {
+	return jobResultHandler(app, model.AnalysisKindCalendarDiscovery)
+}
+
+// swagger:operation GET /jobs/{id}/batching getJobBatching
+//
+// Get the batching strategies discovered by a completed batching discovery job with the parameters they've been
+// discovered with. A duplicate gets the strategies of the original job.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/BatchingDiscoveryResult'
+func GetJobBatching(app *Application) http.HandlerFunc {
+	return jobResultHandler(app, model.AnalysisKindBatchingDiscovery)
+}
+
+// swagger:operation GET /jobs/{id}/prioritization getJobPrioritization
+//
+// Get the priority levels discovered by a completed prioritization discovery job with the parameters they've been
+// discovered with. A duplicate gets the priority levels of the original job.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Job's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/PrioritizationDiscoveryResult'
+func GetJobPrioritization(app *Application) http.HandlerFunc {
+	return jobResultHandler(app, model.AnalysisKindPrioritizationDiscovery)
+}
+
+// swagger:operation GET /usage getUsage
+//
+// Get the storage and the queue used by the client with the limits which apply to it. The client is the owner given in
+// the "X-Owner" header by a trusted reverse proxy or its IP address. Submissions over a limit are rejected with 429
+// for too many pending jobs or uploads and with 507 for the storage quota or the free disk space, both with the
+// Retry-After header.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: X-Owner
+//     in: header
+//     description: Owner of the jobs and uploads, honored only from a trusted reverse proxy
+//     required: false
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/Usage'
+func GetUsage(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usage := app.ownerUsage(app.requestOwner(r))
+		usage.StorageQuota = app.config.OwnerStorageQuota
+		usage.MaxPendingJobs = app.config.MaxPendingJobsPerOwner
+		usage.MaxUploads = app.config.MaxUploadsPerOwner
+		usage.MaxEventLogSize = app.config.UploadMaxSize
+		usage.MinFreeDiskSpace = app.config.MinFreeDiskSpace
+
+		free, err := diskFree(app.config.ResultsDir)
+		if err != nil {
+			message := fmt.Sprintf("cannot check free disk space; %s", err)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+		usage.DiskFreeBytes = free
+
+		reply(w, http.StatusOK, usage, app.logger)
+	}
+}
+
+// swagger:operation POST /logs/inspect inspectEventLog
+//
+// Inspect an event log and propose a column mapping for it. The log is sent like to POST /jobs, as the request body or
+// in the "event_log" part of a multipart request, and isn't stored. The first rows are profiled: every column gets a
+// score for every role from its name and its values, i.e., the share of timestamps, the number of distinct values and
+// how the events group into cases. The best column for every role is proposed with a confidence between 0 and 1. The
+// proposal can be applied to a job with the "auto_map" option of POST /jobs.
+//
+// ---
+// Consumes:
+//   - text/csv
+//   - application/xml
+//   - application/vnd.apache.parquet
+//   - application/x-ndjson
+//   - multipart/form-data
+//   - application/gzip
+//   - application/zip
+//
+// Produces:
+//   - application/json
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/EventLogInspection'
+func InspectEventLog(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dir, err := os.MkdirTemp(app.uploads.dir, "inspect-")
+		if err != nil {
+			message := fmt.Sprintf("failed to create a temporary directory; %s", err)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				app.logger.Printf("error removing temporary directory: %s", err.Error())
+			}
+		}()
+
+		upload, err := app.receiveEventLog(r, dir)
+		if err != nil {
+			message := fmt.Sprintf("invalid event log; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		inspection, err := inspectEventLog(path.Join(dir, upload.EventLogName))
+		if err != nil {
+			message := fmt.Sprintf("failed to inspect the event log; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+		inspection.EventLogFormat = upload.EventLogFormat
+
+		reply(w, http.StatusOK, inspection, app.logger)
+	}
+}
+
 func reply(w http.ResponseWriter, statusCode int, response interface{}, logger *log.Logger) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(statusCode)
@@ -347,6 +976,27 @@ func reply(w http.ResponseWriter, statusCode int, response interface{}, logger *
 	checkError(err, "failed to encode JSON response", logger)
 }
 
+// replyAddJobError replies to a job which couldn't be queued, with 429 or 507 if it has been rejected by the admission
+// control. The job's directory is removed, if the event log has been uploaded into it.
+func replyAddJobError(app *Application, w http.ResponseWriter, job *model.Job, err error) {
+	if (job.EventLogFromRequestBody || job.EventLogDownloaded()) && job.Dir != "" {
+		if err := os.RemoveAll(job.Dir); err != nil {
+			app.logger.Printf("error removing job's directory: %s", err.Error())
+		}
+	}
+
+	replyAdmissionError(app, w, err, "failed to add a job to the queue")
+}
+
+// replyReportError replies with 409 if the job has no results to analyse and with 500 otherwise.
+func replyReportError(app *Application, w http.ResponseWriter, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, errNoResults) {
+		statusCode = http.StatusConflict
+	}
+	reply(w, statusCode, model.ApiResponseError{Error: err.Error()}, app.logger)
+}
+
 func checkError(err error, message string, logger *log.Logger) {
 	if err == nil {
 		return
@@ -354,51 +1004,47 @@ func checkError(err error, message string, logger *log.Logger) {
 	logger.Printf("%s; %s", message, err)
 }
 
-func columnMappingFromRequest(r *http.Request) map[string]string {
-	vars := mapFromRawQuery(r.URL.RawQuery)
-
-	var (
-		caseID    string
-		activity  string
-		resource  string
-		startTime string
-		endTime   string
-		ok        bool
-	)
-
-	columnMapping := make(map[string]string)
-
-	caseID, ok = vars["case"]
-	if ok {
-		columnMapping["case"] = caseID
-	}
-	activity, ok = vars["activity"]
-	if ok {
-		columnMapping["activity"] = activity
-	}
-	resource, ok = vars["resource"]
-	if ok {
-		columnMapping["resource"] = resource
+// downloadEventLog downloads the event log of a job submitted with its URL into the job's directory and validates it,
+// see Application.downloadEventLog. It replies with 422 and the problems if the event log is invalid and with 400 if it
+// can't be downloaded or read, the job's directory is removed then.
+func downloadAndCheckEventLog(app *Application, w http.ResponseWriter, r *http.Request, job *model.Job) bool {
+	err := app.downloadEventLog(r.Context(), job)
+	if err == nil {
+		return true
 	}
-	startTime, ok = vars["start_timestamp"]
-	if ok {
-		columnMapping["start_timestamp"] = startTime
+
+	if err := os.RemoveAll(job.Dir); err != nil {
+		app.logger.Printf("error removing job's directory: %s", err.Error())
 	}
-	endTime, ok = vars["end_timestamp"]
-	if ok {
-		columnMapping["end_timestamp"] = endTime
+
+	if validation, ok := err.(*eventLogValidation); ok {
+		reply(w, http.StatusUnprocessableEntity, validation.Response(), app.logger)
+		return false
 	}
 
-	return columnMapping
+	message := fmt.Sprintf("failed to prepare the event log; %s", err)
+	reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+	return false
 }
 
-func mapFromRawQuery(query string) map[string]string {
-	queryMap := make(map[string]string)
-	for _, pair := range strings.Split(query, "&") {
-		values := strings.Split(pair, "=")
-		if len(values) == 2 {
-			queryMap[values[0]] = values[1]
-		}
+// checkEventLog validates the event log of a job which has been uploaded and replies with 422 and the list of problems
+// if it's invalid. The job's directory is removed then, because the job isn't queued.
+func checkEventLog(app *Application, w http.ResponseWriter, job *model.Job) bool {
+	validation, err := validateEventLog(path.Join(job.Dir, job.EventLogFileName()), job.ColumnMapping)
+	if err == nil && validation.Valid() {
+		return true
+	}
+
+	if err := os.RemoveAll(job.Dir); err != nil {
+		app.logger.Printf("error removing job's directory: %s", err.Error())
 	}
-	return queryMap
+
+	if err != nil {
+		message := fmt.Sprintf("failed to validate the event log; %s", err)
+		reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+		return false
+	}
+
+	reply(w, http.StatusUnprocessableEntity, validation.Response(), app.logger)
+	return false
 }
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// swagger:operation POST /batches postBatch
+//
+// Submit many jobs at once. A JSON request lists the jobs like JSON requests to POST /jobs in "jobs". Other requests
+// carry a zip archive of event logs like an event log for POST /jobs, as the whole body with the settings in the query
+// string or in the "event_log" part of a multipart request with the settings in the other parts; every event log in
+// the archive becomes a job with the same settings, e.g., the column mapping. The "callback_endpoint" is the batch's,
+// it's sent a POST request with the batch's status and progress once all its jobs have finished. A batch is "pending"
+// until a job starts, "running" until all jobs have finished, then "completed", or "failed" if a job has failed or has
+// been deleted. Either all jobs are queued or none, the jobs are checked like for POST /jobs. Event logs given by URL
+// count against the owner's storage quota only once they've been downloaded, a job over the quota fails then.
+//
+// ---
+// Consumes:
+//   - application/json
+//   - application/zip
+//   - multipart/form-data
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: body
+//     in: body
+//     description: Jobs of the batch or a zip archive of event logs
+//     required: true
+//     schema:
+//     $ref: '#/definitions/ApiBatchRequest'
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	201:
+//	  schema:
+//	    $ref: '#/definitions/ApiBatchResponse'
+//	422:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseValidationError'
+func PostBatch(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner := app.requestOwner(r)
+
+		var (
+			jobs     []*model.Job
+			callback string
+			err      error
+		)
+		if r.Header.Get("Content-Type") == "application/json" {
+			jobs, callback, err = app.newJobsFromBatchRequest(r)
+			if err == nil {
+				// the sizes of the downloaded event logs aren't known yet, the storage quota is checked again once
+				// they've been downloaded, see processJob
+				if err = app.admitJob(owner, 0); err != nil {
+					replyAdmissionError(app, w, err, "failed to admit the batch")
+					return
+				}
+			}
+		} else {
+			// the archive's size is known before reading it unless it's chunked or compressed
+			size := r.ContentLength
+			if limit := app.config.UploadMaxSize; limit > 0 && size > limit && r.Header.Get("Content-Encoding") == "" {
+				message := fmt.Sprintf("%s, the limit is %d bytes", errEventLogTooLarge, limit)
+				reply(w, http.StatusRequestEntityTooLarge, model.ApiResponseError{Error: message}, app.logger)
+				return
+			}
+			if err = app.admitJob(owner, size); err != nil {
+				replyAdmissionError(app, w, err, "failed to admit the batch")
+				return
+			}
+
+			jobs, callback, err = app.newJobsFromArchive(r)
+			if err == nil {
+				var size int64
+				for _, job := range jobs {
+					size += dirSize(job.Dir)
+				}
+				if err = app.checkStorageQuota(owner, size); err != nil {
+					app.removeJobDirs(jobs)
+					replyAdmissionError(app, w, err, "failed to admit the batch")
+					return
+				}
+			}
+		}
+
+		var validation *eventLogValidation
+		if errors.As(err, &validation) {
+			response := validation.Response()
+			response.Error = fmt.Sprintf("invalid batch; %s", err)
+			reply(w, http.StatusUnprocessableEntity, response, app.logger)
+			return
+		} else if errors.Is(err, errEventLogTooLarge) {
+			reply(w, http.StatusRequestEntityTooLarge, model.ApiResponseError{Error: err.Error()}, app.logger)
+			return
+		} else if err != nil {
+			message := fmt.Sprintf("invalid batch; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		batchID, err := uuid.NewUUID()
+		if err != nil {
+			app.removeJobDirs(jobs)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: err.Error()}, app.logger)
+			return
+		}
+		batch := &model.Batch{
+			ID:        batchID.String(),
+			Owner:     owner,
+			CreatedAt: time.Now(),
+		}
+		if callback != "" {
+			callbackURL, err := url.Parse(callback)
+			if err != nil {
+				app.removeJobDirs(jobs)
+				message := fmt.Sprintf("invalid batch; invalid callback_endpoint: %s", err)
+				reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+				return
+			}
+			batch.CallbackEndpoint = callback
+			batch.CallbackEndpointURL = &model.URL{URL: callbackURL}
+		}
+		for _, job := range jobs {
+			job.Owner = owner
+			job.BatchID = batch.ID
+			batch.JobIDs = append(batch.JobIDs, job.ID)
+		}
+
+		if err = app.AddBatch(batch, jobs...); err != nil {
+			app.removeJobDirs(jobs)
+			replyAdmissionError(app, w, err, "failed to add the batch to the queue")
+			return
+		}
+
+		reply(w, http.StatusCreated, app.batchResponse(r, batch), app.logger)
+	}
+}
+
+// newJobsFromBatchRequest creates the jobs of a JSON request to POST /batches and returns them with the batch's
+// callback endpoint.
+func (app *Application) newJobsFromBatchRequest(r *http.Request) ([]*model.Job, string, error) {
+	var request model.ApiBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, "", fmt.Errorf("invalid request body; %s", err)
+	}
+	_ = r.Body.Close()
+
+	if len(request.Jobs) == 0 {
+		return nil, "", fmt.Errorf("batch has no jobs")
+	}
+
+	jobs := make([]*model.Job, 0, len(request.Jobs))
+	for i, apiRequest := range request.Jobs {
+		if apiRequest == nil {
+			return nil, "", fmt.Errorf("job %d is null", i)
+		}
+		job, err := app.newJobFromApiRequest(r, apiRequest)
+		if err == nil {
+			err = app.checkDependencies(job)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("job %d: %w", i, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, request.CallbackEndpoint, nil
+}
+
+// swagger:operation GET /batches/{id} getBatch
+//
+// Get a batch with its status, the progress of its jobs by their status and the jobs.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Batch's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/ApiBatchResponse'
+func GetBatchByID(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		batch := app.queue.FindBatchByID(id)
+		if batch == nil {
+			message := fmt.Sprintf("batch with id %s not found", id)
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		reply(w, http.StatusOK, app.batchResponse(r, batch), app.logger)
+	}
+}
+
+// batchResponse returns the batch with its status, progress and jobs.
+func (app *Application) batchResponse(r *http.Request, batch *model.Batch) model.ApiBatchResponse {
+	summary, jobs := app.summarizeBatch(batch)
+	return model.ApiBatchResponse{Batch: summary, Jobs: app.links(r).jobs(jobs)}
+}
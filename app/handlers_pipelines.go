@@ -0,0 +1,112 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// swagger:operation POST /pipelines postPipeline
+//
+// Submit jobs which depend on each other in one call. Every job is given like in a JSON request to POST /jobs with a
+// "name" unique within the pipeline. A job's "depends_on" lists the names of the jobs of the pipeline, or IDs of jobs in
+// the queue, which have to complete before the job runs. A job with "input_from", one of its "depends_on", has no event
+// log of its own and analyses the event log of that job after its normalization and preprocessing, e.g., a waiting time
+// analysis and a calendar discovery of a filtered event log. A job whose dependency fails, or is deleted, fails as well
+// and so do its own dependents. Either all jobs are queued or none; the pipeline is rejected if its jobs depend on each
+// other in a cycle.
+//
+// ---
+// Consumes:
+//   - application/json
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: body
+//     in: body
+//     description: Jobs of the pipeline
+//     required: true
+//     schema:
+//     $ref: '#/definitions/ApiPipelineRequest'
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	201:
+//	  schema:
+//	    $ref: '#/definitions/ApiPipelineResponse'
+func PostPipeline(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request model.ApiPipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			message := fmt.Sprintf("invalid request body; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+		_ = r.Body.Close()
+
+		sorted, err := sortPipeline(request.Jobs)
+		if err != nil {
+			message := fmt.Sprintf("invalid pipeline; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		// the jobs are created after the ones they depend on, so that the names can be replaced with the IDs
+		jobs := make([]*model.Job, 0, len(sorted))
+		jobsByName := map[string]*model.Job{}
+		for _, pipelineJob := range sorted {
+			job, err := app.newJobFromApiRequest(r, &pipelineJob.ApiRequest)
+			if err == nil {
+				err = resolvePipelineDependencies(app, job, jobsByName)
+			}
+			if err != nil {
+				message := fmt.Sprintf("invalid pipeline; job %q: %s", pipelineJob.Name, err)
+				reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+				return
+			}
+
+			jobs = append(jobs, job)
+			jobsByName[pipelineJob.Name] = job
+		}
+
+		// the sizes of the downloaded event logs aren't known yet
+		if err = app.admitJob(app.requestOwner(r), 0); err != nil {
+			replyAdmissionError(app, w, err, "failed to admit the pipeline")
+			return
+		}
+
+		if err = app.AddJobs(jobs...); err != nil {
+			replyAdmissionError(app, w, err, "failed to add the pipeline to the queue")
+			return
+		}
+
+		apiResponse := model.ApiPipelineResponse{Jobs: map[string]*model.Job{}}
+		for name, job := range jobsByName {
+			apiResponse.Jobs[name] = app.links(r).job(job)
+		}
+		reply(w, http.StatusCreated, apiResponse, app.logger)
+	}
+}
+
+// resolvePipelineDependencies replaces the names of the pipeline's jobs the job depends on with their IDs, the other
+// dependencies have to be in the queue.
+func resolvePipelineDependencies(app *Application, job *model.Job, jobsByName map[string]*model.Job) error {
+	for i, dependency := range job.DependsOn {
+		if parent, ok := jobsByName[dependency]; ok {
+			job.DependsOn[i] = parent.ID
+		} else if app.queue.FindByID(dependency) == nil {
+			return fmt.Errorf("dependency %s not found", dependency)
+		}
+	}
+	if parent, ok := jobsByName[job.InputFrom]; ok {
+		job.InputFrom = parent.ID
+	}
+	return nil
+}
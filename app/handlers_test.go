@@ -18,6 +18,8 @@ func makeTestApplication() (*Application, error) {
 	config := DefaultConfiguration()
 	config.AssetsDir = "../assets"
 	config.ResultsDir = "../assets/results"
+	config.UploadsDir = "../assets/uploads"
+	config.AuditLogPath = path.Join(os.TempDir(), "waiting-time-backend-audit.log")
 	return NewApplication(config)
 }
 
@@ -0,0 +1,520 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tusVersion is the version of the tus resumable upload protocol (https://tus.io) the upload endpoints follow.
+const tusVersion = "1.0.0"
+
+// uploadRequest is an optional JSON body for POST /uploads. The same settings can be passed in tus headers instead.
+// The settings of the job are the ones of an event log uploaded to POST /jobs.
+type uploadRequest struct {
+	Length   int64  `json:"length"`
+	FileName string `json:"filename"`
+	jobUpload
+}
+
+// swagger:operation POST /uploads createUpload
+//
+// Create a resumable upload session for a large event log. The size of the log is given in the "Upload-Length" header
+// or in the "length" field of a JSON body. The file name and the settings of the job, the same as with an event log
+// uploaded to POST /jobs, can be given in the "Upload-Metadata" header as comma-separated keys with base64-encoded
+// values or in the JSON body. The session's URL is returned in the "Location" header. Sessions are subject to the
+// limits of GET /usage like the jobs.
+//
+// ---
+// Consumes:
+//   - application/json
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: Upload-Length
+//     in: header
+//     description: Size of the event log in bytes
+//     required: false
+//     type: integer
+//   - name: Upload-Metadata
+//     in: header
+//     description: Comma-separated pairs of a key and a base64-encoded value
+//     required: false
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	201:
+//	  schema:
+//	    $ref: '#/definitions/Upload'
+//	422:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseValidationError'
+func CreateUpload(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request uploadRequest
+
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				message := fmt.Sprintf("invalid request body; %s", err)
+				reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+				return
+			}
+			_ = r.Body.Close()
+		}
+
+		if header := r.Header.Get("Upload-Length"); header != "" {
+			length, err := strconv.ParseInt(header, 10, 64)
+			if err != nil {
+				message := fmt.Sprintf("invalid Upload-Length header; %s", err)
+				reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+				return
+			}
+			request.Length = length
+		}
+
+		if err := request.parseMetadata(r.Header.Get("Upload-Metadata")); err != nil {
+			message := fmt.Sprintf("invalid Upload-Metadata header; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		if validation := validateColumnMapping(request.ColumnMapping); !validation.Valid() {
+			reply(w, http.StatusUnprocessableEntity, validation.Response(), app.logger)
+			return
+		}
+
+		if err := app.checkUploadSettings(&request.jobUpload); err != nil {
+			message := fmt.Sprintf("invalid upload; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		if request.Length <= 0 {
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: "upload length must be positive"}, app.logger)
+			return
+		}
+
+		if app.config.UploadMaxSize > 0 && request.Length > app.config.UploadMaxSize {
+			message := fmt.Sprintf("event log is too large, the limit is %d bytes", app.config.UploadMaxSize)
+			reply(w, http.StatusRequestEntityTooLarge, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		owner := app.requestOwner(r)
+		if err := app.admitUpload(owner, request.Length); err != nil {
+			replyAdmissionError(app, w, err, "failed to admit the upload")
+			return
+		}
+
+		upload := &model.Upload{
+			Length:           request.Length,
+			FileName:         sanitizeFileName(request.FileName),
+			CallbackEndpoint: request.CallbackEndpoint,
+			ColumnMapping:    request.ColumnMapping,
+			EventLogFormat:   request.EventLogFormat,
+			OCELObjectType:   request.OCELObjectType,
+			AutoMap:          request.AutoMap,
+			Preprocessing:    request.Preprocessing,
+			Timezone:         request.Timezone,
+			Force:            request.Force,
+			RetainUntil:      request.RetainUntil,
+			Pinned:           request.Pinned,
+			Kind:             request.Kind,
+			Params:           request.Params,
+			Owner:            owner,
+		}
+		if err := app.uploads.Create(upload); err != nil {
+			message := fmt.Sprintf("failed to create an upload; %s", err)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		w.Header().Set("Location", app.links(r).resolve("/uploads/"+upload.ID).String())
+		setUploadHeaders(w, upload)
+		reply(w, http.StatusCreated, upload, app.logger)
+	}
+}
+
+// swagger:operation HEAD /uploads/{id} headUpload
+//
+// Get the number of received bytes of an upload in the "Upload-Offset" header. A client should call it after
+// a dropped connection to find out where to continue from.
+//
+// ---
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Upload's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	200:
+//	  description: The upload's offset and length are in the Upload-Offset and Upload-Length headers
+//	404:
+//	  description: Upload not found
+func HeadUpload(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upload := app.uploads.Get(mux.Vars(r)["id"])
+		if upload == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		setUploadHeaders(w, upload)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// swagger:operation GET /uploads/{id} getUpload
+//
+// Get an upload session.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Upload's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/Upload'
+func GetUpload(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		upload := app.uploads.Get(id)
+		if upload == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("upload with id %s not found", id)}, app.logger)
+			return
+		}
+
+		setUploadHeaders(w, upload)
+		reply(w, http.StatusOK, upload.Copy(), app.logger)
+	}
+}
+
+// swagger:operation PATCH /uploads/{id} patchUpload
+//
+// Append a chunk to an upload. The chunk's position is given in the "Upload-Offset" header, or in the "Content-Range"
+// header when the PUT method is used, and must be equal to the number of bytes received so far. The new offset is
+// returned in the "Upload-Offset" header.
+//
+// ---
+// Consumes:
+//   - application/offset+octet-stream
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Upload's ID
+//     required: true
+//     type: string
+//   - name: Upload-Offset
+//     in: header
+//     description: Position of the chunk in the event log
+//     required: true
+//     type: integer
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	204:
+//	  description: The chunk has been stored
+//	409:
+//	  description: The offset doesn't match the number of received bytes
+func PatchUpload(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		upload := app.uploads.Get(id)
+		if upload == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("upload with id %s not found", id)}, app.logger)
+			return
+		}
+
+		offset, err := chunkOffset(r)
+		if err != nil {
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: err.Error()}, app.logger)
+			return
+		}
+
+		if !upload.TryLock() {
+			reply(w, http.StatusLocked, model.ApiResponseError{Error: "another chunk of the upload is being written"}, app.logger)
+			return
+		}
+		defer upload.Unlock()
+
+		if current := upload.CurrentOffset(); offset != current {
+			setUploadHeaders(w, upload)
+			message := fmt.Sprintf("offset %d doesn't match the upload's offset %d", offset, current)
+			reply(w, http.StatusConflict, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		_, err = app.uploads.Write(upload, offset, r.ContentLength, r.Body)
+		setUploadHeaders(w, upload)
+		if err != nil {
+			message := fmt.Sprintf("failed to write the chunk; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// swagger:operation POST /uploads/{id}/finalize finalizeUpload
+//
+// Turn a complete upload into a job and submit it for analysis. An invalid event log is rejected with 422 and the list
+// of problems. The upload is removed once the job has been queued, it can be finalized again after a failure.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Upload's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	201:
+//	  schema:
+//	    $ref: '#/definitions/ApiSingleJobResponse'
+//	422:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseValidationError'
+func FinalizeUpload(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		upload := app.uploads.Get(id)
+		if upload == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("upload with id %s not found", id)}, app.logger)
+			return
+		}
+
+		if !upload.TryLock() {
+			reply(w, http.StatusLocked, model.ApiResponseError{Error: "a chunk of the upload is being written"}, app.logger)
+			return
+		}
+		defer upload.Unlock()
+
+		if !upload.Complete() {
+			message := fmt.Sprintf("upload is incomplete, received %d of %d bytes", upload.CurrentOffset(), upload.Length)
+			reply(w, http.StatusConflict, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		// the upload is kept while the owner has too many pending jobs, so that it can be finalized later
+		if err := app.checkPendingJobs(upload.Owner, 1); err != nil {
+			replyAdmissionError(app, w, err, "failed to admit the job")
+			return
+		}
+
+		// the upload keeps its data until the job has been queued, so that it can be finalized again after a failure
+		job, err := app.newJobFromUploadSession(upload)
+		if err != nil {
+			message := fmt.Sprintf("failed to create a job from the upload; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		if !checkEventLog(app, w, job) {
+			return
+		}
+
+		if err = job.Validate(); err != nil {
+			app.removeJobDirs([]*model.Job{job})
+			message := fmt.Sprintf("invalid job; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		if err = app.AddJob(job); err != nil {
+			replyAddJobError(app, w, job, err)
+			return
+		}
+
+		if err = app.uploads.Remove(upload); err != nil {
+			app.logger.Printf("error removing upload %s: %s", upload.ID, err.Error())
+		}
+
+		apiResponse := model.ApiSingleJobResponse{Job: app.links(r).job(job)}
+		reply(w, http.StatusCreated, apiResponse, app.logger)
+	}
+}
+
+// swagger:operation DELETE /uploads/{id} deleteUpload
+//
+// Abort an upload and remove the received data.
+//
+// ---
+// Parameters:
+//   - name: id
+//     in: path
+//     description: Upload's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	204:
+//	  description: The upload has been removed
+func DeleteUpload(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		upload := app.uploads.Get(id)
+		if upload == nil {
+			reply(w, http.StatusNotFound, model.ApiResponseError{Error: fmt.Sprintf("upload with id %s not found", id)}, app.logger)
+			return
+		}
+
+		if !upload.TryLock() {
+			reply(w, http.StatusLocked, model.ApiResponseError{Error: "a chunk of the upload is being written"}, app.logger)
+			return
+		}
+		defer upload.Unlock()
+
+		if err := app.uploads.Remove(upload); err != nil {
+			message := fmt.Sprintf("failed to remove the upload; %s", err)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func setUploadHeaders(w http.ResponseWriter, upload *model.Upload) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.CurrentOffset(), 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// chunkOffset reads the chunk's position from the Upload-Offset header or from the Content-Range header in the
+// "bytes first-last/length" form.
+func chunkOffset(r *http.Request) (int64, error) {
+	if header := r.Header.Get("Upload-Offset"); header != "" {
+		offset, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid Upload-Offset header; %s", err)
+		}
+		return offset, nil
+	}
+
+	if header := r.Header.Get("Content-Range"); header != "" {
+		offset, ok := contentRangeStart(header)
+		if !ok {
+			return 0, fmt.Errorf("invalid Content-Range header %q", header)
+		}
+		return offset, nil
+	}
+
+	return 0, fmt.Errorf("Upload-Offset or Content-Range header is required")
+}
+
+// parseMetadata reads the tus Upload-Metadata header.
+func (request *uploadRequest) parseMetadata(header string) error {
+	if header == "" {
+		return nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return fmt.Errorf("malformed pair %q", pair)
+		}
+
+		var value []byte
+		if len(fields) == 2 {
+			var err error
+			if value, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+				return fmt.Errorf("value of %s is not base64-encoded", fields[0])
+			}
+		}
+
+		switch fields[0] {
+		case "filename", "name":
+			request.FileName = string(value)
+		case "callback_endpoint":
+			request.CallbackEndpoint = string(value)
+		case "column_mapping":
+			if err := json.Unmarshal(value, &request.ColumnMapping); err != nil {
+				return fmt.Errorf("column_mapping is invalid: %s", err)
+			}
+		case "event_log_format":
+			request.EventLogFormat = string(value)
+		case "ocel_object_type":
+			request.OCELObjectType = string(value)
+		case "timezone":
+			request.Timezone = string(value)
+		case "kind":
+			request.Kind = model.AnalysisKind(value)
+		case "auto_map", "force", "pinned":
+			v, err := strconv.ParseBool(string(value))
+			if err != nil {
+				return fmt.Errorf("%s is not a boolean: %s", fields[0], err)
+			}
+			switch fields[0] {
+			case "force":
+				request.Force = v
+			case "pinned":
+				request.Pinned = v
+			default:
+				request.AutoMap = v
+			}
+		case "retain_until":
+			t, err := time.Parse(time.RFC3339, string(value))
+			if err != nil {
+				return fmt.Errorf("retain_until is invalid: %s", err)
+			}
+			request.RetainUntil = &t
+		case "preprocessing":
+			if err := json.Unmarshal(value, &request.Preprocessing); err != nil {
+				return fmt.Errorf("preprocessing is invalid: %s", err)
+			}
+		case "params":
+			request.Params = value
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,335 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/gorilla/mux"
+)
+
+// workerRoutes returns the routes of the remote workers' protocol, they're served with Configuration.RemoteWorkers.
+func (app *Application) workerRoutes() Routes {
+	return Routes{
+		Route{"PostWorkerLease", "POST", "/workers/leases", "", workerAuth(app, PostWorkerLease(app))},
+		Route{"PostWorkerHeartbeat", "POST", "/workers/leases/{id}/heartbeat", "", workerAuth(app, PostWorkerHeartbeat(app))},
+		Route{"GetWorkerEventLog", "GET", "/workers/leases/{id}/event-log", "", workerAuth(app, GetWorkerEventLog(app))},
+		Route{"PutWorkerFile", "PUT", "/workers/leases/{id}/files/{name}", "", workerAuth(app, PutWorkerFile(app))},
+		Route{"PostWorkerCompletion", "POST", "/workers/leases/{id}/completion", "", workerAuth(app, PostWorkerCompletion(app))},
+	}
+}
+
+// workerAuth lets only the requests with the worker token through.
+func workerAuth(app *Application, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := []byte("Bearer " + app.config.WorkerToken)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) != 1 {
+			reply(w, http.StatusUnauthorized, model.ApiResponseError{Error: "invalid worker token"}, app.logger)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// replyLeaseError replies with 410 if the lease is gone, the worker has to stop its analysis then.
+func replyLeaseError(app *Application, w http.ResponseWriter, id string, err error) {
+	if err == errLeaseGone {
+		message := fmt.Sprintf("lease %s has expired, been completed or its job has been cancelled", id)
+		reply(w, http.StatusGone, model.ApiResponseError{Error: message}, app.logger)
+		return
+	}
+	reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: err.Error()}, app.logger)
+}
+
+// swagger:operation POST /workers/leases postWorkerLease
+//
+// Lease the analysis of the first waiting job. The worker downloads the event log, runs the analysis while sending
+// heartbeats every heartbeat_interval seconds, uploads the files the analysis has written and completes the lease.
+// Replies with 204 if there's no analysis to run. The worker endpoints are served only to the workers of a server with
+// remote workers, they authenticate with the worker token as a bearer token.
+//
+// ---
+// Consumes:
+//   - application/json
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: Authorization
+//     in: header
+//     description: Bearer token of the workers
+//     required: true
+//     type: string
+//   - name: body
+//     in: body
+//     description: Worker's request
+//     required: false
+//     schema:
+//     $ref: '#/definitions/LeaseRequest'
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	201:
+//	  schema:
+//	    $ref: '#/definitions/Lease'
+//	204:
+//	  description: No analysis is waiting
+func PostWorkerLease(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request model.LeaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			message := fmt.Sprintf("invalid request body; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+		if request.WorkerID == "" {
+			request.WorkerID = r.RemoteAddr
+		}
+
+		lease := app.leases.Lease(request.WorkerID)
+		if lease == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		app.logger.Printf("Job %s leased to worker %s, attempt %d", lease.JobID, lease.WorkerID, lease.Attempt)
+		reply(w, http.StatusCreated, lease, app.logger)
+	}
+}
+
+// swagger:operation POST /workers/leases/{id}/heartbeat postWorkerHeartbeat
+//
+// Renew a lease. Replies with 410 if the lease has expired, has been completed or its job has been cancelled, the
+// worker has to stop the analysis then.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: Authorization
+//     in: header
+//     description: Bearer token of the workers
+//     required: true
+//     type: string
+//   - name: id
+//     in: path
+//     description: Lease's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  schema:
+//	    $ref: '#/definitions/Lease'
+func PostWorkerHeartbeat(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		lease, err := app.leases.Heartbeat(id)
+		if err != nil {
+			replyLeaseError(app, w, id, err)
+			return
+		}
+
+		reply(w, http.StatusOK, lease, app.logger)
+	}
+}
+
+// swagger:operation GET /workers/leases/{id}/event-log getWorkerEventLog
+//
+// Download the event log of a lease's job.
+//
+// ---
+// Produces:
+//   - application/octet-stream
+//
+// Parameters:
+//   - name: Authorization
+//     in: header
+//     description: Bearer token of the workers
+//     required: true
+//     type: string
+//   - name: id
+//     in: path
+//     description: Lease's ID
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	200:
+//	  description: Event log
+func GetWorkerEventLog(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		lease, job, err := app.leases.Get(id)
+		if err != nil {
+			replyLeaseError(app, w, id, err)
+			return
+		}
+
+		http.ServeFile(w, r, path.Join(job.Dir, lease.EventLogName))
+	}
+}
+
+// swagger:operation PUT /workers/leases/{id}/files/{name} putWorkerFile
+//
+// Upload a file the analysis has written into the job's directory. Existing files are replaced, except the event log,
+// which can't be.
+//
+// ---
+// Consumes:
+//   - application/octet-stream
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: Authorization
+//     in: header
+//     description: Bearer token of the workers
+//     required: true
+//     type: string
+//   - name: id
+//     in: path
+//     description: Lease's ID
+//     required: true
+//     type: string
+//   - name: name
+//     in: path
+//     description: File's name
+//     required: true
+//     type: string
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	204:
+//	  description: File has been stored
+func PutWorkerFile(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		name := mux.Vars(r)["name"]
+
+		lease, job, err := app.leases.Get(id)
+		if err != nil {
+			replyLeaseError(app, w, id, err)
+			return
+		}
+
+		if name != sanitizeFileName(name) || name == lease.EventLogName {
+			message := fmt.Sprintf("invalid file name %q", name)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		// the file is written under a temporary name, so that a broken upload doesn't leave a partial file behind
+		f, err := os.CreateTemp(job.Dir, "."+name+"-*")
+		if err != nil {
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: err.Error()}, app.logger)
+			return
+		}
+		_, err = io.Copy(f, r.Body)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), path.Join(job.Dir, name))
+		}
+		if err != nil {
+			_ = os.Remove(f.Name())
+			message := fmt.Sprintf("failed to store the file; %s", err)
+			reply(w, http.StatusInternalServerError, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// swagger:operation POST /workers/leases/{id}/completion postWorkerCompletion
+//
+// Complete a lease after the files have been uploaded, with the error if the analysis has failed. The job is
+// processed further like after a local analysis.
+//
+// ---
+// Consumes:
+//   - application/json
+//
+// Produces:
+//   - application/json
+//
+// Parameters:
+//   - name: Authorization
+//     in: header
+//     description: Bearer token of the workers
+//     required: true
+//     type: string
+//   - name: id
+//     in: path
+//     description: Lease's ID
+//     required: true
+//     type: string
+//   - name: body
+//     in: body
+//     description: Result of the analysis
+//     required: true
+//     schema:
+//     $ref: '#/definitions/LeaseCompletion'
+//
+// Responses:
+//
+//	default:
+//	  schema:
+//	    $ref: '#/definitions/ApiResponseError'
+//	204:
+//	  description: Lease has been completed
+func PostWorkerCompletion(app *Application) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		var completion model.LeaseCompletion
+		if err := json.NewDecoder(r.Body).Decode(&completion); err != nil {
+			message := fmt.Sprintf("invalid request body; %s", err)
+			reply(w, http.StatusBadRequest, model.ApiResponseError{Error: message}, app.logger)
+			return
+		}
+
+		lease, _, err := app.leases.Get(id)
+		if err != nil {
+			replyLeaseError(app, w, id, err)
+			return
+		}
+
+		var result error
+		if completion.Error != "" {
+			result = fmt.Errorf("error executing analysis on worker %s: %s", lease.WorkerID, completion.Error)
+		}
+		if err = app.leases.Complete(id, result); err != nil {
+			replyLeaseError(app, w, id, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
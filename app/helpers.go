@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"path"
 )
@@ -54,6 +53,29 @@ func readJSON(path string, data interface{}, logger *log.Logger) error {
 	return json.NewDecoder(f).Decode(data)
 }
 
+func dumpJSON(path string, data interface{}, logger *log.Logger) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.Printf("error closing file: %s", err.Error())
+		}
+	}()
+
+	return json.NewEncoder(f).Encode(data)
+}
+
+// linkFile makes dst a hard link to src, or a copy of it if they're on different file systems. The event logs are
+// never changed in place, they're replaced, so src keeps its content.
+func linkFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return copyRegularFile(src, dst)
+}
+
 func mkdir(path string) error {
 	if _, err := os.Open(path); os.IsNotExist(err) {
 		return os.MkdirAll(path, 0777)
@@ -72,31 +94,6 @@ func abspath(p string) (string, error) {
 	return p, nil
 }
 
-func download(url string, path string, logger *log.Logger) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			logger.Printf("error closing response body: %s", err.Error())
-		}
-	}()
-
-	out, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			logger.Printf("error closing file: %s", err.Error())
-		}
-	}()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
-
 func md5sum(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -0,0 +1,433 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+const (
+	// inspectSampleRows is the number of rows read to profile an event log.
+	inspectSampleRows = 1000
+	// inspectMinScore is the lowest score of a column to be proposed for a role.
+	inspectMinScore = 0.3
+	// inspectTimestampRatio is the share of values which must be timestamps for a column to be a timestamp column.
+	inspectTimestampRatio = 0.9
+)
+
+// inspectRoles are the roles of a column mapping in the order they're reported.
+var inspectRoles = []string{
+	model.ColumnMappingCase,
+	model.ColumnMappingActivity,
+	model.ColumnMappingResource,
+	model.ColumnMappingStartTimestamp,
+	model.ColumnMappingEndTimestamp,
+}
+
+// inspectHeaderNames score normalized column names for every role. Names containing one of inspectHeaderParts get a
+// lower score.
+var inspectHeaderNames = map[string]map[string]float64{
+	model.ColumnMappingCase: {
+		"case:concept:name": 1, "case_id": 0.95, "caseid": 0.95, "case id": 0.95, "case": 0.9, "case:id": 0.9,
+		"trace_id": 0.8, "traceid": 0.8, "trace": 0.7, "process instance": 0.7,
+	},
+	model.ColumnMappingActivity: {
+		"concept:name": 1, "activity": 0.95, "activity_name": 0.95, "activity name": 0.95, "activity_id": 0.8,
+		"task": 0.8, "event": 0.7, "event_name": 0.7, "action": 0.7, "step": 0.6,
+	},
+	model.ColumnMappingResource: {
+		"org:resource": 1, "resource": 0.95, "resource_id": 0.9, "user": 0.8, "performer": 0.8, "employee": 0.7,
+		"agent": 0.7, "org:role": 0.5, "role": 0.5,
+	},
+	model.ColumnMappingStartTimestamp: {
+		"start_timestamp": 1, "start_time": 0.95, "starttime": 0.95, "start timestamp": 0.95, "start": 0.9,
+		"time:start": 0.9, "started_at": 0.9,
+	},
+	model.ColumnMappingEndTimestamp: {
+		"time:timestamp": 1, "end_timestamp": 0.95, "end_time": 0.95, "endtime": 0.95, "end timestamp": 0.95,
+		"complete_timestamp": 0.95, "end": 0.9, "completed_at": 0.9, "timestamp": 0.6,
+	},
+}
+
+var inspectHeaderParts = map[string][]string{
+	model.ColumnMappingCase:           {"case"},
+	model.ColumnMappingActivity:       {"activity"},
+	model.ColumnMappingResource:       {"resource"},
+	model.ColumnMappingStartTimestamp: {"start"},
+	model.ColumnMappingEndTimestamp:   {"end", "complete"},
+}
+
+// inspectTimestampFormats are tried for timestamp columns which aren't in ISO 8601.
+var inspectTimestampFormats = []string{
+	"%Y/%m/%d %H:%M:%S",
+	"%d/%m/%Y %H:%M:%S",
+	"%m/%d/%Y %H:%M:%S",
+	"%d.%m.%Y %H:%M:%S",
+	"%d-%m-%Y %H:%M:%S",
+	"%Y/%m/%d %H:%M",
+	"%d/%m/%Y %H:%M",
+	"%m/%d/%Y %H:%M",
+	"%d.%m.%Y %H:%M",
+	"%d-%m-%Y %H:%M",
+	"%Y-%m-%d %H:%M",
+	"%Y-%m-%d",
+	"%d/%m/%Y",
+	"%m/%d/%Y",
+	"%d.%m.%Y",
+}
+
+// columnSample holds the sampled values of a column.
+type columnSample struct {
+	profile *model.ColumnProfile
+	values  []string
+	times   []time.Time // parsed timestamps, zero for values which aren't timestamps
+}
+
+// inspectEventLog profiles a sample of the CSV event log at filePath and proposes a column mapping. Every column gets a
+// score for every role, combined from the header name and the values: timestamp roles need timestamps, the case
+// column must group several events, activities and resources have a low cardinality. The roles are then assigned
+// greedily by the best score, one column per role.
+func inspectEventLog(filePath string) (*model.EventLogInspection, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("event log is empty")
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %s", err.Error())
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\xef\xbb\xbf")
+	}
+
+	samples := make([]*columnSample, len(header))
+	for i, name := range header {
+		samples[i] = &columnSample{profile: &model.ColumnProfile{Name: strings.TrimSpace(name)}}
+	}
+
+	rows := 0
+	for rows < inspectSampleRows {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %s", err.Error())
+		}
+		rows++
+
+		for i, sample := range samples {
+			value := ""
+			if i < len(record) {
+				value = strings.TrimSpace(record[i])
+			}
+			sample.values = append(sample.values, value)
+		}
+	}
+
+	for _, sample := range samples {
+		profileColumn(sample)
+	}
+
+	inspection := &model.EventLogInspection{
+		EventLogFormat: formatCSV,
+		SampledRows:    rows,
+		ColumnMapping:  &model.ColumnMapping{},
+		Confidence:     map[string]float64{},
+		Candidates:     map[string][]*model.ColumnCandidate{},
+	}
+	for _, sample := range samples {
+		inspection.Columns = append(inspection.Columns, sample.profile)
+	}
+
+	type candidate struct {
+		role   string
+		column int
+		score  float64
+	}
+	var candidates []candidate
+
+	for _, role := range inspectRoles {
+		for i, sample := range samples {
+			score := headerScore(role, sample.profile.Name) * 0.6
+			value := valueScore(role, sample)
+			if value == 0 {
+				continue
+			}
+			score += value * 0.4
+			if score < inspectMinScore {
+				continue
+			}
+
+			candidates = append(candidates, candidate{role: role, column: i, score: score})
+			inspection.Candidates[role] = append(inspection.Candidates[role], &model.ColumnCandidate{
+				Column: sample.profile.Name,
+				Score:  roundScore(score),
+			})
+		}
+
+		sort.SliceStable(inspection.Candidates[role], func(i, j int) bool {
+			return inspection.Candidates[role][i].Score > inspection.Candidates[role][j].Score
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	assigned := map[string]int{}
+	usedColumns := map[int]bool{}
+	for _, c := range candidates {
+		if _, ok := assigned[c.role]; ok || usedColumns[c.column] {
+			continue
+		}
+		assigned[c.role] = c.column
+		usedColumns[c.column] = true
+		inspection.Confidence[c.role] = roundScore(c.score)
+	}
+
+	start, hasStart := assigned[model.ColumnMappingStartTimestamp]
+	end, hasEnd := assigned[model.ColumnMappingEndTimestamp]
+	switch {
+	case hasStart && hasEnd:
+		// the names can be misleading, events mostly start before they end
+		if startsAfterEnds(samples[start], samples[end]) {
+			assigned[model.ColumnMappingStartTimestamp], assigned[model.ColumnMappingEndTimestamp] = end, start
+			inspection.Confidence[model.ColumnMappingStartTimestamp] = roundScore(inspection.Confidence[model.ColumnMappingStartTimestamp] / 2)
+			inspection.Confidence[model.ColumnMappingEndTimestamp] = roundScore(inspection.Confidence[model.ColumnMappingEndTimestamp] / 2)
+		}
+	case hasStart != hasEnd:
+		// with a single timestamp column events are instantaneous
+		only, role, missing := start, model.ColumnMappingStartTimestamp, model.ColumnMappingEndTimestamp
+		if hasEnd {
+			only, role, missing = end, model.ColumnMappingEndTimestamp, model.ColumnMappingStartTimestamp
+		}
+		assigned[missing] = only
+		inspection.Confidence[missing] = roundScore(inspection.Confidence[role] / 2)
+	}
+
+	mapping := inspection.ColumnMapping
+	for role, i := range assigned {
+		name := samples[i].profile.Name
+		switch role {
+		case model.ColumnMappingCase:
+			mapping.Case = name
+		case model.ColumnMappingActivity:
+			mapping.Activity = name
+		case model.ColumnMappingResource:
+			mapping.Resource = name
+		case model.ColumnMappingStartTimestamp:
+			mapping.StartTimestamp = name
+		case model.ColumnMappingEndTimestamp:
+			mapping.EndTimestamp = name
+		}
+
+		if format := samples[i].profile.TimestampFormat; format != "" && (role == model.ColumnMappingStartTimestamp || role == model.ColumnMappingEndTimestamp) {
+			if mapping.TimestampFormats == nil {
+				mapping.TimestampFormats = map[string]string{}
+			}
+			mapping.TimestampFormats[name] = format
+		}
+	}
+
+	inspection.Complete = len(assigned) == len(inspectRoles)
+	return inspection, nil
+}
+
+// autoMapEventLog proposes a column mapping for the CSV event log at filePath and fails if some roles can't be mapped.
+func autoMapEventLog(filePath string) (*model.ColumnMapping, error) {
+	inspection, err := inspectEventLog(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !inspection.Complete {
+		var missing []string
+		for role, column := range inspection.ColumnMapping.Roles() {
+			if column == "" {
+				missing = append(missing, role)
+			}
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("column mapping can't be inferred, no column found for %s", strings.Join(missing, ", "))
+	}
+
+	return inspection.ColumnMapping, nil
+}
+
+func profileColumn(sample *columnSample) {
+	profile := sample.profile
+	distinct := map[string]bool{}
+	numeric, timestamps := 0, 0
+
+	sample.times = make([]time.Time, len(sample.values))
+
+	for i, value := range sample.values {
+		if value == "" {
+			profile.Empty++
+			continue
+		}
+
+		if !distinct[value] {
+			distinct[value] = true
+			if len(profile.Examples) < 3 {
+				profile.Examples = append(profile.Examples, value)
+			}
+		}
+
+		if t, err := parseEventLogTimestamp(value); err == nil {
+			sample.times[i] = t
+			timestamps++
+		} else if _, err := strconv.ParseFloat(value, 64); err == nil {
+			numeric++
+		}
+	}
+
+	profile.Distinct = len(distinct)
+
+	nonEmpty := len(sample.values) - profile.Empty
+	if nonEmpty == 0 {
+		return
+	}
+	profile.NumericRatio = roundScore(float64(numeric) / float64(nonEmpty))
+	profile.TimestampRatio = roundScore(float64(timestamps) / float64(nonEmpty))
+
+	if profile.TimestampRatio >= inspectTimestampRatio || numeric == nonEmpty {
+		return
+	}
+
+	for _, format := range inspectTimestampFormats {
+		layout, err := model.StrftimeLayout(format)
+		if err != nil {
+			continue
+		}
+
+		times := make([]time.Time, len(sample.values))
+		parsed := 0
+		for i, value := range sample.values {
+			if value == "" {
+				continue
+			}
+			if t, err := time.Parse(layout, value); err == nil {
+				times[i] = t
+				parsed++
+			}
+		}
+
+		if ratio := float64(parsed) / float64(nonEmpty); ratio >= inspectTimestampRatio {
+			profile.TimestampFormat = format
+			profile.TimestampRatio = roundScore(ratio)
+			sample.times = times
+			return
+		}
+	}
+}
+
+// headerScore scores a column name for a role. Columns with the "@@" prefix are derived by other tools, e.g.,
+// "@@startevent_concept:name", and only get a fraction of the score.
+func headerScore(role, name string) float64 {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	factor := 1.0
+	if strings.HasPrefix(name, "@@") {
+		factor = 0.3
+		if i := strings.Index(name, "_"); i >= 0 {
+			name = name[i+1:]
+		}
+	}
+
+	if score, ok := inspectHeaderNames[role][name]; ok {
+		return score * factor
+	}
+	for _, part := range inspectHeaderParts[role] {
+		if strings.Contains(name, part) {
+			return 0.6 * factor
+		}
+	}
+	return 0
+}
+
+// valueScore scores the sampled values of a column for a role. Zero means the column can't have the role.
+func valueScore(role string, sample *columnSample) float64 {
+	profile := sample.profile
+	nonEmpty := len(sample.values) - profile.Empty
+	if nonEmpty == 0 {
+		return 0
+	}
+
+	isTimestamp := profile.TimestampRatio >= inspectTimestampRatio
+	eventsPerValue := float64(nonEmpty) / float64(profile.Distinct)
+
+	switch role {
+	case model.ColumnMappingStartTimestamp, model.ColumnMappingEndTimestamp:
+		if !isTimestamp {
+			return 0
+		}
+		return 1
+
+	case model.ColumnMappingCase:
+		if isTimestamp || profile.Empty > 0 {
+			return 0
+		}
+		// a case groups several events, but a log isn't a single case
+		if profile.Distinct == 1 || eventsPerValue < 1.5 {
+			return 0.1
+		}
+		return 1
+
+	case model.ColumnMappingActivity:
+		if isTimestamp || profile.Empty > 0 {
+			return 0
+		}
+		if profile.NumericRatio > 0.9 || profile.Distinct == 1 || eventsPerValue < 2 {
+			return 0.2
+		}
+		return 1
+
+	case model.ColumnMappingResource:
+		if isTimestamp {
+			return 0
+		}
+		if profile.Distinct == 1 || eventsPerValue < 2 {
+			return 0.2
+		}
+		return math.Max(0.5, 1-float64(profile.Empty)/float64(len(sample.values)))
+	}
+
+	return 0
+}
+
+// startsAfterEnds reports whether the values of the start column are mostly after the ones of the end column.
+func startsAfterEnds(start, end *columnSample) bool {
+	after, before := 0, 0
+	for i := range start.times {
+		if start.times[i].IsZero() || end.times[i].IsZero() {
+			continue
+		}
+		if start.times[i].After(end.times[i]) {
+			after++
+		} else if start.times[i].Before(end.times[i]) {
+			before++
+		}
+	}
+	return after > before
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
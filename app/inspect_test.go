@@ -0,0 +1,205 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestInspectEventLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    *model.ColumnMapping
+	}{
+		{
+			name: "derived columns",
+			file: "../assets/samples/PurchasingExample.csv",
+			want: &model.ColumnMapping{
+				Case:           "case:concept:name",
+				Activity:       "concept:name",
+				Resource:       "org:resource",
+				StartTimestamp: "start_timestamp",
+				EndTimestamp:   "time:timestamp",
+			},
+		},
+		{
+			name: "custom names",
+			file: "../assets/samples/manual_log_5_columns.csv",
+			want: &model.ColumnMapping{
+				Case:           "case_id",
+				Activity:       "activity",
+				Resource:       "resource",
+				StartTimestamp: "start_timestamp",
+				EndTimestamp:   "end_timestamp",
+			},
+		},
+		{
+			name: "timestamp formats and swapped names",
+			content: "Ticket,Step,Done,Begin,Agent\n" +
+				"1,Open,16/05/2022 10:15,16/05/2022 10:00,Marcus\n" +
+				"1,Close,16/05/2022 12:30,16/05/2022 12:00,Anya\n" +
+				"2,Open,17/05/2022 09:20,17/05/2022 09:00,Anya\n" +
+				"2,Close,17/05/2022 11:00,17/05/2022 10:00,Marcus\n",
+			want: &model.ColumnMapping{
+				Case:             "Ticket",
+				Activity:         "Step",
+				Resource:         "Agent",
+				StartTimestamp:   "Begin",
+				EndTimestamp:     "Done",
+				TimestampFormats: map[string]string{"Begin": "%d/%m/%Y %H:%M", "Done": "%d/%m/%Y %H:%M"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := tt.file
+			if filePath == "" {
+				filePath = path.Join(t.TempDir(), "log.csv")
+				if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			inspection, err := inspectEventLog(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !inspection.Complete {
+				t.Fatalf("expected a complete mapping, got %+v", inspection.ColumnMapping)
+			}
+			if !reflect.DeepEqual(inspection.ColumnMapping, tt.want) {
+				t.Fatalf("unexpected column mapping\n got: %+v\nwant: %+v", inspection.ColumnMapping, tt.want)
+			}
+			for _, role := range inspectRoles {
+				if confidence := inspection.Confidence[role]; confidence <= 0 || confidence > 1 {
+					t.Fatalf("unexpected confidence %f for %s", confidence, role)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectEventLog_Incomplete(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "log.csv")
+	content := "id,name\n1,Marcus\n2,Anya\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := autoMapEventLog(filePath); err == nil {
+		t.Fatal("expected an error for a log without timestamps")
+	}
+}
+
+func TestInspectEventLogHandler(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/PurchasingExample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Post(ts.URL+"/logs/inspect", "text/csv", bytes.NewReader(eventLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+	}
+
+	var inspection model.EventLogInspection
+	if err = json.NewDecoder(res.Body).Decode(&inspection); err != nil {
+		t.Fatal(err)
+	}
+	if inspection.EventLogFormat != formatCSV || inspection.SampledRows != inspectSampleRows {
+		t.Fatalf("unexpected inspection %+v", inspection)
+	}
+	if inspection.ColumnMapping.Activity != "concept:name" {
+		t.Fatalf("unexpected activity column %q", inspection.ColumnMapping.Activity)
+	}
+	if len(inspection.Candidates[model.ColumnMappingActivity]) < 2 {
+		t.Fatalf("expected several activity candidates, got %+v", inspection.Candidates[model.ColumnMappingActivity])
+	}
+
+	entries, err := os.ReadDir(app.uploads.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Fatalf("expected the temporary directory to be removed, found %s", entry.Name())
+		}
+	}
+}
+
+func TestPostJobAutoMap(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5_columns.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a mapping the canonical columns are missing
+	res, err := http.Post(ts.URL+"/jobs", "text/csv", bytes.NewReader(eventLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status code %d, got %d", http.StatusUnprocessableEntity, res.StatusCode)
+	}
+
+	res, err = http.Post(ts.URL+"/jobs?auto_map=true", "text/csv", bytes.NewReader(eventLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status code %d, got %d", http.StatusCreated, res.StatusCode)
+	}
+
+	var response model.ApiSingleJobResponse
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	job := app.queue.FindByID(response.ID)
+	defer func() {
+		if err := app.queue.Remove(job, true); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if !job.AutoMap || job.ColumnMapping == nil || job.ColumnMapping.Case != "case_id" || job.ColumnMapping.EndTimestamp != "end_timestamp" {
+		t.Fatalf("unexpected column mapping %+v", job.ColumnMapping)
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxJSONLineSize limits a single event in a JSON-lines log.
+const maxJSONLineSize = 16 << 20
+
+// jsonLinesReader reads event logs with one JSON object per line. Nested objects are flattened into dot-separated
+// column names, arrays are kept as JSON.
+type jsonLinesReader struct{}
+
+func (jsonLinesReader) Format() string {
+	return "jsonl"
+}
+
+func (jsonLinesReader) Detect(fileName, contentType string, head []byte) bool {
+	for _, ext := range []string{".jsonl", ".ndjson", ".jsonlines"} {
+		if strings.HasSuffix(fileName, ext) {
+			return true
+		}
+	}
+
+	switch contentType {
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines", "application/jsonlines":
+		return true
+	}
+
+	// the first line of the file must be a complete JSON object
+	if !bytes.HasPrefix(head, []byte("{")) {
+		return false
+	}
+	newline := bytes.IndexByte(head, '\n')
+	if newline < 0 {
+		return false
+	}
+	return json.Valid(bytes.TrimSpace(head[:newline]))
+}
+
+// ToCSV reads the file twice, first to collect the field names for the CSV header, then to write the rows.
+func (jsonLinesReader) ToCSV(srcPath, dstPath string, _ EventLogReaderOptions) (bool, error) {
+	var columns []string
+	seen := map[string]bool{}
+
+	err := readJSONLines(srcPath, func(record map[string]string) error {
+		var newColumns []string
+		for k := range record {
+			if !seen[k] {
+				seen[k] = true
+				newColumns = append(newColumns, k)
+			}
+		}
+		sort.Strings(newColumns)
+		columns = append(columns, newColumns...)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(columns) == 0 {
+		return false, fmt.Errorf("JSON-lines event log has no events")
+	}
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	buf := bufio.NewWriter(out)
+	w := csv.NewWriter(buf)
+	if err = w.Write(columns); err != nil {
+		return false, err
+	}
+
+	row := make([]string, len(columns))
+	err = readJSONLines(srcPath, func(record map[string]string) error {
+		for i, column := range columns {
+			row[i] = record[column]
+		}
+		return w.Write(row)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return false, err
+	}
+	if err = buf.Flush(); err != nil {
+		return false, err
+	}
+	return false, out.Close()
+}
+
+func readJSONLines(filePath string, onRecord func(record map[string]string) error) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return fmt.Errorf("invalid JSON on line %d: %s", line, err.Error())
+		}
+
+		record := map[string]string{}
+		flattenJSON("", object, record)
+
+		if err := onRecord(record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// flattenJSON converts a JSON object into column values. Nested objects produce "parent.child" columns.
+func flattenJSON(prefix string, object map[string]interface{}, record map[string]string) {
+	for k, v := range object {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+
+		switch value := v.(type) {
+		case nil:
+			record[k] = ""
+		case string:
+			record[k] = value
+		case json.Number:
+			record[k] = value.String()
+		case bool:
+			record[k] = fmt.Sprint(value)
+		case map[string]interface{}:
+			flattenJSON(k, value, record)
+		default:
+			b, _ := json.Marshal(value)
+			record[k] = string(b)
+		}
+	}
+}
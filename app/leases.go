@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/google/uuid"
+)
+
+// errLeaseGone is returned for the leases which have expired, have been completed or whose job has been cancelled.
+var errLeaseGone = errors.New("lease is gone")
+
+// leaseTask is the analysis of a job waiting for a remote worker or leased to one.
+type leaseTask struct {
+	job          *model.Job
+	eventLogName string
+	lease        *model.Lease
+	// done receives the result of the analysis, it's buffered so that completing a lease never blocks
+	done chan error
+}
+
+// LeaseStore hands the analyses of the jobs out to the remote workers, see Configuration.RemoteWorkers. The analyses
+// wait in the order they've been added and are leased for a duration which the workers extend with heartbeats. An
+// expired lease puts the analysis back in front of the others until it's been leased maxAttempts times.
+type LeaseStore struct {
+	duration    time.Duration
+	maxAttempts int
+
+	lock    sync.Mutex
+	waiting []*leaseTask
+	leased  map[string]*leaseTask
+	// attempts counts the leases of the analyses by the job's ID
+	attempts map[string]int
+}
+
+func NewLeaseStore(duration time.Duration, maxAttempts int) *LeaseStore {
+	return &LeaseStore{
+		duration:    duration,
+		maxAttempts: maxAttempts,
+		leased:      map[string]*leaseTask{},
+		attempts:    map[string]int{},
+	}
+}
+
+// Run adds the analysis of the job's event log and waits until a worker completes it. The analysis is withdrawn when
+// ctx is done, its worker finds out with its next heartbeat.
+func (s *LeaseStore) Run(ctx context.Context, job *model.Job, eventLogName string) error {
+	task := &leaseTask{job: job, eventLogName: eventLogName, done: make(chan error, 1)}
+
+	s.lock.Lock()
+	s.waiting = append(s.waiting, task)
+	s.lock.Unlock()
+
+	// the leases expire even if no worker is asking for new ones
+	ticker := time.NewTicker(s.duration / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-task.done:
+			return err
+		case <-ctx.Done():
+			s.withdraw(task)
+			return fmt.Errorf("analysis has been stopped: %s", ctx.Err().Error())
+		case <-ticker.C:
+			s.lock.Lock()
+			s.expire(time.Now())
+			s.lock.Unlock()
+		}
+	}
+}
+
+// Lease hands the first waiting analysis out to the worker, it returns nil if there's none.
+func (s *LeaseStore) Lease(workerID string) *model.Lease {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	if len(s.waiting) == 0 {
+		return nil
+	}
+
+	task := s.waiting[0]
+	s.waiting = s.waiting[1:]
+	s.attempts[task.job.ID]++
+
+	task.lease = &model.Lease{
+		ID:                uuid.New().String(),
+		JobID:             task.job.ID,
+		WorkerID:          workerID,
+		Attempt:           s.attempts[task.job.ID],
+		Kind:              task.job.AnalysisKind(),
+		Params:            task.job.Params,
+		ColumnMapping:     task.job.ColumnMapping,
+		EventLogName:      task.eventLogName,
+		ExpiresAt:         now.Add(s.duration),
+		HeartbeatInterval: (s.duration / 3).Seconds(),
+	}
+	s.leased[task.lease.ID] = task
+
+	lease := *task.lease
+	return &lease
+}
+
+// Heartbeat renews the lease and returns it.
+func (s *LeaseStore) Heartbeat(id string) (*model.Lease, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	task, ok := s.leased[id]
+	if !ok {
+		return nil, errLeaseGone
+	}
+	task.lease.ExpiresAt = now.Add(s.duration)
+
+	lease := *task.lease
+	return &lease, nil
+}
+
+// Get returns the lease and its job.
+func (s *LeaseStore) Get(id string) (*model.Lease, *model.Job, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire(time.Now())
+	task, ok := s.leased[id]
+	if !ok {
+		return nil, nil, errLeaseGone
+	}
+
+	lease := *task.lease
+	return &lease, task.job, nil
+}
+
+// Complete ends the lease with the result of the analysis, a nil error means it has succeeded.
+func (s *LeaseStore) Complete(id string, result error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire(time.Now())
+	task, ok := s.leased[id]
+	if !ok {
+		return errLeaseGone
+	}
+	delete(s.leased, id)
+	delete(s.attempts, task.job.ID)
+	task.done <- result
+	return nil
+}
+
+// withdraw removes the analysis, whether it's waiting or leased.
+func (s *LeaseStore) withdraw(task *leaseTask) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, t := range s.waiting {
+		if t == task {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			break
+		}
+	}
+	if task.lease != nil && s.leased[task.lease.ID] == task {
+		delete(s.leased, task.lease.ID)
+	}
+	delete(s.attempts, task.job.ID)
+}
+
+// expire ends the leases which haven't been renewed. Their analyses are put back in front of the waiting ones or fail
+// after maxAttempts. It has to be called with the lock held.
+func (s *LeaseStore) expire(now time.Time) {
+	var expired []*leaseTask
+	for id, task := range s.leased {
+		if now.After(task.lease.ExpiresAt) {
+			delete(s.leased, id)
+			expired = append(expired, task)
+		}
+	}
+
+	for _, task := range expired {
+		if s.attempts[task.job.ID] >= s.maxAttempts {
+			delete(s.attempts, task.job.ID)
+			task.done <- fmt.Errorf("the lease of worker %s has expired, the analysis has been leased %d times",
+				task.lease.WorkerID, s.maxAttempts)
+			continue
+		}
+		s.waiting = append([]*leaseTask{task}, s.waiting...)
+	}
+}
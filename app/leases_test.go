@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestLeaseStore(t *testing.T) {
+	store := NewLeaseStore(100*time.Millisecond, 2)
+
+	run := func(job *model.Job) chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- store.Run(context.Background(), job, "log.csv")
+		}()
+		return result
+	}
+	leaseSoon := func(workerID string) *model.Lease {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if lease := store.Lease(workerID); lease != nil {
+				return lease
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("expected a lease")
+		return nil
+	}
+
+	if store.Lease("worker") != nil {
+		t.Fatal("expected no lease without jobs")
+	}
+
+	t.Run("expired lease is handed out again", func(t *testing.T) {
+		job := &model.Job{ID: "expiring", Kind: model.AnalysisKindCalendarDiscovery}
+		result := run(job)
+
+		dead := leaseSoon("dead")
+		if dead.JobID != job.ID || dead.Attempt != 1 || dead.Kind != job.Kind || dead.EventLogName != "log.csv" {
+			t.Fatalf("unexpected lease %+v", dead)
+		}
+		if store.Lease("other") != nil {
+			t.Fatal("expected the leased job not to be handed out twice")
+		}
+
+		alive := leaseSoon("alive")
+		if alive.JobID != job.ID || alive.Attempt != 2 || alive.ID == dead.ID {
+			t.Fatalf("expected the second attempt at the job, got %+v", alive)
+		}
+		if _, err := store.Heartbeat(dead.ID); err != errLeaseGone {
+			t.Fatalf("expected the expired lease to be gone, got %v", err)
+		}
+		if _, err := store.Heartbeat(alive.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Complete(alive.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Complete(alive.ID, nil); err != errLeaseGone {
+			t.Fatalf("expected the completed lease to be gone, got %v", err)
+		}
+	})
+
+	t.Run("analysis fails after the last attempt", func(t *testing.T) {
+		result := run(&model.Job{ID: "failing"})
+		leaseSoon("first")
+		leaseSoon("second")
+
+		select {
+		case err := <-result:
+			if err == nil || !strings.Contains(err.Error(), "worker second") {
+				t.Fatalf("expected the expiry of the second lease, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the analysis to fail")
+		}
+	})
+
+	t.Run("cancelled analysis is withdrawn", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- store.Run(ctx, &model.Job{ID: "cancelled"}, "log.csv")
+		}()
+		lease := leaseSoon("worker")
+
+		cancel()
+		if err := <-result; err == nil || !strings.Contains(err.Error(), "stopped") {
+			t.Fatalf("expected the analysis to be stopped, got %v", err)
+		}
+		if _, err := store.Heartbeat(lease.ID); err != errLeaseGone {
+			t.Fatalf("expected the lease of the cancelled job to be gone, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// resultsURLPath is where the job's directories are served from, see the "/assets/" route.
+const resultsURLPath = "/assets/results"
+
+// links builds the absolute URLs the API returns. The links stored with the jobs are rebuilt in every response, so that
+// they follow the configuration and the request rather than the host the job has been created on.
+type links struct {
+	base *url.URL
+}
+
+// links returns the link builder for the response to r, which is nil outside of requests. The forwarded scheme and host
+// override the configured ones if the configuration trusts them.
+func (app *Application) links(r *http.Request) links {
+	base := *app.config.BaseURL()
+
+	if r != nil && app.config.TrustForwardedHeaders {
+		if proto := forwardedValue(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			base.Scheme = proto
+		}
+		if host := forwardedValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			base.Host = host
+		}
+	}
+
+	return links{base: &base}
+}
+
+// forwardedValue returns the value added by the proxy closest to the client if there're several proxies.
+func forwardedValue(header string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(header, ",")[0]))
+}
+
+// resolve returns the absolute URL of the path on this service.
+func (l links) resolve(p string) *url.URL {
+	u := *l.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + p
+	u.RawPath = ""
+	return &u
+}
+
+// jobFile returns the URL of the file name in the job's directory.
+func (l links) jobFile(jobID, name string) *url.URL {
+	return l.resolve(path.Join(resultsURLPath, jobID, name))
+}
+
+// job returns a copy of the job with the links to its files rebuilt. Jobs store the links with the host they've been
+// created on, only the file names are taken from them. Duplicates link to the report and the result file in the
+// original's directory.
+func (l links) job(job *model.Job) *model.Job {
+	if job == nil {
+		return nil
+	}
+
+	c := job.Copy()
+
+	owner := c.ID
+	if c.DuplicateOf != "" {
+		owner = c.DuplicateOf
+	}
+	if c.ReportCSV != nil && c.ReportCSV.URL != nil {
+		c.ReportCSV = &model.URL{URL: l.jobFile(owner, path.Base(c.ReportCSV.URL.Path))}
+	}
+	if c.ResultFile != nil && c.ResultFile.URL != nil {
+		c.ResultFile = &model.URL{URL: l.jobFile(owner, path.Base(c.ResultFile.URL.Path))}
+	}
+
+	// the link to an uploaded event log is dropped when the log has been deleted
+	if c.EventLogFromRequestBody && c.EventLogDeletedAt != nil {
+		c.EventLog = ""
+	} else if c.EventLogFromRequestBody && c.EventLogURL != nil && c.EventLogURL.URL != nil {
+		eventLogURL := l.jobFile(c.ID, path.Base(c.EventLogURL.URL.Path))
+		c.EventLog = eventLogURL.String()
+		c.EventLogURL = &model.URL{URL: eventLogURL}
+	}
+
+	return c
+}
+
+// jobs returns the copies of the jobs with the links rebuilt, see job.
+func (l links) jobs(jobs []*model.Job) []*model.Job {
+	if jobs == nil {
+		return nil
+	}
+
+	result := make([]*model.Job, len(jobs))
+	for i, job := range jobs {
+		result[i] = l.job(job)
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestJobLinks(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	parse := func(s string) *model.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &model.URL{URL: u}
+	}
+
+	// jobs stored with the links of the host they've been created on
+	uploaded := &model.Job{
+		ID:                      "links-uploaded",
+		Status:                  model.JobStatusCompleted,
+		EventLog:                "http://old-host/assets/results/links-uploaded/log.csv",
+		EventLogURL:             parse("http://old-host/assets/results/links-uploaded/log.csv"),
+		EventLogFromRequestBody: true,
+		ReportCSV:               parse("http://old-host/assets/results/links-uploaded/log_transitions_report.csv"),
+	}
+	downloaded := &model.Job{
+		ID:          "links-downloaded",
+		Status:      model.JobStatusDuplicate,
+		EventLog:    "http://example.org/log.csv",
+		EventLogURL: parse("http://example.org/log.csv"),
+		DuplicateOf: uploaded.ID,
+		ReportCSV:   uploaded.ReportCSV,
+	}
+	app.queue.Jobs = append(app.queue.Jobs, uploaded, downloaded)
+
+	get := func(id string, headers map[string]string) *model.Job {
+		req, err := http.NewRequest("GET", ts.URL+"/jobs/"+id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		var response model.ApiSingleJobResponse
+		if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Job
+	}
+
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "proxy.example.com, internal"}
+
+	tests := []struct {
+		name          string
+		baseURL       string
+		trust         bool
+		headers       map[string]string
+		job           *model.Job
+		wantEventLog  string
+		wantReportCSV string
+	}{
+		{
+			name:          "default",
+			job:           uploaded,
+			wantEventLog:  "http://localhost/assets/results/links-uploaded/log.csv",
+			wantReportCSV: "http://localhost/assets/results/links-uploaded/log_transitions_report.csv",
+		},
+		{
+			name:          "public base URL with prefix",
+			baseURL:       "https://example.com/waiting-time/",
+			headers:       forwarded,
+			job:           uploaded,
+			wantEventLog:  "https://example.com/waiting-time/assets/results/links-uploaded/log.csv",
+			wantReportCSV: "https://example.com/waiting-time/assets/results/links-uploaded/log_transitions_report.csv",
+		},
+		{
+			name:          "trusted forwarded headers",
+			baseURL:       "http://example.com/waiting-time",
+			trust:         true,
+			headers:       forwarded,
+			job:           uploaded,
+			wantEventLog:  "https://proxy.example.com/waiting-time/assets/results/links-uploaded/log.csv",
+			wantReportCSV: "https://proxy.example.com/waiting-time/assets/results/links-uploaded/log_transitions_report.csv",
+		},
+		{
+			name:          "duplicate of a downloaded log",
+			baseURL:       "https://example.com",
+			job:           downloaded,
+			wantEventLog:  "http://example.org/log.csv",
+			wantReportCSV: "https://example.com/assets/results/links-uploaded/log_transitions_report.csv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.config.PublicBaseURL = tt.baseURL
+			app.config.TrustForwardedHeaders = tt.trust
+
+			job := get(tt.job.ID, tt.headers)
+
+			if job.EventLog != tt.wantEventLog {
+				t.Fatalf("expected event_log %s, got %s", tt.wantEventLog, job.EventLog)
+			}
+			if job.ReportCSV.String() != tt.wantReportCSV {
+				t.Fatalf("expected report_csv %s, got %s", tt.wantReportCSV, job.ReportCSV.String())
+			}
+		})
+	}
+
+	if uploaded.ReportCSV.String() != "http://old-host/assets/results/links-uploaded/log_transitions_report.csv" {
+		t.Fatalf("expected the stored job to keep its links, got %s", uploaded.ReportCSV.String())
+	}
+}
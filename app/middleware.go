@@ -24,8 +24,9 @@ func Logger(app *Application, inner http.Handler, name string) http.Handler {
 func EnableCORS(inner http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Content-Encoding, Content-Range, Accept-Encoding, X-CSRF-Token, Authorization, Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset, X-Owner")
+		w.Header().Set("Access-Control-Expose-Headers", "Location, Retry-After, Tus-Resumable, Upload-Length, Upload-Offset")
 
 		if r.Method == "OPTIONS" {
 			return
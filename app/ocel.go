@@ -0,0 +1,284 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ocelReader flattens object-centric event logs in the JSON formats of OCEL 1.0 and OCEL 2.0 by one object type: every
+// object of the type becomes a case with the events related to it.
+type ocelReader struct{}
+
+type ocelEvent struct {
+	activity   string
+	timestamp  time.Time
+	attributes map[string]string
+	objects    []string
+}
+
+func (ocelReader) Format() string {
+	return "ocel"
+}
+
+func (ocelReader) Detect(fileName, contentType string, head []byte) bool {
+	if strings.HasSuffix(fileName, ".jsonocel") || strings.HasSuffix(fileName, ".ocel.json") {
+		return true
+	}
+
+	if !bytes.HasPrefix(head, []byte("{")) {
+		return false
+	}
+	return bytes.Contains(head, []byte(`"ocel:`)) ||
+		(bytes.Contains(head, []byte(`"objectTypes"`)) && bytes.Contains(head, []byte(`"eventTypes"`)))
+}
+
+func (ocelReader) ToCSV(srcPath, dstPath string, options EventLogReaderOptions) (bool, error) {
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return false, err
+	}
+	var document map[string]json.RawMessage
+	err = json.NewDecoder(bufio.NewReader(f)).Decode(&document)
+	_ = f.Close()
+	if err != nil {
+		return false, fmt.Errorf("invalid OCEL document: %s", err.Error())
+	}
+
+	var (
+		events      []ocelEvent
+		objectTypes map[string]string
+	)
+	if _, ok := document["ocel:events"]; ok {
+		events, objectTypes, err = parseOCEL1(document)
+	} else {
+		events, objectTypes, err = parseOCEL2(document)
+	}
+	if err != nil {
+		return false, fmt.Errorf("invalid OCEL document: %s", err.Error())
+	}
+
+	objectType, err := ocelCaseObjectType(objectTypes, options.OCELObjectType)
+	if err != nil {
+		return false, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].timestamp.Before(events[j].timestamp)
+	})
+
+	attributeKeys := map[string]bool{}
+	for _, event := range events {
+		for k := range event.attributes {
+			attributeKeys[k] = true
+		}
+	}
+	delete(attributeKeys, xesResource)
+	delete(attributeKeys, "resource")
+	attributeColumns := sortedKeys(attributeKeys)
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	buf := bufio.NewWriter(out)
+	w := csv.NewWriter(buf)
+
+	header := []string{canonicalCaseColumn, canonicalActivityColumn, canonicalResourceColumn, canonicalStartColumn, canonicalEndColumn}
+	if err = w.Write(append(header, attributeColumns...)); err != nil {
+		return false, err
+	}
+
+	rows := 0
+	for _, event := range events {
+		resource, ok := event.attributes[xesResource]
+		if !ok {
+			resource = event.attributes["resource"]
+		}
+		timestamp := event.timestamp.Format(canonicalTimeLayout)
+
+		for _, object := range event.objects {
+			if objectTypes[object] != objectType {
+				continue
+			}
+
+			record := []string{object, event.activity, resource, timestamp, timestamp}
+			for _, k := range attributeColumns {
+				record = append(record, event.attributes[k])
+			}
+			if err = w.Write(record); err != nil {
+				return false, err
+			}
+			rows++
+		}
+	}
+
+	if rows == 0 {
+		return false, fmt.Errorf("no events are related to objects of type %q", objectType)
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return false, err
+	}
+	if err = buf.Flush(); err != nil {
+		return false, err
+	}
+	return true, out.Close()
+}
+
+// ocelCaseObjectType checks the requested object type. It can be omitted when the log has only one object type.
+func ocelCaseObjectType(objectTypes map[string]string, requested string) (string, error) {
+	types := map[string]bool{}
+	for _, t := range objectTypes {
+		types[t] = true
+	}
+	available := strings.Join(sortedKeys(types), ", ")
+
+	if requested == "" {
+		if len(types) == 1 {
+			for t := range types {
+				return t, nil
+			}
+		}
+		return "", fmt.Errorf("ocel_object_type is required to flatten the object-centric event log, available types: %s", available)
+	}
+
+	if !types[requested] {
+		return "", fmt.Errorf("object type %q is not in the event log, available types: %s", requested, available)
+	}
+	return requested, nil
+}
+
+func parseOCEL1(document map[string]json.RawMessage) ([]ocelEvent, map[string]string, error) {
+	var rawEvents map[string]struct {
+		Activity  string                 `json:"ocel:activity"`
+		Timestamp string                 `json:"ocel:timestamp"`
+		Objects   []string               `json:"ocel:omap"`
+		Values    map[string]interface{} `json:"ocel:vmap"`
+	}
+	if err := json.Unmarshal(document["ocel:events"], &rawEvents); err != nil {
+		return nil, nil, err
+	}
+
+	var rawObjects map[string]struct {
+		Type string `json:"ocel:type"`
+	}
+	if err := json.Unmarshal(document["ocel:objects"], &rawObjects); err != nil {
+		return nil, nil, err
+	}
+
+	objectTypes := map[string]string{}
+	for id, object := range rawObjects {
+		objectTypes[id] = object.Type
+	}
+
+	// event IDs are the keys of a JSON object, so they are sorted to get a stable order for equal timestamps
+	ids := make([]string, 0, len(rawEvents))
+	for id := range rawEvents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var events []ocelEvent
+	for _, id := range ids {
+		raw := rawEvents[id]
+
+		timestamp, err := parseISOTimestamp(raw.Timestamp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("event %s: %s", id, err.Error())
+		}
+
+		attributes := map[string]string{}
+		for k, v := range raw.Values {
+			attributes[k] = ocelValue(v)
+		}
+
+		events = append(events, ocelEvent{
+			activity:   raw.Activity,
+			timestamp:  timestamp,
+			attributes: attributes,
+			objects:    raw.Objects,
+		})
+	}
+
+	return events, objectTypes, nil
+}
+
+func parseOCEL2(document map[string]json.RawMessage) ([]ocelEvent, map[string]string, error) {
+	var rawEvents []struct {
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		Time       string `json:"time"`
+		Attributes []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"attributes"`
+		Relationships []struct {
+			ObjectID string `json:"objectId"`
+		} `json:"relationships"`
+	}
+	if err := json.Unmarshal(document["events"], &rawEvents); err != nil {
+		return nil, nil, err
+	}
+
+	var rawObjects []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(document["objects"], &rawObjects); err != nil {
+		return nil, nil, err
+	}
+
+	objectTypes := map[string]string{}
+	for _, object := range rawObjects {
+		objectTypes[object.ID] = object.Type
+	}
+
+	var events []ocelEvent
+	for _, raw := range rawEvents {
+		timestamp, err := parseISOTimestamp(raw.Time)
+		if err != nil {
+			return nil, nil, fmt.Errorf("event %s: %s", raw.ID, err.Error())
+		}
+
+		attributes := map[string]string{}
+		for _, attribute := range raw.Attributes {
+			attributes[attribute.Name] = ocelValue(attribute.Value)
+		}
+
+		var objects []string
+		for _, relationship := range raw.Relationships {
+			objects = append(objects, relationship.ObjectID)
+		}
+
+		events = append(events, ocelEvent{
+			activity:   raw.Type,
+			timestamp:  timestamp,
+			attributes: attributes,
+			objects:    objects,
+		})
+	}
+
+	return events, objectTypes, nil
+}
+
+func ocelValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		b, _ := json.Marshal(value)
+		return string(b)
+	}
+}
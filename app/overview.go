@@ -0,0 +1,223 @@
+package app
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// waitingTimeCauses are the causes the waiting time can be broken down by, "total" is the waiting time of all causes.
+var waitingTimeCauses = []string{"total", "batching", "prioritization", "contention", "unavailability", "extraneous"}
+
+// defaultWaitingTimeGroups is how many of the largest groups a breakdown lists by default.
+const defaultWaitingTimeGroups = 10
+
+func isWaitingTimeCause(cause string) bool {
+	for _, c := range waitingTimeCauses {
+		if c == cause {
+			return true
+		}
+	}
+	return false
+}
+
+// cause returns the waiting time of one of waitingTimeCauses.
+func (wt *waitingTimes) cause(cause string) float64 {
+	switch cause {
+	case "batching":
+		return wt.batching
+	case "prioritization":
+		return wt.prioritization
+	case "contention":
+		return wt.contention
+	case "unavailability":
+		return wt.unavailability
+	case "extraneous":
+		return wt.extraneous
+	default:
+		return wt.total
+	}
+}
+
+// average returns the waiting times divided by n, or zeros if n is 0.
+func (wt *waitingTimes) average(n int) *model.WaitingTimes {
+	if n == 0 {
+		return &model.WaitingTimes{}
+	}
+	return &model.WaitingTimes{
+		TotalWt:          wt.total / float64(n),
+		BatchingWt:       wt.batching / float64(n),
+		PrioritizationWt: wt.prioritization / float64(n),
+		ContentionWt:     wt.contention / float64(n),
+		UnavailabilityWt: wt.unavailability / float64(n),
+		ExtraneousWt:     wt.extraneous / float64(n),
+	}
+}
+
+func (wt *waitingTimes) sums() *model.WaitingTimes {
+	return wt.average(1)
+}
+
+// waitingTimeOverview summarizes the transitions of a report. The processing time is 0 if it isn't known.
+func waitingTimeOverview(pt float64, items []model.JobResultItem) *model.JobOverview {
+	var process waitingTimes
+	cases := map[string]bool{}
+	activities := map[string]bool{}
+	transitions := map[[2]string]bool{}
+
+	for i := range items {
+		item := &items[i]
+		process.add(item)
+		cases[item.CaseID] = true
+		activities[item.SourceActivity] = true
+		activities[item.DestinationActivity] = true
+		transitions[[2]string{item.SourceActivity, item.DestinationActivity}] = true
+	}
+
+	return &model.JobOverview{
+		NumCases:               len(cases),
+		NumActivities:          len(activities),
+		NumTransitions:         len(transitions),
+		NumTransitionInstances: len(items),
+		ProcessingTime:         pt,
+		Totals:                 process.sums(),
+		Averages:               process.average(len(items)),
+	}
+}
+
+// waitingTimeBreakdown breaks the waiting time of the cause down by transition, resource pair, target resource and
+// case. Only the transitions between the source and the target activity are covered, any activity matches if they're
+// empty. The groups are limited to the limit largest if it's positive.
+func waitingTimeBreakdown(items []model.JobResultItem, cause, source, target string, limit int) *model.WaitingTimeBreakdown {
+	var process, selected waitingTimes
+	cases := map[string]bool{}
+	affected := map[string]bool{}
+	instances := 0
+
+	transitions := newWaitingTimeGroups(cause)
+	pairs := newWaitingTimeGroups(cause)
+	resources := newWaitingTimeGroups(cause)
+	caseGroups := newWaitingTimeGroups(cause)
+
+	for i := range items {
+		item := &items[i]
+		process.add(item)
+
+		if (source != "" && item.SourceActivity != source) || (target != "" && item.DestinationActivity != target) {
+			continue
+		}
+
+		selected.add(item)
+		instances++
+		cases[item.CaseID] = true
+		var wt waitingTimes
+		wt.add(item)
+		if wt.cause(cause) > 0 {
+			affected[item.CaseID] = true
+		}
+
+		transitions.add([2]string{item.SourceActivity, item.DestinationActivity}, item, func() *model.WaitingTimeGroup {
+			return &model.WaitingTimeGroup{SourceActivity: item.SourceActivity, TargetActivity: item.DestinationActivity}
+		})
+		pairs.add([2]string{item.SourceResource, item.DestinationResource}, item, func() *model.WaitingTimeGroup {
+			return &model.WaitingTimeGroup{SourceResource: item.SourceResource, TargetResource: item.DestinationResource}
+		})
+		resources.add([2]string{"", item.DestinationResource}, item, func() *model.WaitingTimeGroup {
+			return &model.WaitingTimeGroup{TargetResource: item.DestinationResource}
+		})
+		caseGroups.add([2]string{item.CaseID, ""}, item, func() *model.WaitingTimeGroup {
+			return &model.WaitingTimeGroup{CaseID: item.CaseID}
+		})
+	}
+
+	breakdown := &model.WaitingTimeBreakdown{
+		Cause:                  cause,
+		SourceActivity:         source,
+		TargetActivity:         target,
+		NumCases:               len(cases),
+		NumCasesAffected:       len(affected),
+		NumTransitionInstances: instances,
+		Wt:                     selected.cause(cause),
+		ProcessWt:              process.cause(cause),
+		Totals:                 selected.sums(),
+		Transitions:            transitions.top(limit),
+		ResourcePairs:          pairs.top(limit),
+		Resources:              resources.top(limit),
+		Cases:                  caseGroups.top(limit),
+	}
+	if instances > 0 {
+		breakdown.AvgWt = breakdown.Wt / float64(instances)
+	}
+	if len(items) > 0 {
+		breakdown.ProcessAvgWt = breakdown.ProcessWt / float64(len(items))
+	}
+
+	return breakdown
+}
+
+// waitingTimeGroups sums the waiting times of transition instances by a key in the order the keys first occur.
+type waitingTimeGroups struct {
+	cause  string
+	groups []*waitingTimeGroup
+	byKey  map[[2]string]*waitingTimeGroup
+}
+
+type waitingTimeGroup struct {
+	group *model.WaitingTimeGroup
+	wt    waitingTimes
+}
+
+func newWaitingTimeGroups(cause string) *waitingTimeGroups {
+	return &waitingTimeGroups{cause: cause, byKey: map[[2]string]*waitingTimeGroup{}}
+}
+
+// add adds the item to the group of the key, which is created with newGroup if it's the first item.
+func (g *waitingTimeGroups) add(key [2]string, item *model.JobResultItem, newGroup func() *model.WaitingTimeGroup) {
+	group, ok := g.byKey[key]
+	if !ok {
+		group = &waitingTimeGroup{group: newGroup()}
+		g.byKey[key] = group
+		g.groups = append(g.groups, group)
+	}
+	group.wt.add(item)
+	group.group.Count++
+}
+
+// top returns the groups ordered by the waiting time of the cause, the largest first, and limited to limit groups if
+// it's positive.
+func (g *waitingTimeGroups) top(limit int) []*model.WaitingTimeGroup {
+	sort.SliceStable(g.groups, func(i, j int) bool {
+		return g.groups[i].wt.cause(g.cause) > g.groups[j].wt.cause(g.cause)
+	})
+
+	groups := g.groups
+	if limit > 0 && len(groups) > limit {
+		groups = groups[:limit]
+	}
+
+	result := make([]*model.WaitingTimeGroup, len(groups))
+	for i, group := range groups {
+		group.group.Wt = group.wt.cause(g.cause)
+		group.group.AvgWt = group.group.Wt / float64(group.group.Count)
+		group.group.Totals = group.wt.sums()
+		result[i] = group.group
+	}
+	return result
+}
+
+// jobOverview summarizes the report of a completed job, see jobReport.
+func (app *Application) jobOverview(job *model.Job) (*model.JobOverview, error) {
+	owner, items, err := app.jobReport(job)
+	if err != nil {
+		return nil, err
+	}
+
+	// the overview is still useful without the processing time
+	pt, err := jobProcessingTime(owner)
+	if err != nil && !errors.Is(err, errNoResults) {
+		return nil, err
+	}
+
+	return waitingTimeOverview(pt, items), nil
+}
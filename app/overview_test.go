@@ -0,0 +1,148 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestWaitingTimeOverview(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	report := "start_time,end_time,source_activity,source_resource,destination_activity,destination_resource,case_id," +
+		"wt_total,wt_contention,wt_batching,wt_prioritization,wt_unavailability,wt_extraneous\n" +
+		"2022-05-16T10:15:00Z,2022-05-16T12:00:00Z,A,Marcus,B,Anya,0,6300,6300,0,0,0,0\n" +
+		"2022-05-16T12:30:00Z,2022-05-16T12:30:00Z,B,Anya,C,Dom,0,0,0,0,0,0,0\n" +
+		"2022-05-16T10:30:00Z,2022-05-16T11:00:00Z,A,Marcus,B,Anya,1,1800,0,0,0,0,1800\n" +
+		"2022-05-16T11:30:00Z,2022-05-16T11:40:00Z,B,Anya,C,Carmine,1,600,0,600,0,0,0\n" +
+		"2022-05-16T10:45:00Z,2022-05-16T11:00:00Z,A,Marcus,B,Anya,2,900,300,600,0,0,0\n"
+
+	job := &model.Job{
+		ID:             "overview",
+		Status:         model.JobStatusCompleted,
+		CreatedAt:      time.Now(),
+		Dir:            t.TempDir(),
+		ReportCSV:      &model.URL{URL: &url.URL{Path: "/assets/results/overview/log_transitions_report.csv"}},
+		ProcessingTime: 3600,
+	}
+	if err = os.WriteFile(path.Join(job.Dir, "log_transitions_report.csv"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pending := &model.Job{ID: "overview-pending", Status: model.JobStatusPending, CreatedAt: time.Now()}
+	for _, j := range []*model.Job{job, pending} {
+		if err = app.queue.Add(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, j := range []*model.Job{job, pending} {
+			if err := app.queue.Remove(j, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}()
+
+	get := func(p string, wantStatus int, v interface{}) {
+		t.Helper()
+
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != wantStatus {
+			t.Fatalf("expected status code %d for %s, got %d", wantStatus, p, res.StatusCode)
+		}
+		if v != nil {
+			if err = json.NewDecoder(res.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var overview model.JobOverview
+	get("/jobs/overview/overview", http.StatusOK, &overview)
+	if overview.NumCases != 3 || overview.NumActivities != 3 || overview.NumTransitions != 2 ||
+		overview.NumTransitionInstances != 5 || overview.ProcessingTime != 3600 {
+		t.Fatalf("unexpected overview %+v", overview)
+	}
+	wantTotals := model.WaitingTimes{TotalWt: 9600, BatchingWt: 1200, ContentionWt: 6600, ExtraneousWt: 1800}
+	if *overview.Totals != wantTotals {
+		t.Fatalf("expected totals %+v, got %+v", wantTotals, *overview.Totals)
+	}
+	if overview.Averages.TotalWt != 1920 || overview.Averages.ContentionWt != 1320 {
+		t.Fatalf("unexpected averages %+v", *overview.Averages)
+	}
+
+	var breakdown model.WaitingTimeBreakdown
+	get("/jobs/overview/waiting-time/contention", http.StatusOK, &breakdown)
+	if breakdown.Wt != 6600 || breakdown.AvgWt != 1320 || breakdown.ProcessWt != 6600 || breakdown.NumCases != 3 ||
+		breakdown.NumCasesAffected != 2 || breakdown.NumTransitionInstances != 5 {
+		t.Fatalf("unexpected breakdown %+v", breakdown)
+	}
+	if len(breakdown.Transitions) != 2 || breakdown.Transitions[0].TargetActivity != "B" ||
+		breakdown.Transitions[0].Wt != 6600 || breakdown.Transitions[0].AvgWt != 2200 || breakdown.Transitions[0].Count != 3 {
+		t.Fatalf("unexpected transitions %+v", breakdown.Transitions)
+	}
+	if len(breakdown.Resources) != 3 || breakdown.Resources[0].TargetResource != "Anya" || breakdown.Resources[0].Wt != 6600 {
+		t.Fatalf("unexpected resources %+v", breakdown.Resources)
+	}
+	var cases []string
+	for _, group := range breakdown.Cases {
+		cases = append(cases, group.CaseID)
+	}
+	if len(cases) != 3 || cases[0] != "0" || cases[1] != "2" || cases[2] != "1" || breakdown.Cases[2].Totals.TotalWt != 2400 {
+		t.Fatalf("expected the cases 0, 2 and 1 with the totals, got %v", breakdown.Cases)
+	}
+
+	// a single transition's resource pairs
+	breakdown = model.WaitingTimeBreakdown{}
+	get("/jobs/overview/waiting-time/batching?source_activity=B&target_activity=C&limit=1", http.StatusOK, &breakdown)
+	if breakdown.Wt != 600 || breakdown.AvgWt != 300 || breakdown.ProcessWt != 1200 || breakdown.ProcessAvgWt != 240 ||
+		breakdown.NumCases != 2 || breakdown.NumCasesAffected != 1 {
+		t.Fatalf("unexpected breakdown %+v", breakdown)
+	}
+	if len(breakdown.ResourcePairs) != 1 || breakdown.ResourcePairs[0].TargetResource != "Carmine" || breakdown.ResourcePairs[0].Wt != 600 {
+		t.Fatalf("unexpected resource pairs %+v", breakdown.ResourcePairs)
+	}
+
+	get("/jobs/overview/waiting-time/idle", http.StatusBadRequest, nil)
+	get("/jobs/overview/waiting-time/total?limit=-1", http.StatusBadRequest, nil)
+	get("/jobs/overview-pending/overview", http.StatusConflict, nil)
+	get("/jobs/overview-pending/waiting-time/total", http.StatusConflict, nil)
+	get("/jobs/missing/overview", http.StatusNotFound, nil)
+}
+
+func TestWaitingTimeOverview_Empty(t *testing.T) {
+	overview := waitingTimeOverview(0, nil)
+	breakdown := waitingTimeBreakdown(nil, "total", "", "", defaultWaitingTimeGroups)
+
+	// NaN averages can't be encoded
+	for _, v := range []interface{}{overview, breakdown} {
+		if _, err := json.Marshal(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if overview.NumCases != 0 || overview.Averages.TotalWt != 0 || breakdown.AvgWt != 0 || breakdown.ProcessAvgWt != 0 {
+		t.Fatalf("unexpected overview %+v and breakdown %+v", overview, breakdown)
+	}
+	if breakdown.Transitions == nil || len(breakdown.Transitions) != 0 {
+		t.Fatalf("expected an empty list of transitions, got %v", breakdown.Transitions)
+	}
+}
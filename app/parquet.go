@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+// parquetBatchSize is the number of rows read from every column at a time.
+const parquetBatchSize = 1024
+
+// parquetReader reads event logs stored as flat Parquet tables. Every column becomes a CSV column with the same name.
+type parquetReader struct{}
+
+func (parquetReader) Format() string {
+	return "parquet"
+}
+
+func (parquetReader) Detect(fileName, contentType string, head []byte) bool {
+	return strings.HasSuffix(fileName, ".parquet") ||
+		contentType == "application/vnd.apache.parquet" ||
+		bytes.HasPrefix(head, []byte("PAR1"))
+}
+
+func (parquetReader) ToCSV(srcPath, dstPath string, _ EventLogReaderOptions) (bool, error) {
+	fr, err := local.NewLocalFileReader(srcPath)
+	if err != nil {
+		return false, err
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetColumnReader(fr, 1)
+	if err != nil {
+		return false, fmt.Errorf("invalid Parquet file: %s", err.Error())
+	}
+	defer pr.ReadStop()
+
+	schemaHandler := pr.SchemaHandler
+
+	var (
+		header   []string
+		elements []*parquet.SchemaElement
+	)
+	for _, p := range schemaHandler.ValueColumns {
+		index := schemaHandler.MapIndex[p]
+		element := schemaHandler.SchemaElements[index]
+		name := schemaHandler.Infos[index].ExName
+
+		if strings.Count(p, "\x01") > 1 || element.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+			return false, fmt.Errorf("nested or repeated Parquet column %q is not supported", name)
+		}
+
+		header = append(header, name)
+		elements = append(elements, element)
+	}
+	if len(header) == 0 {
+		return false, fmt.Errorf("Parquet file has no columns")
+	}
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	buf := bufio.NewWriter(out)
+	w := csv.NewWriter(buf)
+	if err = w.Write(header); err != nil {
+		return false, err
+	}
+
+	numRows := pr.GetNumRows()
+	columns := make([][]interface{}, len(header))
+	record := make([]string, len(header))
+
+	for offset := int64(0); offset < numRows; offset += parquetBatchSize {
+		batch := numRows - offset
+		if batch > parquetBatchSize {
+			batch = parquetBatchSize
+		}
+
+		for i := range columns {
+			if columns[i], _, _, err = pr.ReadColumnByIndex(int64(i), batch); err != nil {
+				return false, fmt.Errorf("invalid Parquet file: %s", err.Error())
+			}
+			if int64(len(columns[i])) != batch {
+				return false, fmt.Errorf("invalid Parquet file: column %q has %d values instead of %d", header[i], len(columns[i]), batch)
+			}
+		}
+
+		for row := int64(0); row < batch; row++ {
+			for i := range columns {
+				record[i] = parquetValue(columns[i][row], elements[i])
+			}
+			if err = w.Write(record); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return false, err
+	}
+	if err = buf.Flush(); err != nil {
+		return false, err
+	}
+	return false, out.Close()
+}
+
+// parquetValue formats a Parquet value as text. Timestamps and dates are written in the canonical time layout, so that
+// the column mapping can point at them directly.
+func parquetValue(v interface{}, element *parquet.SchemaElement) string {
+	if v == nil {
+		return ""
+	}
+
+	logicalType := element.GetLogicalType()
+
+	switch value := v.(type) {
+	case int32:
+		if element.IsSetConvertedType() && element.GetConvertedType() == parquet.ConvertedType_DATE ||
+			logicalType != nil && logicalType.IsSetDATE() {
+			return types.TIMESTAMP_MILLISToTime(int64(value)*24*60*60*1000, true).Format("2006-01-02")
+		}
+		return strconv.FormatInt(int64(value), 10)
+
+	case int64:
+		if logicalType != nil && logicalType.IsSetTIMESTAMP() {
+			unit := logicalType.GetTIMESTAMP().GetUnit()
+			switch {
+			case unit.IsSetMILLIS():
+				return types.TIMESTAMP_MILLISToTime(value, true).Format(canonicalTimeLayout)
+			case unit.IsSetMICROS():
+				return types.TIMESTAMP_MICROSToTime(value, true).Format(canonicalTimeLayout)
+			case unit.IsSetNANOS():
+				return types.TIMESTAMP_NANOSToTime(value, true).Format(canonicalTimeLayout)
+			}
+		}
+		if element.IsSetConvertedType() {
+			switch element.GetConvertedType() {
+			case parquet.ConvertedType_TIMESTAMP_MILLIS:
+				return types.TIMESTAMP_MILLISToTime(value, true).Format(canonicalTimeLayout)
+			case parquet.ConvertedType_TIMESTAMP_MICROS:
+				return types.TIMESTAMP_MICROSToTime(value, true).Format(canonicalTimeLayout)
+			}
+		}
+		return strconv.FormatInt(value, 10)
+
+	case string:
+		if element.GetType() == parquet.Type_INT96 {
+			return types.INT96ToTime(value).Format(canonicalTimeLayout)
+		}
+		return value
+
+	case float32:
+		return strconv.FormatFloat(float64(value), 'g', -1, 32)
+
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// checkDependencies checks that the jobs the job depends on are in the queue.
+func (app *Application) checkDependencies(job *model.Job) error {
+	for _, id := range job.DependsOn {
+		if app.queue.FindByID(id) == nil {
+			return fmt.Errorf("dependency %s not found", id)
+		}
+	}
+	return nil
+}
+
+// copyInputEventLog copies the analysed event log of the job's input_from parent into the job's directory and returns
+// its name. The job takes the parent's column mapping unless it has one of its own, because the parent's event log has
+// been normalized with it.
+func (app *Application) copyInputEventLog(job *model.Job) (string, error) {
+	parent := app.queue.FindByID(job.InputFrom)
+	if parent == nil {
+		return "", fmt.Errorf("input job %s not found", job.InputFrom)
+	}
+	name := parent.EventLogFileName()
+	if parent.Dir == "" || name == "" || parent.EventLogDeletedAt != nil {
+		return "", fmt.Errorf("input job %s has no event log", parent.ID)
+	}
+
+	if err := mkdir(job.Dir); err != nil {
+		return "", err
+	}
+	if err := copyRegularFile(path.Join(parent.Dir, name), path.Join(job.Dir, name)); err != nil {
+		return "", fmt.Errorf("error copying the event log of input job %s: %s", parent.ID, err.Error())
+	}
+
+	if job.ColumnMapping == nil {
+		job.SetColumnMapping(parent.ColumnMapping)
+	}
+	return name, nil
+}
+
+// sortPipeline returns the jobs of a pipeline with every job after the jobs of the pipeline it depends on. It returns an
+// error if the names aren't unique or the jobs depend on each other in a cycle.
+func sortPipeline(jobs []*model.ApiPipelineJob) ([]*model.ApiPipelineJob, error) {
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("pipeline has no jobs")
+	}
+
+	byName := map[string]*model.ApiPipelineJob{}
+	for _, job := range jobs {
+		if job == nil {
+			return nil, fmt.Errorf("pipeline job is null")
+		}
+		if byName[job.Name] != nil {
+			return nil, fmt.Errorf("pipeline job name %q is not unique", job.Name)
+		}
+		byName[job.Name] = job
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	sorted := make([]*model.ApiPipelineJob, 0, len(jobs))
+
+	var visit func(job *model.ApiPipelineJob) error
+	visit = func(job *model.ApiPipelineJob) error {
+		switch state[job.Name] {
+		case visiting:
+			return fmt.Errorf("pipeline has a dependency cycle through job %q", job.Name)
+		case visited:
+			return nil
+		}
+
+		state[job.Name] = visiting
+		for _, name := range job.DependsOn {
+			if parent := byName[name]; parent != nil {
+				if err := visit(parent); err != nil {
+					return err
+				}
+			}
+		}
+		state[job.Name] = visited
+		sorted = append(sorted, job)
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := visit(job); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestSortPipeline(t *testing.T) {
+	job := func(name string, dependsOn ...string) *model.ApiPipelineJob {
+		return &model.ApiPipelineJob{Name: name, ApiRequest: model.ApiRequest{DependsOn: dependsOn}}
+	}
+
+	tests := []struct {
+		name    string
+		jobs    []*model.ApiPipelineJob
+		want    string
+		wantErr string
+	}{
+		{
+			name: "dependencies first",
+			jobs: []*model.ApiPipelineJob{job("report", "wt", "calendars"), job("wt", "filter"), job("calendars", "filter"), job("filter")},
+			want: "filter,wt,calendars,report",
+		},
+		{
+			name: "job in the queue",
+			jobs: []*model.ApiPipelineJob{job("wt", "4f1c9d2e")},
+			want: "wt",
+		},
+		{
+			name:    "cycle",
+			jobs:    []*model.ApiPipelineJob{job("a", "c"), job("b", "a"), job("c", "b")},
+			wantErr: "cycle",
+		},
+		{
+			name:    "duplicate name",
+			jobs:    []*model.ApiPipelineJob{job("a"), job("a")},
+			wantErr: "not unique",
+		},
+		{
+			name:    "no jobs",
+			wantErr: "no jobs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, err := sortPipeline(tt.jobs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error with %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, job := range sorted {
+				names = append(names, job.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPostPipeline(t *testing.T) {
+	logs := httptest.NewServer(http.FileServer(http.Dir("../assets/samples")))
+	defer logs.Close()
+
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+	app.config.DownloadAllowList = []string{"127.0.0.1"}
+	if app.fetcher, err = NewFetcher(app.config, app.logger); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	runner := &fakeRunner{}
+	app.runner = runner
+
+	post := func(body string) (int, *model.ApiPipelineResponse) {
+		res, err := http.Post(ts.URL+"/pipelines", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		var response model.ApiPipelineResponse
+		if res.StatusCode == http.StatusCreated {
+			if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			for _, job := range response.Jobs {
+				job := app.queue.FindByID(job.ID)
+				t.Cleanup(func() {
+					if err := app.queue.Remove(job, true); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		}
+		return res.StatusCode, &response
+	}
+	// process runs the jobs the queue releases one after another like a worker
+	process := func() {
+		for job := app.queue.Claim(); job != nil; job = app.queue.Claim() {
+			app.processJob(job)
+			app.queue.Release(job)
+		}
+	}
+
+	t.Run("dependents run after their parents", func(t *testing.T) {
+		runner.run = func(_ context.Context, cmd *AnalysisCommand) error {
+			return os.WriteFile(cmd.Args[2], []byte(`[]`), 0644)
+		}
+
+		statusCode, response := post(fmt.Sprintf(`{"jobs": [
+			{"name": "batching", "kind": "batching_discovery", "depends_on": ["filter"], "input_from": "filter"},
+			{"name": "filter", "kind": "calendar_discovery", "event_log": "%s/manual_log_5.csv",
+				"preprocessing": {"exclude_activities": ["D"]}},
+			{"name": "report", "kind": "calendar_discovery", "event_log": "%s/manual_log_5.csv", "force": true,
+				"depends_on": ["batching", "filter"]}
+		]}`, logs.URL, logs.URL))
+		if statusCode != http.StatusCreated || len(response.Jobs) != 3 {
+			t.Fatalf("expected status code %d and 3 jobs, got %d and %+v", http.StatusCreated, statusCode, response.Jobs)
+		}
+		filter := app.queue.FindByID(response.Jobs["filter"].ID)
+		batching := app.queue.FindByID(response.Jobs["batching"].ID)
+		report := app.queue.FindByID(response.Jobs["report"].ID)
+		if batching.InputFrom != filter.ID || len(report.DependsOn) != 2 || report.DependsOn[0] != batching.ID {
+			t.Fatalf("expected the names to be replaced with the IDs, got %+v and %+v", batching, report)
+		}
+
+		if job := app.queue.Claim(); job != filter {
+			t.Fatalf("expected the filter to be released first, got %+v", job)
+		}
+		if job := app.queue.Claim(); job != nil {
+			t.Fatalf("expected the dependents to wait, got %+v", job)
+		}
+		app.processJob(filter)
+		app.queue.Release(filter)
+		process()
+
+		for _, job := range []*model.Job{filter, batching, report} {
+			if job.Status != model.JobStatusCompleted {
+				t.Fatalf("expected job %s to complete, got status %s and error %q", job.ID, job.Status, job.Error)
+			}
+		}
+
+		// the batching discovery has analysed the filtered event log
+		filtered, err := os.ReadFile(path.Join(filter.Dir, filter.EventLogFileName()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		input, err := os.ReadFile(path.Join(batching.Dir, batching.EventLogFileName()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(input, filtered) || bytes.Contains(input, []byte(",D,")) {
+			t.Fatalf("expected the filtered event log as the input, got %s", input)
+		}
+	})
+
+	t.Run("failure propagates to the dependents", func(t *testing.T) {
+		runner.run = func(_ context.Context, cmd *AnalysisCommand) error {
+			return fmt.Errorf("exit status 1")
+		}
+
+		statusCode, response := post(fmt.Sprintf(`{"jobs": [
+			{"name": "a", "kind": "calendar_discovery", "event_log": "%s/manual_log_5.csv", "force": true},
+			{"name": "b", "kind": "calendar_discovery", "depends_on": ["a"], "input_from": "a"},
+			{"name": "c", "kind": "calendar_discovery", "depends_on": ["b"], "input_from": "b"}
+		]}`, logs.URL))
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, statusCode)
+		}
+		process()
+
+		for name, parent := range map[string]string{"b": "a", "c": "b"} {
+			job := app.queue.FindByID(response.Jobs[name].ID)
+			want := fmt.Sprintf("dependency %s has failed", response.Jobs[parent].ID)
+			if job.Status != model.JobStatusFailed || !strings.Contains(job.Error, want) {
+				t.Fatalf("expected job %s to fail with %q, got status %s and error %q", name, want, job.Status, job.Error)
+			}
+		}
+	})
+
+	t.Run("invalid pipelines", func(t *testing.T) {
+		jobs := len(app.queue.Snapshot())
+		for _, body := range []string{
+			`{"jobs": [{"name": "a", "depends_on": ["b"], "input_from": "b"}, {"name": "b", "depends_on": ["a"], "input_from": "a"}]}`,
+			`{"jobs": [{"name": "a", "depends_on": ["missing"], "input_from": "missing"}]}`,
+			`{"jobs": [{"name": "a", "input_from": "b"}, {"name": "b", "event_log": "http://example.com/log.csv"}]}`,
+			`{"jobs": [{"event_log": "http://example.com/log.csv"}]}`,
+		} {
+			if statusCode, _ := post(body); statusCode != http.StatusBadRequest {
+				t.Fatalf("expected status code %d for %s, got %d", http.StatusBadRequest, body, statusCode)
+			}
+		}
+		if len(app.queue.Snapshot()) != jobs {
+			t.Fatal("expected no jobs of invalid pipelines to be queued")
+		}
+	})
+}
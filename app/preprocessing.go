@@ -0,0 +1,280 @@
+package app
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// unfilteredSuffix is appended to the name of the original event log when it's replaced by the preprocessed one.
+const unfilteredSuffix = "_unfiltered"
+
+// preprocessedCase holds what the case filters need to know about a case.
+type preprocessedCase struct {
+	start, end time.Time
+	events     int // events left after the event filters
+	keep       bool
+}
+
+// preprocessEventLog applies the preprocessing to the CSV event log name in dir. The original log is kept with the
+// unfilteredSuffix and the filtered one takes its name, so that the analysis and its report names stay the same. If the
+// original log is already there, e.g., when a job is restarted, it's filtered again instead of the filtered one.
+func preprocessEventLog(dir, name string, columnMapping *model.ColumnMapping, p *model.Preprocessing) (*model.PreprocessingSummary, error) {
+	if columnMapping == nil {
+		columnMapping = &canonicalColumnMapping
+	}
+
+	ext := path.Ext(name)
+	unfilteredName := strings.TrimSuffix(name, ext) + unfilteredSuffix + ext
+	filePath := path.Join(dir, name)
+	unfilteredPath := path.Join(dir, unfilteredName)
+
+	if _, err := os.Stat(unfilteredPath); os.IsNotExist(err) {
+		if err = os.Rename(filePath, unfilteredPath); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	summary := &model.PreprocessingSummary{UnfilteredEventLogName: unfilteredName}
+
+	// the first pass applies the event filters and collects the cases
+	keepEvents := []bool{}
+	cases := map[string]*preprocessedCase{}
+	err := readEventLog(unfilteredPath, columnMapping, nil, func(event *preprocessedEvent) error {
+		c, ok := cases[event.caseID]
+		if !ok {
+			c = &preprocessedCase{start: event.start, end: event.end}
+			cases[event.caseID] = c
+		}
+		if event.start.Before(c.start) {
+			c.start = event.start
+		}
+		if event.end.After(c.end) {
+			c.end = event.end
+		}
+
+		keep := keepEvent(p, event)
+		if keep {
+			c.events++
+		}
+		keepEvents = append(keepEvents, keep)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary.EventsBefore = len(keepEvents)
+	summary.CasesBefore = len(cases)
+
+	for caseID, c := range cases {
+		c.keep = keepCase(p, caseID, c)
+		if c.keep {
+			summary.CasesAfter++
+		}
+	}
+
+	// the second pass writes the events kept
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+
+	i := 0
+	err = readEventLog(unfilteredPath, columnMapping, w.Write, func(event *preprocessedEvent) error {
+		keep := keepEvents[i] && cases[event.caseID].keep
+		i++
+		if !keep {
+			return nil
+		}
+		summary.EventsAfter++
+		return w.Write(event.record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return nil, err
+	}
+
+	if summary.EventsAfter == 0 {
+		return summary, fmt.Errorf("preprocessing removed all %d events of the event log", summary.EventsBefore)
+	}
+
+	return summary, nil
+}
+
+// preprocessedEvent is a row of an event log with the values of the mapped columns.
+type preprocessedEvent struct {
+	record     []string
+	caseID     string
+	activity   string
+	resource   string
+	start, end time.Time
+}
+
+// readEventLog calls onHeader, if given, for the header of the CSV event log at filePath and fn for every row. The log is
+// expected to be validated already, so a timestamp which doesn't parse is an error.
+func readEventLog(filePath string, columnMapping *model.ColumnMapping, onHeader func([]string) error, fn func(event *preprocessedEvent) error) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(bufio.NewReader(f))
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %s", err.Error())
+	}
+	if onHeader != nil {
+		if err = onHeader(header); err != nil {
+			return err
+		}
+	}
+
+	indices := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\xef\xbb\xbf")
+		}
+		indices[strings.TrimSpace(name)] = i
+	}
+
+	columns := columnMapping.Roles()
+	index := map[string]int{}
+	for key, column := range columns {
+		i, ok := indices[column]
+		if !ok {
+			return fmt.Errorf("%s column %q is missing", key, column)
+		}
+		index[key] = i
+	}
+
+	startParser := newTimestampParser(columnMapping, columns[model.ColumnMappingStartTimestamp], nil)
+	endParser := newTimestampParser(columnMapping, columns[model.ColumnMappingEndTimestamp], nil)
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid CSV: %s", err.Error())
+		}
+
+		event := &preprocessedEvent{
+			record:   record,
+			caseID:   record[index[model.ColumnMappingCase]],
+			activity: strings.TrimSpace(record[index[model.ColumnMappingActivity]]),
+			resource: strings.TrimSpace(record[index[model.ColumnMappingResource]]),
+		}
+		if event.start, err = startParser.Parse(record[index[model.ColumnMappingStartTimestamp]]); err != nil {
+			return err
+		}
+		if event.end, err = endParser.Parse(record[index[model.ColumnMappingEndTimestamp]]); err != nil {
+			return err
+		}
+
+		if err = fn(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func keepEvent(p *model.Preprocessing, event *preprocessedEvent) bool {
+	if len(p.IncludeActivities) > 0 && !contains(p.IncludeActivities, event.activity) {
+		return false
+	}
+	if contains(p.ExcludeActivities, event.activity) {
+		return false
+	}
+	if len(p.IncludeResources) > 0 && !contains(p.IncludeResources, event.resource) {
+		return false
+	}
+	if contains(p.ExcludeResources, event.resource) {
+		return false
+	}
+
+	if p.TimeRangeMode() == model.TimeRangeModeEvents && !withinTimeRange(p.TimeRange, event.start, event.end) {
+		return false
+	}
+
+	return true
+}
+
+func keepCase(p *model.Preprocessing, caseID string, c *preprocessedCase) bool {
+	if c.events == 0 || c.events < p.MinCaseLength {
+		return false
+	}
+
+	switch p.TimeRangeMode() {
+	case model.TimeRangeModeContained:
+		if !withinTimeRange(p.TimeRange, c.start, c.end) {
+			return false
+		}
+	case model.TimeRangeModeIntersecting:
+		if p.TimeRange.From != nil && c.end.Before(*p.TimeRange.From) || p.TimeRange.To != nil && c.start.After(*p.TimeRange.To) {
+			return false
+		}
+	}
+
+	if p.CaseSampleRatio > 0 && caseSample(p.Seed, caseID) >= p.CaseSampleRatio {
+		return false
+	}
+
+	return true
+}
+
+func withinTimeRange(r *model.TimeRange, start, end time.Time) bool {
+	if r == nil {
+		return true
+	}
+	if r.From != nil && start.Before(*r.From) {
+		return false
+	}
+	if r.To != nil && end.After(*r.To) {
+		return false
+	}
+	return true
+}
+
+// caseSample maps a case to a number in [0, 1) which depends only on the seed and the case ID, so that the sample doesn't
+// change with the order of the events or between runs.
+func caseSample(seed int64, caseID string) float64 {
+	h := sha256.New()
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(seed))
+	_, _ = h.Write(b[:])
+	_, _ = h.Write([]byte(caseID))
+
+	return float64(binary.LittleEndian.Uint64(h.Sum(nil))>>11) / (1 << 53)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestPreprocessEventLog(t *testing.T) {
+	const content = "case:concept:name,concept:name,start_timestamp,time:timestamp,org:resource\n" +
+		"1,A,2022-01-10T10:00:00,2022-01-10T11:00:00,Marcus\n" +
+		"1,B,2022-01-10T12:00:00,2022-01-10T13:00:00,Anya\n" +
+		"1,C,2022-04-02T12:00:00,2022-04-02T13:00:00,Anya\n" +
+		"2,A,2022-02-01T10:00:00,2022-02-01T11:00:00,Marcus\n" +
+		"2,C,2022-02-02T10:00:00,2022-02-02T11:00:00,Cole\n" +
+		"3,A,2022-05-01T10:00:00,2022-05-01T11:00:00,Cole\n"
+
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		preprocessing *model.Preprocessing
+		wantRows      []string // case and activity of the rows kept
+		wantErr       bool
+	}{
+		{
+			name:          "events in time range",
+			preprocessing: &model.Preprocessing{TimeRange: &model.TimeRange{From: &from, To: &to}},
+			wantRows:      []string{"1A", "1B", "2A", "2C"},
+		},
+		{
+			name:          "cases contained in time range",
+			preprocessing: &model.Preprocessing{TimeRange: &model.TimeRange{From: &from, To: &to, Mode: model.TimeRangeModeContained}},
+			wantRows:      []string{"2A", "2C"},
+		},
+		{
+			name:          "cases intersecting time range",
+			preprocessing: &model.Preprocessing{TimeRange: &model.TimeRange{From: &from, To: &to, Mode: model.TimeRangeModeIntersecting}},
+			wantRows:      []string{"1A", "1B", "1C", "2A", "2C"},
+		},
+		{
+			name:          "activities and resources",
+			preprocessing: &model.Preprocessing{IncludeActivities: []string{"A", "C"}, ExcludeResources: []string{"Cole"}},
+			wantRows:      []string{"1A", "1C", "2A"},
+		},
+		{
+			name:          "minimum case length after event filters",
+			preprocessing: &model.Preprocessing{ExcludeActivities: []string{"B"}, MinCaseLength: 2},
+			wantRows:      []string{"1A", "1C", "2A", "2C"},
+		},
+		{
+			name:          "everything removed",
+			preprocessing: &model.Preprocessing{IncludeResources: []string{"Nobody"}},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(path.Join(dir, "log.csv"), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			summary, err := preprocessEventLog(dir, "log.csv", nil, tt.preprocessing)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rows := preprocessedRows(t, path.Join(dir, "log.csv"))
+			if fmt.Sprint(rows) != fmt.Sprint(tt.wantRows) {
+				t.Fatalf("unexpected rows\n got: %v\nwant: %v", rows, tt.wantRows)
+			}
+
+			if summary.EventsBefore != 6 || summary.CasesBefore != 3 || summary.EventsAfter != len(tt.wantRows) {
+				t.Fatalf("unexpected summary %+v", summary)
+			}
+
+			// the original log is kept and a second run filters it again
+			if _, err = preprocessEventLog(dir, "log.csv", nil, tt.preprocessing); err != nil {
+				t.Fatal(err)
+			}
+			if rows := preprocessedRows(t, path.Join(dir, "log"+unfilteredSuffix+".csv")); len(rows) != 6 {
+				t.Fatalf("expected the unfiltered log to have 6 rows, got %d", len(rows))
+			}
+		})
+	}
+}
+
+func TestPreprocessEventLog_Sampling(t *testing.T) {
+	var content bytes.Buffer
+	content.WriteString("case:concept:name,concept:name,start_timestamp,time:timestamp,org:resource\n")
+	for i := 0; i < 1000; i++ {
+		content.WriteString(fmt.Sprintf("%d,A,2022-01-10T10:00:00,2022-01-10T11:00:00,Marcus\n", i))
+	}
+
+	sample := func(seed int64) []string {
+		dir := t.TempDir()
+		if err := os.WriteFile(path.Join(dir, "log.csv"), content.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		summary, err := preprocessEventLog(dir, "log.csv", nil, &model.Preprocessing{CaseSampleRatio: 0.1, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.CasesAfter < 60 || summary.CasesAfter > 140 {
+			t.Fatalf("expected about 100 cases, got %d", summary.CasesAfter)
+		}
+		return preprocessedRows(t, path.Join(dir, "log.csv"))
+	}
+
+	if fmt.Sprint(sample(42)) != fmt.Sprint(sample(42)) {
+		t.Fatal("expected the same sample for the same seed")
+	}
+	if fmt.Sprint(sample(42)) == fmt.Sprint(sample(7)) {
+		t.Fatal("expected different samples for different seeds")
+	}
+}
+
+func TestPostJob_InvalidPreprocessing(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	bodies := []string{
+		`{"event_log":"http://example.com/log.csv","preprocessing":{"case_sample_ratio":1.5}}`,
+		`{"event_log":"http://example.com/log.csv","preprocessing":{"include_activities":["A"],"exclude_activities":["A"]}}`,
+		`{"event_log":"http://example.com/log.csv","preprocessing":{"time_range":{"mode":"weekly"}}}`,
+		`{"event_log":"http://example.com/log.csv","preprocessing":{"sample":0.5}}`,
+	}
+
+	for _, body := range bodies {
+		res, err := http.Post(ts.URL+"/jobs", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status code %d for %s, got %d", http.StatusBadRequest, body, res.StatusCode)
+		}
+	}
+}
+
+func preprocessedRows(t *testing.T, filePath string) []string {
+	f, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []string
+	for _, record := range records[1:] {
+		rows = append(rows, record[0]+record[1])
+	}
+	return rows
+}
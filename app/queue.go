@@ -11,29 +11,64 @@ import (
 
 type Queue struct {
 	Jobs []*model.Job
+	// Batches group the jobs submitted together, they refer to the jobs by their IDs
+	Batches []*model.Batch
 
-	lock sync.Mutex
+	// claimed holds the IDs of the jobs taken by the workers
+	claimed map[string]bool
+	lock    sync.Mutex
 }
 
 func NewQueue() *Queue {
 	return &Queue{}
 }
 
-// Add adds a job to the queue if it's not yet present there.
-func (q *Queue) Add(job *model.Job) error {
-	if job == nil {
-		return fmt.Errorf("job is nil")
+// Add adds jobs to the queue, either all of them or none if one is already present there.
+func (q *Queue) Add(jobs ...*model.Job) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.add(jobs)
+}
+
+// AddBatch adds the batch with its jobs to the queue, so that no job runs before its batch is there.
+func (q *Queue) AddBatch(batch *model.Batch, jobs ...*model.Job) error {
+	if batch == nil {
+		return fmt.Errorf("batch is nil")
 	}
 
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
+	for _, b := range q.Batches {
+		if b.ID == batch.ID {
+			return fmt.Errorf("batch already present in queue")
+		}
+	}
+	if err := q.add(jobs); err != nil {
+		return err
+	}
+	q.Batches = append(q.Batches, batch)
+
+	return nil
+}
+
+// add adds the jobs unless one is nil or present already. It has to be called with the lock held.
+func (q *Queue) add(jobs []*model.Job) error {
+	present := map[*model.Job]bool{}
 	for _, j := range q.Jobs {
-		if j == job {
+		present[j] = true
+	}
+	for _, job := range jobs {
+		if job == nil {
+			return fmt.Errorf("job is nil")
+		}
+		if present[job] {
 			return fmt.Errorf("job already present in queue")
 		}
+		present[job] = true
 	}
-	q.Jobs = append(q.Jobs, job)
+	q.Jobs = append(q.Jobs, jobs...)
 
 	return nil
 }
@@ -67,6 +102,20 @@ func (q *Queue) Remove(job *model.Job, removeFiles bool) error {
 	return nil
 }
 
+// Snapshot returns a copy of the list of jobs, which is safe to iterate while jobs are added and removed.
+func (q *Queue) Snapshot() []*model.Job {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	jobs := make([]*model.Job, 0, len(q.Jobs))
+	for _, j := range q.Jobs {
+		if j != nil {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
 // FindByID finds a job by its ID.
 func (q *Queue) FindByID(id string) *model.Job {
 	for _, j := range q.Jobs {
@@ -81,6 +130,47 @@ func (q *Queue) FindByID(id string) *model.Job {
 	return nil
 }
 
+// FindBatchByID finds a batch by its ID.
+func (q *Queue) FindBatchByID(id string) *model.Batch {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, b := range q.Batches {
+		if b != nil && b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// BatchSnapshot returns a copy of the list of batches.
+func (q *Queue) BatchSnapshot() []*model.Batch {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	batches := make([]*model.Batch, 0, len(q.Batches))
+	for _, b := range q.Batches {
+		if b != nil {
+			batches = append(batches, b)
+		}
+	}
+	return batches
+}
+
+// RemoveBatch removes a batch from the queue, its jobs are kept.
+func (q *Queue) RemoveBatch(batch *model.Batch) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var batches []*model.Batch
+	for _, b := range q.Batches {
+		if b != batch {
+			batches = append(batches, b)
+		}
+	}
+	q.Batches = batches
+}
+
 // FindByMD5 finds a job by the event log's MD5 hash. Returns nil if not found.
 func (q *Queue) FindByMD5(md5 string) *model.Job {
 	for _, j := range q.Jobs {
@@ -95,32 +185,135 @@ func (q *Queue) FindByMD5(md5 string) *model.Job {
 	return nil
 }
 
-// Next finds the first pending job in the queue.
+// FindCompletedByCacheKey finds a completed job other than the one with the given ID by its cache key. Duplicates aren't
+// returned, so that a new duplicate links to the job which has run the analysis. Returns nil if not found.
+func (q *Queue) FindCompletedByCacheKey(cacheKey, exceptID string) *model.Job {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, j := range q.Jobs {
+		if j == nil {
+			continue
+		}
+
+		if j.CacheKey == cacheKey && j.ID != exceptID && j.Status == model.JobStatusCompleted {
+			return j
+		}
+	}
+	return nil
+}
+
+// Next finds the first pending job in the queue whose parents have completed.
 func (q *Queue) Next() *model.Job {
 	q.sort()
 
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
 	for _, j := range q.Jobs {
 		if j == nil {
 			continue
 		}
 
-		if j.Status == model.JobStatusPending {
+		if completed, _ := q.dependencyState(j); j.Status == model.JobStatusPending && completed {
 			return j
 		}
 	}
 	return nil
 }
 
-// Clear empties the queue and removes related disk data.
+// Claim finds the first pending job which no worker has claimed yet and claims it until Release is called. The job's
+// parents have completed, or one of them has failed, so that the worker fails the job, see FailedDependency.
+func (q *Queue) Claim() *model.Job {
+	q.sort()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.claimed == nil {
+		q.claimed = map[string]bool{}
+	}
+
+	for _, j := range q.Jobs {
+		if j == nil {
+			continue
+		}
+
+		if j.Status != model.JobStatusPending || q.claimed[j.ID] {
+			continue
+		}
+
+		if completed, failed := q.dependencyState(j); completed || failed != "" {
+			q.claimed[j.ID] = true
+			return j
+		}
+	}
+	return nil
+}
+
+// FailedDependency returns the ID of the first parent of the job which has failed or isn't in the queue anymore, or an
+// empty string if there's none.
+func (q *Queue) FailedDependency(job *model.Job) string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	_, failed := q.dependencyState(job)
+	return failed
+}
+
+// dependencyState reports whether the parents of the job have completed, duplicates count as completed. It returns the
+// ID of the first parent which has failed or isn't in the queue anymore otherwise, the job can never run then. It has
+// to be called with the lock held.
+func (q *Queue) dependencyState(job *model.Job) (completed bool, failed string) {
+	completed = true
+	for _, id := range job.DependsOn {
+		parent := q.FindByID(id)
+		switch {
+		case parent == nil || parent.Status == model.JobStatusFailed:
+			return false, id
+		case parent.Status != model.JobStatusCompleted && parent.Status != model.JobStatusDuplicate:
+			completed = false
+		}
+	}
+	return completed, ""
+}
+
+// Release gives up a job claimed by Claim.
+func (q *Queue) Release(job *model.Job) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.claimed, job.ID)
+}
+
+// CountPending returns the number of jobs waiting to be processed.
+func (q *Queue) CountPending() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	count := 0
+	for _, j := range q.Jobs {
+		if j != nil && j.Status == model.JobStatusPending {
+			count++
+		}
+	}
+	return count
+}
+
+// Clear empties the queue and removes related disk data. It refuses to while jobs are running or claimed by workers.
 func (q *Queue) Clear() error {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
 	runningJobsCount := q.countRunningJobs()
-	if q.countRunningJobs() > 0 {
+	if runningJobsCount > 0 {
 		return fmt.Errorf("cannot clear queue while there are %d running jobs", runningJobsCount)
 	}
 
+	if claimedJobsCount := len(q.claimed); claimedJobsCount > 0 {
+		return fmt.Errorf("cannot clear queue while there are %d jobs claimed by workers", claimedJobsCount)
+	}
+
 	for _, j := range q.Jobs {
 		if j == nil {
 			continue
@@ -136,6 +329,7 @@ func (q *Queue) Clear() error {
 	}
 
 	q.Jobs = []*model.Job{}
+	q.Batches = nil
 
 	return nil
 }
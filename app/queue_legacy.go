@@ -0,0 +1,79 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// legacyQueue is the format of the queue saved by the versions which kept the column mappings as plain maps. gob can't
+// read such a queue into Queue, because the column mapping's type has changed under the same field name.
+type legacyQueue struct {
+	Jobs []*legacyJob
+}
+
+// legacyJob has the fields of model.Job at the time, the later ones are simply missing from such a queue.
+type legacyJob struct {
+	ID                      string
+	Status                  model.JobStatus
+	Error                   string
+	Result                  *model.JobResult
+	ReportCSV               *model.URL
+	CallbackEndpoint        string
+	CallbackEndpointURL     *model.URL
+	EventLog                string
+	EventLogURL             *model.URL
+	EventLogMD5             string
+	EventLogName            string
+	EventLogFormat          string
+	OCELObjectType          string
+	EventLogFromRequestBody bool
+	Download                *model.EventLogDownload
+	Diagnostics             []*model.EventLogDiagnostic
+	CreatedAt               time.Time
+	CompletedAt             *time.Time
+	ColumnMapping           map[string]string
+	Dir                     string
+}
+
+// job converts the legacy job to a model.Job.
+func (j *legacyJob) job() *model.Job {
+	return &model.Job{
+		ID:                      j.ID,
+		Status:                  j.Status,
+		Error:                   j.Error,
+		Result:                  j.Result,
+		ReportCSV:               j.ReportCSV,
+		CallbackEndpoint:        j.CallbackEndpoint,
+		CallbackEndpointURL:     j.CallbackEndpointURL,
+		EventLog:                j.EventLog,
+		EventLogURL:             j.EventLogURL,
+		EventLogMD5:             j.EventLogMD5,
+		EventLogName:            j.EventLogName,
+		EventLogFormat:          j.EventLogFormat,
+		OCELObjectType:          j.OCELObjectType,
+		EventLogFromRequestBody: j.EventLogFromRequestBody,
+		Download:                j.Download,
+		Diagnostics:             j.Diagnostics,
+		CreatedAt:               j.CreatedAt,
+		CompletedAt:             j.CompletedAt,
+		ColumnMapping:           model.ColumnMappingFromRoles(j.ColumnMapping),
+		Dir:                     j.Dir,
+	}
+}
+
+// readLegacyQueue reads a queue saved in the legacy format into the queue.
+func readLegacyQueue(path string, queue *Queue, logger *log.Logger) error {
+	var legacy legacyQueue
+	if err := readGob(path, &legacy, logger); err != nil {
+		return err
+	}
+
+	queue.Jobs = make([]*model.Job, 0, len(legacy.Jobs))
+	for _, job := range legacy.Jobs {
+		queue.Jobs = append(queue.Jobs, job.job())
+	}
+	queue.Batches = nil
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
 	"io/fs"
 	"os"
+	"path"
 	"testing"
 	"time"
 )
@@ -63,6 +64,36 @@ func TestQueue_Clear(t *testing.T) {
 	}
 }
 
+func TestQueue_Clear_Claimed(t *testing.T) {
+	job := &model.Job{ID: "claimed", Status: model.JobStatusPending, CreatedAt: time.Now(), Dir: t.TempDir()}
+
+	q := NewQueue()
+	if err := q.Add(job); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed := q.Claim(); claimed != job {
+		t.Fatalf("expected the job to be claimed, got %+v", claimed)
+	}
+	if err := q.Clear(); err == nil {
+		t.Fatal("expected the queue not to be cleared while a job is claimed")
+	}
+	if len(q.Jobs) != 1 {
+		t.Fatalf("expected the claimed job to stay in the queue, got %d jobs", len(q.Jobs))
+	}
+	if _, err := os.Stat(job.Dir); err != nil {
+		t.Fatalf("expected the claimed job's directory to be kept, got %s", err)
+	}
+
+	q.Release(job)
+	if err := q.Clear(); err != nil {
+		t.Fatalf("expected the queue to be cleared once the job is released, got %s", err)
+	}
+	if len(q.Jobs) != 0 {
+		t.Fatalf("expected no jobs, got %d", len(q.Jobs))
+	}
+}
+
 func TestQueue_ClearOld(t *testing.T) {
 	const resultsDir = "../assets/results"
 	var rootFS = os.DirFS(resultsDir)
@@ -153,3 +184,96 @@ func TestQueue_ClearOld(t *testing.T) {
 		})
 	}
 }
+
+func TestQueue_Dependencies(t *testing.T) {
+	now := time.Now()
+	parent := &model.Job{ID: "parent", Status: model.JobStatusPending, CreatedAt: now}
+	child := &model.Job{ID: "child", Status: model.JobStatusPending, CreatedAt: now.Add(-time.Second), DependsOn: []string{"parent"}}
+
+	q := NewQueue()
+	if err := q.Add(child, parent); err != nil {
+		t.Fatal(err)
+	}
+
+	if job := q.Next(); job != parent {
+		t.Fatalf("expected the parent before its older child, got %+v", job)
+	}
+	if job := q.Claim(); job != parent {
+		t.Fatalf("expected the parent to be claimed, got %+v", job)
+	}
+	if job := q.Claim(); job != nil {
+		t.Fatalf("expected the child to wait for its parent, got %+v", job)
+	}
+
+	parent.Status = model.JobStatusDuplicate
+	q.Release(parent)
+	if job := q.Next(); job != child {
+		t.Fatalf("expected the child once its parent has completed, got %+v", job)
+	}
+
+	// a child of a failed parent is released to fail, but it's never next to run
+	parent.Status = model.JobStatusFailed
+	if job := q.Next(); job != nil {
+		t.Fatalf("expected no job to run, got %+v", job)
+	}
+	if job := q.Claim(); job != child || q.FailedDependency(child) != parent.ID {
+		t.Fatalf("expected the child to be released with its failed parent, got %+v", job)
+	}
+
+	if err := q.Add(parent); err == nil {
+		t.Fatal("expected a job not to be added twice")
+	}
+}
+
+func TestApplication_LoadQueue_LegacyColumnMapping(t *testing.T) {
+	// the format of the queue before the column mapping became a model.ColumnMapping
+	type job struct {
+		ID            string
+		Status        model.JobStatus
+		EventLog      string
+		CreatedAt     time.Time
+		ColumnMapping map[string]string
+	}
+	type queue struct {
+		Jobs []*job
+	}
+
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+
+	legacy := queue{Jobs: []*job{
+		{ID: "mapped", Status: model.JobStatusCompleted, EventLog: "http://example.com/log.csv", ColumnMapping: map[string]string{
+			"case": "Case", "activity": "Activity", "resource": "Resource",
+			"start_timestamp": "Start", "end_timestamp": "End",
+		}},
+		{ID: "unmapped", Status: model.JobStatusPending, EventLog: "http://example.com/log.csv"},
+	}}
+	if err = dumpGob(app.config.QueuePath, legacy, app.logger); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.LoadQueue(); err != nil {
+		t.Fatal(err)
+	}
+
+	mapped := app.queue.FindByID("mapped")
+	if mapped == nil || mapped.Status != model.JobStatusCompleted || mapped.ColumnMapping == nil ||
+		mapped.ColumnMapping.Case != "Case" || mapped.ColumnMapping.EndTimestamp != "End" {
+		t.Fatalf("expected the job with its column mapping, got %+v", mapped)
+	}
+	if unmapped := app.queue.FindByID("unmapped"); unmapped == nil || unmapped.ColumnMapping != nil {
+		t.Fatalf("expected the job without a column mapping, got %+v", unmapped)
+	}
+
+	// the queue is saved in the current format from then on
+	if err = app.SaveQueue(); err != nil {
+		t.Fatal(err)
+	}
+	if err = readGob(app.config.QueuePath, NewQueue(), app.logger); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+const (
+	auditActionJobDeleted      = "job_deleted"
+	auditActionEventLogDeleted = "event_log_deleted"
+)
+
+// auditRecord describes a deletion in the audit log.
+type auditRecord struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	JobID  string    `json:"job_id"`
+	Reason string    `json:"reason"`
+	// Files are the paths of the deleted files or directories.
+	Files []string `json:"files,omitempty"`
+}
+
+// auditLog appends records to a JSON-lines file. Nothing is written if the path is empty.
+type auditLog struct {
+	path string
+	lock sync.Mutex
+}
+
+func (a *auditLog) Record(record auditRecord) error {
+	if a.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// audit records a deletion in the audit log and the application's log.
+func (app *Application) audit(action string, job *model.Job, reason string, files ...string) {
+	app.logger.Printf("Audit: %s %s, %s", action, job.ID, reason)
+
+	record := auditRecord{Time: time.Now().UTC(), Action: action, JobID: job.ID, Reason: reason, Files: files}
+	if err := app.auditLog.Record(record); err != nil {
+		app.logger.Printf("error writing audit record: %s", err.Error())
+	}
+}
+
+// sweep deletes what has expired: jobs with their results, event logs and upload sessions. It's run every
+// Configuration.RetentionSweepInterval.
+func (app *Application) sweep() {
+	if err := app.sweepJobs(time.Now()); err != nil {
+		app.logger.Printf("Error clearing expired jobs: %s", err.Error())
+	}
+	if err := app.uploads.ClearExpired(app.config.UploadSessionTTL); err != nil {
+		app.logger.Printf("Error clearing expired uploads: %s", err.Error())
+	}
+	app.sweepBatches()
+}
+
+// sweepJobs applies the retention to the finished jobs at the time now. A job expires at its retain_until or when
+// Configuration.JobRetention has passed since it's been created, pinned jobs never expire. An original is kept as long
+// as some of its duplicates are kept, because they link to its results, and a parent as long as its dependents haven't
+// finished. The event logs expire after Configuration.EventLogRetention regardless of pinning, unless a dependent takes
+// one as its input.
+func (app *Application) sweepJobs(now time.Time) error {
+	jobs := app.queue.Snapshot()
+
+	expired := map[string]bool{}
+	for _, job := range jobs {
+		expiresAt, ok := jobExpiresAt(job, app.config.JobRetention)
+		if ok && expiresAt.Before(now) && !job.Pinned && jobFinished(job) {
+			expired[job.ID] = true
+		}
+	}
+	for _, job := range jobs {
+		if job.DuplicateOf != "" && !expired[job.ID] {
+			delete(expired, job.DuplicateOf)
+		}
+	}
+
+	// the parents of unfinished jobs are kept, with the event logs their dependents take as input
+	inputs := map[string]bool{}
+	for _, job := range jobs {
+		if jobFinished(job) {
+			continue
+		}
+		for _, id := range job.DependsOn {
+			delete(expired, id)
+		}
+		if job.InputFrom != "" {
+			inputs[job.InputFrom] = true
+		}
+	}
+
+	var errs []string
+	changed := false
+
+	for _, job := range jobs {
+		if expired[job.ID] {
+			if err := app.queue.Remove(job, true); err != nil {
+				errs = append(errs, fmt.Sprintf("job %s: %s", job.ID, err.Error()))
+				continue
+			}
+			changed = true
+			app.audit(auditActionJobDeleted, job, jobExpiryReason(job), job.Dir)
+			continue
+		}
+
+		if app.config.EventLogRetention > 0 && job.EventLogDeletedAt == nil && jobFinished(job) && !inputs[job.ID] &&
+			job.CreatedAt.Add(app.config.EventLogRetention).Before(now) {
+			files, err := removeJobEventLog(job)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("job %s: %s", job.ID, err.Error()))
+				continue
+			}
+			job.SetEventLogDeletedAt(now)
+			changed = true
+			app.audit(auditActionEventLogDeleted, job, "event log retention expired", files...)
+		}
+	}
+
+	if changed {
+		if err := app.SaveQueue(); err != nil {
+			errs = append(errs, fmt.Sprintf("error saving queue: %s", err.Error()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// jobExpiresAt returns when the job is deleted unless it's pinned. It returns false if the job has no retain_until and
+// there's no global retention, the job never expires then.
+func jobExpiresAt(job *model.Job, retention time.Duration) (time.Time, bool) {
+	if job.RetainUntil != nil {
+		return *job.RetainUntil, true
+	}
+	if retention > 0 {
+		return job.CreatedAt.Add(retention), true
+	}
+	return time.Time{}, false
+}
+
+func jobExpiryReason(job *model.Job) string {
+	if job.RetainUntil != nil {
+		return "retain_until passed"
+	}
+	return "job retention expired"
+}
+
+// jobFinished reports whether the job is no longer waiting or being analysed, so that its files can be deleted.
+func jobFinished(job *model.Job) bool {
+	return job.Status != model.JobStatusPending && job.Status != model.JobStatusRunning
+}
+
+// removeJobEventLog deletes the event log in the job's directory and the copy kept before preprocessing and returns the
+// paths of the deleted files.
+func removeJobEventLog(job *model.Job) ([]string, error) {
+	name := job.EventLogFileName()
+	if job.Dir == "" || name == "" {
+		return nil, nil
+	}
+
+	ext := path.Ext(name)
+	candidates := []string{
+		path.Join(job.Dir, name),
+		path.Join(job.Dir, strings.TrimSuffix(name, ext)+unfilteredSuffix+ext),
+	}
+
+	var removed []string
+	for _, filePath := range candidates {
+		err := os.Remove(filePath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+		removed = append(removed, filePath)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestSweepJobs(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	dir := t.TempDir()
+	app.auditLog.path = path.Join(dir, "audit.log")
+	app.config.QueuePath = path.Join(dir, "queue.gob")
+	app.config.JobRetention = 24 * time.Hour
+	app.config.EventLogRetention = time.Hour
+
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	newJob := func(id string, status model.JobStatus, age time.Duration) *model.Job {
+		job := &model.Job{
+			ID:           id,
+			Status:       status,
+			EventLogName: "log.csv",
+			CreatedAt:    now.Add(-age),
+			Dir:          path.Join(dir, id),
+		}
+		if err := os.MkdirAll(job.Dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"log.csv", "log_unfiltered.csv", "log_transitions_report.csv"} {
+			if err := os.WriteFile(path.Join(job.Dir, name), []byte("case:concept:name\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := app.queue.Add(job); err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+
+	old := 48 * time.Hour
+	expired := newJob("expired", model.JobStatusCompleted, old)
+	pinned := newJob("pinned", model.JobStatusCompleted, old)
+	pinned.Pinned = true
+	retained := newJob("retained", model.JobStatusFailed, old)
+	retained.RetainUntil = &future
+	early := newJob("early", model.JobStatusCompleted, 2*time.Hour)
+	early.RetainUntil = &past
+	input := newJob("input", model.JobStatusCompleted, old)
+	pending := newJob("pending", model.JobStatusPending, old)
+	pending.DependsOn = []string{input.ID}
+	pending.InputFrom = input.ID
+	original := newJob("original", model.JobStatusCompleted, old)
+	duplicate := newJob("duplicate", model.JobStatusDuplicate, 2*time.Hour)
+	duplicate.DuplicateOf = original.ID
+	recent := newJob("recent", model.JobStatusCompleted, time.Minute)
+
+	if err = app.sweepJobs(now); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, job := range []*model.Job{expired, early} {
+		if app.queue.FindByID(job.ID) != nil {
+			t.Fatalf("expected job %s to be removed from the queue", job.ID)
+		}
+		if _, err = os.Stat(job.Dir); !os.IsNotExist(err) {
+			t.Fatalf("expected the directory of job %s to be removed, got %v", job.ID, err)
+		}
+	}
+
+	for _, job := range []*model.Job{pinned, retained, input, pending, original, duplicate, recent} {
+		if app.queue.FindByID(job.ID) == nil {
+			t.Fatalf("expected job %s to be kept", job.ID)
+		}
+
+		// the event logs of the finished jobs have expired, except the input of the pending job, the reports are kept
+		_, err = os.Stat(path.Join(job.Dir, "log.csv"))
+		logDeleted := os.IsNotExist(err)
+		wantLogDeleted := job != input && job != pending && job != recent
+		if logDeleted != wantLogDeleted || (job.EventLogDeletedAt != nil) != wantLogDeleted {
+			t.Fatalf("expected the event log of job %s to be deleted: %v, got %v", job.ID, wantLogDeleted, logDeleted)
+		}
+		if _, err = os.Stat(path.Join(job.Dir, "log_transitions_report.csv")); err != nil {
+			t.Fatalf("expected the report of job %s to be kept, got %v", job.ID, err)
+		}
+	}
+
+	readAudit := func() map[string]string {
+		b, err := os.ReadFile(app.auditLog.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actions := map[string]string{}
+		scanner := bufio.NewScanner(bytes.NewReader(b))
+		for scanner.Scan() {
+			var record auditRecord
+			if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			actions[record.JobID] += record.Action + ";"
+		}
+		return actions
+	}
+
+	want := map[string]string{
+		"expired":   "job_deleted;",
+		"early":     "job_deleted;",
+		"pinned":    "event_log_deleted;",
+		"retained":  "event_log_deleted;",
+		"original":  "event_log_deleted;",
+		"duplicate": "event_log_deleted;",
+	}
+	checkAudit := func() {
+		actions := readAudit()
+		if len(actions) != len(want) {
+			t.Fatalf("unexpected audit records %v", actions)
+		}
+		for id, action := range want {
+			if actions[id] != action {
+				t.Fatalf("expected audit records %q for job %s, got %q", action, id, actions[id])
+			}
+		}
+	}
+	checkAudit()
+
+	// a second sweep has nothing to delete
+	if err = app.sweepJobs(now); err != nil {
+		t.Fatal(err)
+	}
+	checkAudit()
+}
+
+func TestPutJobRetention(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	job := &model.Job{ID: "retention", Status: model.JobStatusCompleted, CreatedAt: time.Now()}
+	if err = app.queue.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := app.queue.Remove(job, false); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	put := func(id, body string) *http.Response {
+		req, err := http.NewRequest("PUT", ts.URL+"/jobs/"+id+"/retention", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = res.Body.Close() })
+		return res
+	}
+
+	retainUntil := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	res := put(job.ID, `{"retain_until": "`+retainUntil.Format(time.RFC3339)+`", "pinned": true}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	var response model.ApiSingleJobResponse
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Pinned || response.RetainUntil == nil || !response.RetainUntil.Equal(retainUntil) {
+		t.Fatalf("unexpected retention in the response %+v", response.Job)
+	}
+	if !job.Pinned || job.RetainUntil == nil {
+		t.Fatalf("expected the job's retention to be set, got %+v", job)
+	}
+
+	// the settings are replaced
+	if res = put(job.ID, `{}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if job.Pinned || job.RetainUntil != nil {
+		t.Fatalf("expected the job's retention to be reset, got %+v", job)
+	}
+
+	if res = put(job.ID, `{"retain_until": "2020-01-01T00:00:00Z"}`); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, res.StatusCode)
+	}
+	if res = put("missing", `{}`); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status code %d, got %d", http.StatusNotFound, res.StatusCode)
+	}
+}
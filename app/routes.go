@@ -41,6 +41,14 @@ func (app *Application) initializeRouter() {
 			CancelJobByID(app),
 		},
 
+		Route{
+			"PutJobRetention",
+			"PUT",
+			"/jobs/{id}/retention",
+			"",
+			PutJobRetention(app),
+		},
+
 		Route{
 			"GetJobByID",
 			"GET",
@@ -73,6 +81,102 @@ func (app *Application) initializeRouter() {
 			GetJobs(app),
 		},
 
+		Route{
+			"PostPipeline",
+			"POST",
+			"/pipelines",
+			"",
+			PostPipeline(app),
+		},
+
+		Route{
+			"PostBatch",
+			"POST",
+			"/batches",
+			"",
+			PostBatch(app),
+		},
+
+		Route{
+			"GetBatchByID",
+			"GET",
+			"/batches/{id}",
+			"",
+			GetBatchByID(app),
+		},
+
+		Route{
+			"GetUsage",
+			"GET",
+			"/usage",
+			"",
+			GetUsage(app),
+		},
+
+		Route{
+			"InspectEventLog",
+			"POST",
+			"/logs/inspect",
+			"",
+			InspectEventLog(app),
+		},
+
+		Route{
+			"CreateUpload",
+			"POST",
+			"/uploads",
+			"",
+			CreateUpload(app),
+		},
+
+		Route{
+			"FinalizeUpload",
+			"POST",
+			"/uploads/{id}/finalize",
+			"",
+			FinalizeUpload(app),
+		},
+
+		Route{
+			"HeadUpload",
+			"HEAD",
+			"/uploads/{id}",
+			"",
+			HeadUpload(app),
+		},
+
+		Route{
+			"GetUpload",
+			"GET",
+			"/uploads/{id}",
+			"",
+			GetUpload(app),
+		},
+
+		Route{
+			"PatchUpload",
+			"PATCH",
+			"/uploads/{id}",
+			"",
+			PatchUpload(app),
+		},
+
+		Route{
+			"PutUpload",
+			"PUT",
+			"/uploads/{id}",
+			"",
+			PatchUpload(app),
+		},
+
+		Route{
+			"DeleteUpload",
+			"DELETE",
+			"/uploads/{id}",
+			"",
+			DeleteUpload(app),
+		},
+
 		Route{
 			"SampleCallback",
 			"POST",
@@ -90,6 +194,12 @@ func (app *Application) initializeRouter() {
 		},
 	}
 
+	// the results of the analyses are served by their runners' endpoints
+	routes = append(routes, app.analysisRoutes()...)
+	if app.config.RemoteWorkers {
+		routes = append(routes, app.workerRoutes()...)
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
 
 	for _, route := range routes {
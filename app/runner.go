@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// AnalysisRunner runs the commands of the analyses. ExecRunner is the one the application uses, tests replace it.
+type AnalysisRunner interface {
+	// Run runs the command and waits for it to exit. The command is stopped when ctx is done.
+	Run(ctx context.Context, cmd *AnalysisCommand) error
+}
+
+// AnalysisCommand is a program run with its arguments as they are, they never go through a shell.
+type AnalysisCommand struct {
+	// JobID is the job the command analyses.
+	JobID   string
+	Program string
+	Args    []string
+	// Dir is the working directory, the application's one if it's empty.
+	Dir    string
+	Limits ResourceLimits
+	// Sandbox isolates the command from the application if it's set, Dir is required then.
+	Sandbox *Sandbox
+	// Stdout and Stderr receive the output of the command, it's discarded if they're nil.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ResourceLimits of an analysis process, zero means no limit.
+type ResourceLimits struct {
+	// Memory is the maximum size of the process's virtual memory in bytes.
+	Memory int64
+	// CPUTime is the maximum CPU time of the process, it's rounded up to whole seconds.
+	CPUTime time.Duration
+	// WallTime is how long the process can run before it's killed.
+	WallTime time.Duration
+}
+
+// Sandbox is how a command is isolated from the application, see sandboxCommand.
+type Sandbox struct {
+	// User is the name or the ID of the unprivileged user the command runs as.
+	User string
+	// Env is the whole environment of the command.
+	Env []string
+}
+
+// ExecRunner runs the commands as child processes. The memory and CPU limits are set with rlimits on the platforms
+// which have them, see limitCommand. A sandboxed command isn't started if any part of the sandbox can't be set up.
+//
+// A command is started in a process group of its own, see prepareCommand. When the context is done, the group is asked
+// to terminate, see terminateCommand, and killed if it's still running after the GracePeriod, see killCommand.
+type ExecRunner struct {
+	// GracePeriod is how long a stopped command has to exit, defaultGracePeriod if it's zero.
+	GracePeriod time.Duration
+}
+
+// defaultGracePeriod is the grace period of an ExecRunner which has none set.
+const defaultGracePeriod = 10 * time.Second
+
+func (r *ExecRunner) Run(ctx context.Context, c *AnalysisCommand) error {
+	if c.Limits.WallTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Limits.WallTime)
+		defer cancel()
+	}
+
+	cmd := limitCommand(c)
+	cmd.Dir = c.Dir
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	prepareCommand(cmd)
+
+	if c.Sandbox != nil {
+		if c.Dir == "" {
+			return fmt.Errorf("error setting up the sandbox: the command has no directory")
+		}
+		if err := sandboxCommand(cmd, c.Sandbox); err != nil {
+			return fmt.Errorf("error setting up the sandbox: %s", err.Error())
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting analysis: %s", err.Error())
+	}
+
+	// the process group is stopped if the context is done before the command exits, Run returns only after the
+	// goroutine has, so that it never outlives the command
+	exited := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+		}
+
+		terminateCommand(cmd)
+		timer := time.NewTimer(r.gracePeriod())
+		defer timer.Stop()
+		select {
+		case <-exited:
+		case <-timer.C:
+			killCommand(cmd)
+		}
+	}()
+
+	err := cmd.Wait()
+	close(exited)
+	<-stopped
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("analysis has been stopped: %s", ctxErr.Error())
+	}
+	return err
+}
+
+func (r *ExecRunner) gracePeriod() time.Duration {
+	if r.GracePeriod > 0 {
+		return r.GracePeriod
+	}
+	return defaultGracePeriod
+}
@@ -0,0 +1,12 @@
+package app
+
+import "syscall"
+
+// isolateNetwork does nothing, macOS has no network namespaces.
+func isolateNetwork(*syscall.SysProcAttr) {
+}
+
+// isolateProcesses does nothing, macOS has no PID namespaces. A process which leaves the process group can outlive the
+// analysis.
+func isolateProcesses(*syscall.SysProcAttr) {
+}
@@ -0,0 +1,16 @@
+package app
+
+import "syscall"
+
+// isolateNetwork starts the process in a network namespace of its own, which has only a loopback interface that is
+// down.
+func isolateNetwork(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWNET
+}
+
+// isolateProcesses starts the process in a PID namespace of its own. The other processes in the namespace are killed
+// when the process exits, so that none outlives the analysis, e.g., after leaving the process group with setsid, to
+// read the event logs of the later analyses running as the same user.
+func isolateProcesses(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWPID
+}
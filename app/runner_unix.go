@@ -0,0 +1,136 @@
+//go:build linux || darwin
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// limitCommand returns the command which runs the program with the arguments. With limits on the memory or the CPU
+// time, or in a sandbox, which disables core dumps, the program is exec'ed by a shell which sets the rlimits first. The
+// program and the arguments are passed to the shell as positional parameters, so they're never parsed by it.
+func limitCommand(c *AnalysisCommand) *exec.Cmd {
+	program, args, limits := c.Program, c.Args, c.Limits
+
+	var script string
+	if c.Sandbox != nil {
+		script += "ulimit -c 0 && "
+	}
+	if limits.Memory > 0 {
+		// ulimit takes kibibytes
+		script += "ulimit -v " + strconv.FormatInt((limits.Memory+1023)/1024, 10) + " && "
+	}
+	if limits.CPUTime > 0 {
+		seconds := int64((limits.CPUTime + time.Second - 1) / time.Second)
+		script += "ulimit -t " + strconv.FormatInt(seconds, 10) + " && "
+	}
+	if script == "" {
+		return exec.Command(program, args...)
+	}
+
+	// in a sandbox the shell stays the program's parent as the init of the PID namespace, see isolateProcesses, the
+	// init ignores terminateCommand's SIGTERM but the program gets it
+	if c.Sandbox != nil {
+		return exec.Command("sh", append([]string{"-c", script + `"$0" "$@"`, program}, args...)...)
+	}
+	return exec.Command("sh", append([]string{"-c", script + `exec "$0" "$@"`, program}, args...)...)
+}
+
+// prepareCommand puts the command into its own process group, so that terminateCommand and killCommand reach its
+// children as well.
+func prepareCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateCommand sends SIGTERM to the process group of the command.
+func terminateCommand(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// killCommand kills the process group of the command.
+func killCommand(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// sandboxCommand makes the command run as the sandbox's user with the sandbox's environment, and in network and PID
+// namespaces of its own where the platform has them, see isolateNetwork and isolateProcesses. The command's directory
+// is handed over to the user. Dropping the privileges needs the application to run as root.
+func sandboxCommand(cmd *exec.Cmd, sandbox *Sandbox) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("the application has to run as root to drop privileges")
+	}
+
+	uid, gid, err := lookupUser(sandbox.User)
+	if err != nil {
+		return err
+	}
+	if uid == 0 {
+		return fmt.Errorf("user %s is root", sandbox.User)
+	}
+
+	err = filepath.Walk(cmd.Dir, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, int(uid), int(gid))
+	})
+	if err != nil {
+		return fmt.Errorf("error handing over the directory: %s", err.Error())
+	}
+
+	cmd.Env = sandbox.Env
+	if cmd.Env == nil {
+		cmd.Env = []string{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid, Groups: []uint32{}}
+	isolateNetwork(cmd.SysProcAttr)
+	isolateProcesses(cmd.SysProcAttr)
+	return nil
+}
+
+// lookupUser returns the user and group IDs of the user given by name or ID.
+func lookupUser(name string) (uint32, uint32, error) {
+	u, err := user.Lookup(name)
+	if _, ok := err.(user.UnknownUserError); ok {
+		u, err = user.LookupId(name)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("error looking up user %s: %s", name, err.Error())
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(uid), uint32(gid), nil
+}
+
+// ownedByApplication reports whether the file belongs to the application's user.
+func ownedByApplication(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Geteuid()
+}
+
+// openRegularFile opens the file for reading unless it's a symbolic link or not a regular file.
+func openRegularFile(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	return f, nil
+}
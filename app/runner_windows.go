@@ -0,0 +1,55 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// limitCommand returns the command which runs the program with the arguments. The limits on the memory and the CPU
+// time aren't applied on Windows.
+func limitCommand(c *AnalysisCommand) *exec.Cmd {
+	return exec.Command(c.Program, c.Args...)
+}
+
+// prepareCommand puts the command into its own process group.
+func prepareCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateCommand asks the process tree of the command to close, console programs usually don't.
+func terminateCommand(cmd *exec.Cmd) {
+	_ = exec.Command("taskkill", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// sandboxCommand fails, the sandbox isn't supported on Windows.
+func sandboxCommand(*exec.Cmd, *Sandbox) error {
+	return fmt.Errorf("the sandbox isn't supported on Windows")
+}
+
+// killCommand kills the process tree of the command, or only the process if taskkill fails.
+func killCommand(cmd *exec.Cmd) {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// ownedByApplication reports false, the sandbox isn't supported on Windows.
+func ownedByApplication(os.FileInfo) bool {
+	return false
+}
+
+// openRegularFile opens the file for reading unless it's not a regular file.
+func openRegularFile(name string) (*os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	return f, nil
+}
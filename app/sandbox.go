@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxesDirName is the directory in the temporary one which keeps the directories of the sandboxed analyses. Others
+// can only traverse it, so that a sandboxed analysis can't find the directories of the others.
+const sandboxesDirName = "waiting-time-sandboxes"
+
+// analysisSandbox is the directory a sandboxed analysis runs in, away from the application's data. It starts with a
+// copy of the event log, what the analysis writes to it is collected into the job's directory afterwards.
+type analysisSandbox struct {
+	Dir string
+}
+
+// newAnalysisSandbox creates the directory of a sandboxed analysis of the job and copies the event log into it.
+func newAnalysisSandbox(jobID, jobDir, eventLogName string) (*analysisSandbox, error) {
+	root, err := sandboxesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(root, jobID+"-")
+	if err != nil {
+		return nil, err
+	}
+	sandbox := &analysisSandbox{Dir: dir}
+
+	if err = copyRegularFile(path.Join(jobDir, eventLogName), path.Join(dir, eventLogName)); err != nil {
+		_ = sandbox.Close()
+		return nil, fmt.Errorf("error copying the event log: %s", err.Error())
+	}
+	return sandbox, nil
+}
+
+// sandboxesDir creates the directory of the sandboxes unless it exists. Anyone can create it first in the temporary
+// directory, so it's refused unless it's a directory of the application's user with mode 0711, e.g., not a symbolic
+// link, the others can't replace it then.
+func sandboxesDir() (string, error) {
+	root := path.Join(os.TempDir(), sandboxesDirName)
+	if err := os.Mkdir(root, 0711); err == nil {
+		// the mode isn't left to the umask
+		if err = os.Chmod(root, 0711); err != nil {
+			return "", err
+		}
+	} else if !os.IsExist(err) {
+		return "", err
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0711 || !ownedByApplication(info) {
+		return "", fmt.Errorf("%s has to be a directory of the application's user with mode 0711", root)
+	}
+	return root, nil
+}
+
+// collect copies the files the analysis has written into jobDir, except the ones named in skip, which have been
+// copied from there. Symbolic links and other files which aren't regular are left out, so that the analysis can't
+// make the application copy a file it can't read itself.
+func (s *analysisSandbox) collect(jobDir string, skip ...string) error {
+	return filepath.Walk(s.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		for _, name := range skip {
+			if rel == name {
+				return nil
+			}
+		}
+
+		dst := filepath.Join(jobDir, rel)
+		switch {
+		case rel == ".":
+			return nil
+		case info.IsDir():
+			return os.MkdirAll(dst, 0777)
+		case info.Mode().IsRegular():
+			return copyRegularFile(p, dst)
+		default:
+			return nil
+		}
+	})
+}
+
+// Close deletes the sandbox's directory.
+func (s *analysisSandbox) Close() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// copyRegularFile copies src to dst unless src is a symbolic link or not a regular file, see openRegularFile.
+func copyRegularFile(src, dst string) error {
+	in, err := openRegularFile(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// sandboxEnvironment returns the environment of a sandboxed analysis in dir: PATH, the locale and the variables named
+// in names from the application's environment, and dir as the home and the temporary directory.
+func sandboxEnvironment(dir string, names []string) []string {
+	env := []string{"HOME=" + dir, "TMPDIR=" + dir}
+	for _, name := range append([]string{"PATH", "LANG", "LC_ALL", "TZ"}, names...) {
+		if value, ok := os.LookupEnv(name); ok && !strings.Contains(name, "=") {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// restrictDataDirs makes the directories accessible only to the application's user, so that the sandboxed analyses
+// can't read the queue, the results or the uploads.
+func restrictDataDirs(dirs ...string) error {
+	for _, dir := range dirs {
+		if err := os.Chmod(dir, 0700); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error restricting access to %s: %s", dir, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// timezones of jobs are looked up by name, also on hosts without the timezone database
+	_ "time/tzdata"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// zonedTimeLayouts are the timestamp formats with an offset accepted in event logs and reports.
+var zonedTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-0700",
+}
+
+// naiveTimeLayouts are the timestamp formats without an offset, they're read in the job's timezone.
+var naiveTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// slashedDate matches dates like "01/02/2022" or "1.2.2022" which are read differently in different locales.
+var slashedDate = regexp.MustCompile(`^\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4}\b`)
+
+// epochUnits maps the number of digits of an epoch timestamp to its unit. Other lengths are rejected, because "1000" can
+// be seconds as well as milliseconds.
+var epochUnits = map[int]time.Duration{
+	10: time.Second,
+	13: time.Millisecond,
+	16: time.Microsecond,
+	19: time.Nanosecond,
+}
+
+var errAmbiguousTimestamp = errors.New("ambiguous timestamp")
+
+// timestampError is returned for a value which isn't a timestamp or can be read in more than one way.
+type timestampError struct {
+	Value     string
+	Reason    string
+	Ambiguous bool
+}
+
+func (e *timestampError) Error() string {
+	if e.Ambiguous {
+		return fmt.Sprintf("ambiguous timestamp %q, %s", e.Value, e.Reason)
+	}
+	return fmt.Sprintf("invalid timestamp %q", e.Value)
+}
+
+func (e *timestampError) Is(target error) bool {
+	return e.Ambiguous && target == errAmbiguousTimestamp
+}
+
+// timestampParser reads the timestamps of an event log column. Without a layout, ISO 8601 timestamps, the same with a
+// space instead of "T" and epoch timestamps in seconds, milliseconds, microseconds or nanoseconds are accepted.
+// Timestamps without an offset are read in the location, UTC if it's nil.
+type timestampParser struct {
+	Layout   string
+	Location *time.Location
+}
+
+// newTimestampParser returns the parser for a column, with the layout from the column mapping's timestamp formats.
+func newTimestampParser(columnMapping *model.ColumnMapping, column string, location *time.Location) timestampParser {
+	layout, _ := columnMapping.TimestampLayout(column)
+	return timestampParser{Layout: layout, Location: location}
+}
+
+// Parse reads a timestamp and returns it in UTC.
+func (p timestampParser) Parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	location := p.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	if p.Layout != "" {
+		t, err := time.ParseInLocation(p.Layout, value, location)
+		if err != nil {
+			return time.Time{}, &timestampError{Value: value}
+		}
+		return t.UTC(), nil
+	}
+
+	if value != "" && strings.Trim(value, "0123456789") == "" {
+		unit, ok := epochUnits[len(value)]
+		if !ok {
+			return time.Time{}, &timestampError{
+				Value:     value,
+				Reason:    "epoch timestamps must have 10 (seconds), 13 (milliseconds), 16 (microseconds) or 19 (nanoseconds) digits",
+				Ambiguous: true,
+			}
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, &timestampError{Value: value}
+		}
+		return time.Unix(0, 0).Add(time.Duration(n) * unit).UTC(), nil
+	}
+
+	for _, layout := range zonedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	for _, layout := range naiveTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	if slashedDate.MatchString(value) {
+		return time.Time{}, &timestampError{
+			Value:     value,
+			Reason:    "the order of day and month depends on the locale, give the column's format in timestamp_formats",
+			Ambiguous: true,
+		}
+	}
+
+	return time.Time{}, &timestampError{Value: value}
+}
+
+// parseEventLogTimestamp reads a timestamp without a column format, timestamps without an offset are taken as UTC.
+func parseEventLogTimestamp(value string) (time.Time, error) {
+	return timestampParser{}.Parse(value)
+}
+
+// loadTimezone returns the location for a job's timezone, UTC if it's empty.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return location, nil
+}
+
+// normalizeEventLogTimestamps rewrites the timestamp columns of the CSV event log at filePath in the canonical layout in
+// UTC, so that the analysis reads them the same way whatever their source format was. Timestamps without an offset are
+// read in the timezone. The returned column mapping has no timestamp formats anymore, because they no longer apply.
+func normalizeEventLogTimestamps(filePath string, columnMapping *model.ColumnMapping, timezone string) (*model.ColumnMapping, error) {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := columnMapping
+	if mapping == nil {
+		mapping = &canonicalColumnMapping
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmpPath := filePath + ".tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+	}()
+
+	r := csv.NewReader(bufio.NewReader(src))
+	w := csv.NewWriter(dst)
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %s", err.Error())
+	}
+	if err = w.Write(header); err != nil {
+		return nil, err
+	}
+
+	indices := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\xef\xbb\xbf")
+		}
+		indices[strings.TrimSpace(name)] = i
+	}
+
+	type timestampColumn struct {
+		index  int
+		parser timestampParser
+	}
+	var columns []timestampColumn
+	for _, column := range []string{mapping.StartTimestamp, mapping.EndTimestamp} {
+		i, ok := indices[column]
+		if !ok {
+			return nil, fmt.Errorf("timestamp column %q is missing", column)
+		}
+		columns = append(columns, timestampColumn{index: i, parser: newTimestampParser(mapping, column, location)})
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %s", err.Error())
+		}
+
+		for _, column := range columns {
+			t, err := column.parser.Parse(record[column.index])
+			if err != nil {
+				line, _ := r.FieldPos(column.index)
+				return nil, fmt.Errorf("line %d: %s", line, err.Error())
+			}
+			record[column.index] = t.Format(canonicalTimeLayout)
+		}
+
+		if err = w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return nil, err
+	}
+	if err = dst.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return nil, err
+	}
+
+	if columnMapping == nil || columnMapping.TimestampFormats == nil {
+		return columnMapping, nil
+	}
+	normalized := *columnMapping
+	normalized.TimestampFormats = nil
+	return &normalized, nil
+}
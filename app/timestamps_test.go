@@ -0,0 +1,142 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestTimestampParser(t *testing.T) {
+	tallinn, err := loadTimezone("Europe/Tallinn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2022, 5, 16, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		parser        timestampParser
+		value         string
+		want          time.Time
+		wantAmbiguous bool
+		wantErr       bool
+	}{
+		{name: "ISO 8601 with offset", value: "2022-05-16T10:00:00+03:00", want: want},
+		{name: "ISO 8601 with Z", value: "2022-05-16T07:00:00.000Z", want: want},
+		{name: "space with offset", value: "2022-05-16 10:00:00+03:00", want: want},
+		{name: "naive in UTC", value: "2022-05-16T07:00:00.000", want: want},
+		{name: "naive in timezone", parser: timestampParser{Location: tallinn}, value: "2022-05-16 10:00:00", want: want},
+		{name: "offset wins over timezone", parser: timestampParser{Location: tallinn}, value: "2022-05-16T07:00:00Z", want: want},
+		{name: "epoch seconds", value: "1652684400", want: want},
+		{name: "epoch milliseconds", value: "1652684400000", want: want},
+		{name: "epoch of unknown unit", value: "165268440000", wantAmbiguous: true},
+		{name: "day and month order", value: "05/06/2022 10:00", wantAmbiguous: true},
+		{name: "layout in timezone", parser: timestampParser{Layout: "02/01/2006 15:04", Location: tallinn}, value: "16/05/2022 10:00", want: want},
+		{name: "layout mismatch", parser: timestampParser{Layout: "02/01/2006 15:04"}, value: "2022-05-16T07:00:00", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "text", value: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parser.Parse(tt.value)
+
+			if tt.wantAmbiguous {
+				if !errors.Is(err, errAmbiguousTimestamp) {
+					t.Fatalf("expected an ambiguous timestamp error, got %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				if err == nil || errors.Is(err, errAmbiguousTimestamp) {
+					t.Fatalf("expected an invalid timestamp error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeEventLogTimestamps(t *testing.T) {
+	content := "Case,Activity,Start,End,Resource\n" +
+		"0,A,16/05/2022 10:00,1652685300000,Marcus\n" +
+		"0,B,16/05/2022 12:00,2022-05-16 12:30:00,Anya\n"
+
+	filePath := path.Join(t.TempDir(), "log.csv")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := &model.ColumnMapping{
+		Case:             "Case",
+		Activity:         "Activity",
+		Resource:         "Resource",
+		StartTimestamp:   "Start",
+		EndTimestamp:     "End",
+		TimestampFormats: map[string]string{"Start": "%d/%m/%Y %H:%M"},
+	}
+
+	normalized, err := normalizeEventLogTimestamps(filePath, mapping, "Europe/Tallinn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if normalized.TimestampFormats != nil || mapping.TimestampFormats == nil {
+		t.Fatalf("expected the formats to be dropped from a copy of the mapping, got %+v", normalized)
+	}
+
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Case,Activity,Start,End,Resource\n" +
+		"0,A,2022-05-16T07:00:00.000Z,2022-05-16T07:15:00.000Z,Marcus\n" +
+		"0,B,2022-05-16T09:00:00.000Z,2022-05-16T09:30:00.000Z,Anya\n"
+	if string(b) != want {
+		t.Fatalf("unexpected event log\n got: %s\nwant: %s", b, want)
+	}
+
+	// the normalized log reads the same again
+	if _, err = normalizeEventLogTimestamps(filePath, normalized, "Europe/Tallinn"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = normalizeEventLogTimestamps(filePath, normalized, "Mars/Olympus_Mons"); err == nil || !strings.Contains(err.Error(), "unknown timezone") {
+		t.Fatalf("expected an unknown timezone error, got %v", err)
+	}
+}
+
+func TestJobResultsFromPath_InvalidTimestamp(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	report := "start_time,end_time,source_activity,source_resource,destination_activity,destination_resource,case_id," +
+		"wt_total,wt_contention,wt_batching,wt_prioritization,wt_unavailability,wt_extraneous\n" +
+		"2022-05-16 10:15:00+03:00,2022-05-16T12:00:00,A,Marcus,B,Anya,0,6300,0,0,0,0,6300\n" +
+		"05/16/2022,2022-05-16 12:00:00+03:00,A,Marcus,B,Anya,1,6300,0,0,0,0,6300\n"
+
+	filePath := path.Join(t.TempDir(), "report.csv")
+	if err = os.WriteFile(filePath, []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = app.jobResultsFromPath(filePath); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected an error for line 3, got %v", err)
+	}
+}
@@ -0,0 +1,622 @@
+package app
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+const (
+	defaultEventLogName = "event_log.csv"
+
+	// maxFormFieldSize limits non-file parts of a multipart request which are read into memory.
+	maxFormFieldSize = 1 << 20
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+
+	unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+var (
+	errEmptyUpload      = errors.New("request body is empty")
+	errEventLogTooLarge = errors.New("event log is too large")
+)
+
+// jobUpload holds the settings which come along with an uploaded event log.
+type jobUpload struct {
+	EventLogName     string               `json:"-"`
+	ContentType      string               `json:"-"`
+	CallbackEndpoint string               `json:"callback_endpoint,omitempty"`
+	ColumnMapping    *model.ColumnMapping `json:"column_mapping,omitempty"`
+	EventLogFormat   string               `json:"event_log_format,omitempty"`
+	OCELObjectType   string               `json:"ocel_object_type,omitempty"`
+	AutoMap          bool                 `json:"auto_map,omitempty"`
+	Preprocessing    *model.Preprocessing `json:"preprocessing,omitempty"`
+	Timezone         string               `json:"timezone,omitempty"`
+	Force            bool                 `json:"force,omitempty"`
+	RetainUntil      *time.Time           `json:"retain_until,omitempty"`
+	Pinned           bool                 `json:"pinned,omitempty"`
+	Kind             model.AnalysisKind   `json:"kind,omitempty"`
+	// Params is given as a JSON object like the preprocessing.
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// receiveEventLog streams the event log from the request body into dir, decompresses and normalizes it, see
+// receiveUpload, and returns the settings provided with it.
+func (app *Application) receiveEventLog(r *http.Request, dir string) (*jobUpload, error) {
+	upload, err := app.receiveUpload(r, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = app.normalizeEventLog(dir, upload); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// receiveUpload streams the file from the request body into dir as it is and returns the settings provided with it.
+// Multipart requests carry the log in the "event_log" file part and the settings in the "column_mapping",
+// "callback_endpoint", "event_log_format", "ocel_object_type", "auto_map", "preprocessing", "timezone", "force",
+// "retain_until", "pinned", "kind", "params" and "options" parts, other requests carry the log as the whole body
+// and the settings in the query string, with preprocessing and params as JSON objects. The column mapping
+// can also be given in separate fields with the keys of model.ParseColumnMapping, both in the query string and in the
+// multipart form. A body sent with the Content-Encoding header is decompressed.
+func (app *Application) receiveUpload(r *http.Request, dir string) (*jobUpload, error) {
+	query := r.URL.Query()
+	upload := &jobUpload{
+		ColumnMapping:  model.ParseColumnMapping(query),
+		EventLogFormat: query.Get("event_log_format"),
+		OCELObjectType: query.Get("ocel_object_type"),
+		Timezone:       query.Get("timezone"),
+		Kind:           model.AnalysisKind(query.Get("kind")),
+	}
+
+	for key, value := range map[string]*bool{"auto_map": &upload.AutoMap, "force": &upload.Force, "pinned": &upload.Pinned} {
+		if s := query.Get(key); s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a boolean: %s", key, err.Error())
+			}
+			*value = v
+		}
+	}
+
+	if retainUntil := query.Get("retain_until"); retainUntil != "" {
+		t, err := time.Parse(time.RFC3339, retainUntil)
+		if err != nil {
+			return nil, fmt.Errorf("retain_until is invalid: %s", err.Error())
+		}
+		upload.RetainUntil = &t
+	}
+
+	if preprocessing := query.Get("preprocessing"); preprocessing != "" {
+		upload.Preprocessing = &model.Preprocessing{}
+		if err := json.Unmarshal([]byte(preprocessing), upload.Preprocessing); err != nil {
+			return nil, fmt.Errorf("preprocessing is invalid: %s", err.Error())
+		}
+	}
+
+	if params := query.Get("params"); params != "" {
+		upload.Params = json.RawMessage(params)
+	}
+
+	var body io.Reader = r.Body
+
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err == io.EOF {
+			return nil, errEmptyUpload
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid gzip request body: %s", err.Error())
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var err error
+	if mediaType == "multipart/form-data" {
+		err = app.receiveMultipartEventLog(multipart.NewReader(body, params["boundary"]), dir, upload)
+	} else {
+		upload.EventLogName = defaultEventLogName
+		upload.ContentType = r.Header.Get("Content-Type")
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			upload.EventLogName = sanitizeFileName(params["filename"])
+		}
+		err = app.saveEventLog(body, dir, upload.EventLogName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = app.checkUploadSettings(upload); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// checkUploadSettings validates the settings which come along with an uploaded event log and fills in the defaults of
+// the analysis' parameters.
+func (app *Application) checkUploadSettings(upload *jobUpload) error {
+	var err error
+	if upload.EventLogFormat != "" {
+		if _, err = detectEventLogFormat("", "", upload.EventLogFormat); err != nil {
+			return err
+		}
+	}
+	if upload.Preprocessing != nil {
+		if err = upload.Preprocessing.Validate(); err != nil {
+			return fmt.Errorf("preprocessing is invalid: %s", err.Error())
+		}
+	}
+	if upload.Kind, upload.Params, err = app.resolveAnalysis(upload.Kind, upload.Params); err != nil {
+		return err
+	}
+	if _, err = loadTimezone(upload.Timezone); err != nil {
+		return err
+	}
+	return model.ValidateRetainUntil(upload.RetainUntil)
+}
+
+func (app *Application) receiveMultipartEventLog(reader *multipart.Reader, dir string, upload *jobUpload) error {
+	mappingFields := url.Values{}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid multipart request: %s", err.Error())
+		}
+
+		switch part.FormName() {
+		case "event_log", "file":
+			if upload.EventLogName != "" {
+				return fmt.Errorf("multiple event logs in one request")
+			}
+
+			upload.EventLogName = defaultEventLogName
+			if part.FileName() != "" {
+				upload.EventLogName = sanitizeFileName(part.FileName())
+			}
+			upload.ContentType = part.Header.Get("Content-Type")
+			if err = app.saveEventLog(part, dir, upload.EventLogName); err != nil {
+				return err
+			}
+
+		case "column_mapping":
+			var columnMapping model.ColumnMapping
+			if err = json.NewDecoder(io.LimitReader(part, maxFormFieldSize)).Decode(&columnMapping); err != nil {
+				return fmt.Errorf("column_mapping is invalid: %s", err.Error())
+			}
+			upload.ColumnMapping = &columnMapping
+
+		case "callback_endpoint":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			upload.CallbackEndpoint = strings.TrimSpace(value)
+
+		case "event_log_format":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			upload.EventLogFormat = strings.TrimSpace(value)
+
+		case "ocel_object_type":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			upload.OCELObjectType = strings.TrimSpace(value)
+
+		case "auto_map", "force", "pinned":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			v, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("%s is not a boolean: %s", part.FormName(), err.Error())
+			}
+			switch part.FormName() {
+			case "force":
+				upload.Force = v
+			case "pinned":
+				upload.Pinned = v
+			default:
+				upload.AutoMap = v
+			}
+
+		case "timezone":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			upload.Timezone = strings.TrimSpace(value)
+
+		case "retain_until":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("retain_until is invalid: %s", err.Error())
+			}
+			upload.RetainUntil = &t
+
+		case "preprocessing":
+			var preprocessing model.Preprocessing
+			if err = json.NewDecoder(io.LimitReader(part, maxFormFieldSize)).Decode(&preprocessing); err != nil {
+				return fmt.Errorf("preprocessing is invalid: %s", err.Error())
+			}
+			upload.Preprocessing = &preprocessing
+
+		case "kind":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			upload.Kind = model.AnalysisKind(strings.TrimSpace(value))
+
+		case "params":
+			value, err := readFormField(part)
+			if err != nil {
+				return err
+			}
+			upload.Params = json.RawMessage(value)
+
+		case "options":
+			var options uploadOptions
+			if err = json.NewDecoder(io.LimitReader(part, maxFormFieldSize)).Decode(&options); err != nil {
+				return fmt.Errorf("options is not a valid JSON object: %s", err.Error())
+			}
+			options.apply(upload)
+
+		default:
+			// the column mapping can be given in separate fields with the same keys as in the query string
+			if part.FileName() == "" {
+				value, err := readFormField(part)
+				if err != nil {
+					return err
+				}
+				mappingFields.Add(part.FormName(), value)
+			}
+		}
+
+		_ = part.Close()
+	}
+
+	if upload.EventLogName == "" {
+		return fmt.Errorf("multipart request has no event_log file")
+	}
+
+	if columnMapping := model.ParseColumnMapping(mappingFields); columnMapping != nil {
+		upload.ColumnMapping = columnMapping
+	}
+
+	return nil
+}
+
+// uploadOptions are the settings given in the "options" part of a multipart request. The booleans are pointers, so that
+// the options can set them to false as well as to true.
+type uploadOptions struct {
+	jobUpload
+	AutoMap *bool `json:"auto_map,omitempty"`
+	Force   *bool `json:"force,omitempty"`
+	Pinned  *bool `json:"pinned,omitempty"`
+}
+
+// apply overrides the upload's settings with the ones given in the options.
+func (options *uploadOptions) apply(upload *jobUpload) {
+	if options.ColumnMapping != nil {
+		upload.ColumnMapping = options.ColumnMapping
+	}
+	if options.CallbackEndpoint != "" {
+		upload.CallbackEndpoint = options.CallbackEndpoint
+	}
+	if options.EventLogFormat != "" {
+		upload.EventLogFormat = options.EventLogFormat
+	}
+	if options.OCELObjectType != "" {
+		upload.OCELObjectType = options.OCELObjectType
+	}
+	if options.AutoMap != nil {
+		upload.AutoMap = *options.AutoMap
+	}
+	if options.Preprocessing != nil {
+		upload.Preprocessing = options.Preprocessing
+	}
+	if options.Timezone != "" {
+		upload.Timezone = options.Timezone
+	}
+	if options.Force != nil {
+		upload.Force = *options.Force
+	}
+	if options.RetainUntil != nil {
+		upload.RetainUntil = options.RetainUntil
+	}
+	if options.Pinned != nil {
+		upload.Pinned = *options.Pinned
+	}
+	if options.Kind != "" {
+		upload.Kind = options.Kind
+	}
+	if options.Params != nil {
+		upload.Params = options.Params
+	}
+}
+
+// readFormField reads a non-file part of a multipart request, which is limited to maxFormFieldSize.
+func readFormField(part *multipart.Part) (string, error) {
+	b, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize))
+	return string(b), err
+}
+
+// saveEventLog streams r into a file in dir without buffering it in memory.
+func (app *Application) saveEventLog(r io.Reader, dir, name string) error {
+	f, err := os.Create(path.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			app.logger.Printf("error closing file: %s", err.Error())
+		}
+	}()
+
+	n, err := copyLimited(f, r, app.config.UploadMaxSize)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errEmptyUpload
+	}
+
+	return nil
+}
+
+// normalizeEventLog decompresses the uploaded event log in dir and converts it to CSV if it's in another format. The
+// format is taken from the upload's settings or detected from the file. The upload is updated with the name of the
+// resulting CSV file and the format; the column mapping is dropped if the reader writes the canonical columns. With
+// AutoMap set and no column mapping given, the mapping is inferred from the CSV file.
+func (app *Application) normalizeEventLog(dir string, upload *jobUpload) error {
+	name, err := app.decompressEventLog(dir, upload.EventLogName)
+	if err != nil {
+		return err
+	}
+	upload.EventLogName = name
+
+	srcPath := path.Join(dir, name)
+	reader, err := detectEventLogFormat(srcPath, upload.ContentType, upload.EventLogFormat)
+	if err != nil {
+		return err
+	}
+	if reader == nil {
+		upload.EventLogFormat = formatCSV
+		return upload.autoMap(dir)
+	}
+	upload.EventLogFormat = reader.Format()
+
+	csvName := strings.TrimSuffix(name, path.Ext(name)) + ".csv"
+	tmpPath := path.Join(dir, csvName+".tmp")
+
+	canonical, err := reader.ToCSV(srcPath, tmpPath, EventLogReaderOptions{OCELObjectType: upload.OCELObjectType})
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("error converting %s event log: %s", reader.Format(), err.Error())
+	}
+	if err = os.Remove(srcPath); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path.Join(dir, csvName)); err != nil {
+		return err
+	}
+
+	upload.EventLogName = csvName
+	if canonical {
+		upload.ColumnMapping = nil
+		return nil
+	}
+
+	return upload.autoMap(dir)
+}
+
+// autoMap infers the column mapping of the CSV event log in dir if it's asked for and no mapping has been given.
+func (upload *jobUpload) autoMap(dir string) error {
+	if !upload.AutoMap || upload.ColumnMapping != nil {
+		return nil
+	}
+
+	columnMapping, err := autoMapEventLog(path.Join(dir, upload.EventLogName))
+	if err != nil {
+		return err
+	}
+	upload.ColumnMapping = columnMapping
+
+	return nil
+}
+
+// maxCompressionLayers is how many times an event log can be compressed, e.g., a gzipped file inside a zip archive, so
+// that an archive containing itself isn't decompressed forever.
+const maxCompressionLayers = 2
+
+// decompressEventLog replaces a gzip or zip compressed event log in dir with its content and returns the name of the
+// decompressed file. Compression is detected by magic bytes, so misnamed files are handled too. Uncompressed logs are
+// left as they are, logs compressed more than maxCompressionLayers times are rejected.
+func (app *Application) decompressEventLog(dir, name string) (string, error) {
+	return app.decompressEventLogLayer(dir, name, 0)
+}
+
+// decompressEventLogLayer decompresses the event log which has been decompressed layers times already.
+func (app *Application) decompressEventLogLayer(dir, name string, layers int) (string, error) {
+	archivePath := path.Join(dir, name)
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(f, magic)
+	_ = f.Close()
+	magic = magic[:n]
+
+	compressed := bytes.HasPrefix(magic, gzipMagic) || bytes.HasPrefix(magic, zipMagic)
+	if compressed && layers >= maxCompressionLayers {
+		return "", fmt.Errorf("event log is compressed more than %d times", maxCompressionLayers)
+	}
+
+	var (
+		newName string
+		content io.ReadCloser
+	)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		newName = name
+		if strings.ToLower(path.Ext(name)) == ".gz" {
+			newName = strings.TrimSuffix(name, path.Ext(name))
+		}
+
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return "", err
+		}
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			_ = f.Close()
+			return "", fmt.Errorf("invalid gzip event log: %s", err.Error())
+		}
+		content = readCloser{Reader: gz, close: f.Close}
+
+	case bytes.HasPrefix(magic, zipMagic):
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return "", fmt.Errorf("invalid zip event log: %s", err.Error())
+		}
+
+		var entry *zip.File
+		for _, file := range zr.File {
+			base := path.Base(file.Name)
+			if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+				continue
+			}
+			if entry != nil {
+				_ = zr.Close()
+				return "", fmt.Errorf("zip archive must contain exactly one event log")
+			}
+			entry = file
+		}
+		if entry == nil {
+			_ = zr.Close()
+			return "", fmt.Errorf("zip archive contains no event log")
+		}
+
+		newName = sanitizeFileName(path.Base(entry.Name))
+		rc, err := entry.Open()
+		if err != nil {
+			_ = zr.Close()
+			return "", err
+		}
+		content = readCloser{Reader: rc, close: func() error {
+			_ = rc.Close()
+			return zr.Close()
+		}}
+
+	default:
+		return name, nil
+	}
+
+	if path.Ext(newName) == "" {
+		newName += ".csv"
+	}
+
+	// the content is written under a temporary name, because the archive can have the same name as its content
+	tmpName := newName + ".tmp"
+	err = app.saveEventLog(content, dir, tmpName)
+	if closeErr := content.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error decompressing event log: %s", closeErr.Error())
+	}
+	if err != nil {
+		_ = os.Remove(path.Join(dir, tmpName))
+		return "", err
+	}
+
+	if err = os.Remove(archivePath); err != nil {
+		return "", err
+	}
+	if err = os.Rename(path.Join(dir, tmpName), path.Join(dir, newName)); err != nil {
+		return "", err
+	}
+
+	// nested compression, e.g., a gzipped file inside a zip archive
+	return app.decompressEventLogLayer(dir, newName, layers+1)
+}
+
+// copyLimited copies from r to w and fails if more than limit bytes are available. Zero limit means no limit.
+func copyLimited(w io.Writer, r io.Reader, limit int64) (int64, error) {
+	if limit <= 0 {
+		return io.Copy(w, r)
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, limit+1))
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, fmt.Errorf("%w, the limit is %d bytes", errEventLogTooLarge, limit)
+	}
+	return n, nil
+}
+
+// sanitizeFileName keeps the base name of a client-provided file name and replaces characters which aren't safe in
+// paths and URLs.
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = unsafeFileNameChars.ReplaceAllString(name, "_")
+	name = strings.TrimLeft(name, ".")
+	if name == "" || name == "_" {
+		return defaultEventLogName
+	}
+	return name
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
@@ -0,0 +1,364 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, name string, b []byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func multipartBody(t *testing.T, fileName string, file []byte, fields map[string]string) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := mw.CreateFormFile("event_log", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf, mw.FormDataContentType()
+}
+
+func TestPostJobUploads(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5_columns.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columnMapping := `{"case":"case_id","activity":"activity","resource":"resource","start_timestamp":"start_timestamp","end_timestamp":"end_timestamp"}`
+
+	multipartCSV, multipartCSVType := multipartBody(t, "manual_log_5_columns.csv", eventLog, map[string]string{
+		"column_mapping":    columnMapping,
+		"callback_endpoint": "http://example.com/callback",
+	})
+	multipartGzip, multipartGzipType := multipartBody(t, "manual_log_5_columns.csv.gz", gzipBytes(t, eventLog), map[string]string{
+		"options": `{"column_mapping":` + columnMapping + `}`,
+	})
+	multipartZip, multipartZipType := multipartBody(t, "archive.zip", zipBytes(t, "logs/manual_log_5_columns.csv", eventLog), map[string]string{
+		"column_mapping": columnMapping,
+	})
+	multipartNested, multipartNestedType := multipartBody(t, "archive.zip", zipBytes(t, "log.csv.gz", gzipBytes(t, eventLog)), map[string]string{
+		"column_mapping": columnMapping,
+	})
+	tooNested := zipBytes(t, "archive.zip", zipBytes(t, "log.csv.gz", gzipBytes(t, eventLog)))
+	multipartTooNested, multipartTooNestedType := multipartBody(t, "archive.zip", tooNested, map[string]string{
+		"column_mapping": columnMapping,
+	})
+	multipartOptions, multipartOptionsType := multipartBody(t, "manual_log_5_columns.csv", eventLog, map[string]string{
+		"options": `{"column_mapping":` + columnMapping + `,"force":false}`,
+	})
+	multipartInvalid, multipartInvalidType := multipartBody(t, "manual_log_5_columns.csv", eventLog, nil)
+
+	columnMappingQuery := "?case=case_id&activity=activity&resource=resource&start_timestamp=start_timestamp&end_timestamp=end_timestamp"
+
+	tests := []struct {
+		name             string
+		query            string
+		body             []byte
+		headers          map[string]string
+		wantStatus       int
+		wantEventLogName string
+		wantCallback     string
+		wantMapping      bool
+		wantForce        bool
+		wantPinned       bool
+	}{
+		{
+			name:             "multipart csv",
+			body:             multipartCSV.Bytes(),
+			headers:          map[string]string{"Content-Type": multipartCSVType},
+			wantStatus:       http.StatusCreated,
+			wantEventLogName: "manual_log_5_columns.csv",
+			wantCallback:     "http://example.com/callback",
+			wantMapping:      true,
+		},
+		{
+			name:             "multipart gzip",
+			body:             multipartGzip.Bytes(),
+			headers:          map[string]string{"Content-Type": multipartGzipType},
+			wantStatus:       http.StatusCreated,
+			wantEventLogName: "manual_log_5_columns.csv",
+			wantMapping:      true,
+		},
+		{
+			name:             "multipart zip",
+			body:             multipartZip.Bytes(),
+			headers:          map[string]string{"Content-Type": multipartZipType},
+			wantStatus:       http.StatusCreated,
+			wantEventLogName: "manual_log_5_columns.csv",
+			wantMapping:      true,
+		},
+		{
+			name:             "gzip inside zip",
+			body:             multipartNested.Bytes(),
+			headers:          map[string]string{"Content-Type": multipartNestedType},
+			wantStatus:       http.StatusCreated,
+			wantEventLogName: "log.csv",
+			wantMapping:      true,
+		},
+		{
+			name:             "options override the booleans",
+			query:            "?force=true&pinned=true",
+			body:             multipartOptions.Bytes(),
+			headers:          map[string]string{"Content-Type": multipartOptionsType},
+			wantStatus:       http.StatusCreated,
+			wantEventLogName: "manual_log_5_columns.csv",
+			wantMapping:      true,
+			wantPinned:       true,
+		},
+		{
+			name:       "compressed too many times",
+			body:       multipartTooNested.Bytes(),
+			headers:    map[string]string{"Content-Type": multipartTooNestedType},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "gzip content encoding",
+			query: columnMappingQuery,
+			body:  gzipBytes(t, eventLog),
+			headers: map[string]string{
+				"Content-Type":        "text/csv",
+				"Content-Encoding":    "gzip",
+				"Content-Disposition": `attachment; filename="../../log.csv"`,
+			},
+			wantStatus:       http.StatusCreated,
+			wantEventLogName: "log.csv",
+			wantMapping:      true,
+		},
+		{
+			name:       "event log without the default columns",
+			body:       multipartInvalid.Bytes(),
+			headers:    map[string]string{"Content-Type": multipartInvalidType},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "multipart without file",
+			body:       []byte("--x\r\nContent-Disposition: form-data; name=\"callback_endpoint\"\r\n\r\nhttp://example.com\r\n--x--\r\n"),
+			headers:    map[string]string{"Content-Type": "multipart/form-data; boundary=x"},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", ts.URL+"/jobs"+tt.query, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status code %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var response model.ApiSingleJobResponse
+			if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			job := app.queue.FindByID(response.ID)
+			defer func() {
+				if err := app.queue.Remove(job, true); err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			if job.EventLogName != tt.wantEventLogName {
+				t.Fatalf("expected event log name %s, got %s", tt.wantEventLogName, job.EventLogName)
+			}
+			if job.CallbackEndpoint != tt.wantCallback {
+				t.Fatalf("expected callback %s, got %s", tt.wantCallback, job.CallbackEndpoint)
+			}
+			if tt.wantMapping != (job.ColumnMapping != nil && job.ColumnMapping.Case == "case_id") {
+				t.Fatalf("unexpected column mapping %v", job.ColumnMapping)
+			}
+			if job.Force != tt.wantForce || job.Pinned != tt.wantPinned {
+				t.Fatalf("expected force %v and pinned %v, got %v and %v", tt.wantForce, tt.wantPinned, job.Force, job.Pinned)
+			}
+
+			b, err := os.ReadFile(path.Join(job.Dir, job.EventLogName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, eventLog) {
+				t.Fatalf("stored event log differs from the uploaded one")
+			}
+
+			entries, err := os.ReadDir(job.Dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("expected only the event log in the job's directory, got %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestPostJobColumnMappingInputs(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	eventLog := []byte("Case ID,Activity & Step,Start,End,Resource,Region\n" +
+		"0,A,16/05/2022 10:00,16/05/2022 10:15,Marcus,EU\n" +
+		"0,B,16/05/2022 12:00,16/05/2022 12:30,Anya,EU\n")
+
+	want := &model.ColumnMapping{
+		Case:             "Case ID",
+		Activity:         "Activity & Step",
+		Resource:         "Resource",
+		StartTimestamp:   "Start",
+		EndTimestamp:     "End",
+		CaseAttributes:   []string{"Region"},
+		TimestampFormats: map[string]string{"Start": "%d/%m/%Y %H:%M", "End": "%d/%m/%Y %H:%M"},
+	}
+
+	values := url.Values{}
+	values.Set("case", want.Case)
+	values.Set("activity", want.Activity)
+	values.Set("resource", want.Resource)
+	values.Set("start_timestamp", want.StartTimestamp)
+	values.Set("end_timestamp", want.EndTimestamp)
+	values.Add("case_attributes", "Region")
+	values.Set("timestamp_formats[Start]", "%d/%m/%Y %H:%M")
+	values.Set("timestamp_formats[End]", "%d/%m/%Y %H:%M")
+
+	fields := map[string]string{}
+	for k := range values {
+		fields[k] = values.Get(k)
+	}
+	multipartFields, multipartFieldsType := multipartBody(t, "log.csv", eventLog, fields)
+
+	mappingJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multipartJSON, multipartJSONType := multipartBody(t, "log.csv", eventLog, map[string]string{"column_mapping": string(mappingJSON)})
+
+	tests := []struct {
+		name        string
+		query       string
+		body        []byte
+		contentType string
+	}{
+		{name: "query string", query: "?" + values.Encode(), body: eventLog, contentType: "text/csv"},
+		{name: "multipart fields", body: multipartFields.Bytes(), contentType: multipartFieldsType},
+		{name: "multipart JSON", body: multipartJSON.Bytes(), contentType: multipartJSONType},
+	}
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Post(ts.URL+"/jobs"+tt.query, tt.contentType, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusCreated {
+				t.Fatalf("expected status code %d, got %d", http.StatusCreated, res.StatusCode)
+			}
+
+			var response model.ApiSingleJobResponse
+			if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			job := app.queue.FindByID(response.ID)
+			defer func() {
+				if err := app.queue.Remove(job, true); err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			if !reflect.DeepEqual(job.ColumnMapping, want) {
+				t.Fatalf("unexpected column mapping\n got: %+v\nwant: %+v", job.ColumnMapping, want)
+			}
+		})
+	}
+
+	// an unknown key in the JSON mapping is rejected instead of being ignored
+	res, err := http.Post(ts.URL+"/jobs", "application/json", bytes.NewBufferString(`{"event_log":"http://example.com/log.csv","column_mapping":{"case_id":"Case ID"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status code %d for an unknown key, got %d", http.StatusBadRequest, res.StatusCode)
+	}
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"fmt"
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadStore keeps resumable upload sessions on disk. Each session has a metadata file and a data file in the store's
+// directory, so sessions survive restarts of the server.
+type UploadStore struct {
+	dir     string
+	uploads map[string]*model.Upload
+	logger  *log.Logger
+
+	lock sync.Mutex
+}
+
+// NewUploadStore creates the store's directory if needed and loads the existing sessions from it.
+func NewUploadStore(dir string, logger *log.Logger) (*UploadStore, error) {
+	s := &UploadStore{
+		dir:     dir,
+		uploads: map[string]*model.Upload{},
+		logger:  logger,
+	}
+
+	if err := mkdir(dir); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		upload := &model.Upload{}
+		if err := readJSON(path.Join(dir, entry.Name()), upload, logger); err != nil {
+			logger.Printf("error loading upload %s: %s", entry.Name(), err.Error())
+			continue
+		}
+
+		// the data file is the source of truth, because the server could stop in the middle of a chunk
+		if info, err := os.Stat(s.DataPath(upload)); err == nil {
+			upload.Offset = info.Size()
+		} else {
+			upload.Offset = 0
+		}
+
+		s.uploads[upload.ID] = upload
+	}
+
+	return s, nil
+}
+
+// Create starts a new upload session with the length and the settings of the given upload, its ID and timestamps are
+// set.
+func (s *UploadStore) Create(upload *model.Upload) error {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return err
+	}
+
+	upload.ID = id.String()
+	upload.CreatedAt = time.Now()
+	upload.UpdatedAt = upload.CreatedAt
+
+	f, err := os.Create(s.DataPath(upload))
+	if err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = s.save(upload); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.uploads[upload.ID] = upload
+
+	return nil
+}
+
+// Get returns the upload session with the given ID or nil if there is none.
+func (s *UploadStore) Get(id string) *model.Upload {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.uploads[id]
+}
+
+// Write appends a chunk of size bytes, or of an unknown size if it's negative, to the upload's data starting at the
+// given offset, which must be equal to the number of bytes received so far. A chunk which goes past the upload's length
+// is rejected and nothing of it is kept. If the chunk is interrupted, the received part is kept and the offset is
+// advanced accordingly, so the client can continue from there. The caller must hold the upload's lock.
+func (s *UploadStore) Write(upload *model.Upload, offset, size int64, r io.Reader) (int64, error) {
+	if current := upload.CurrentOffset(); offset != current {
+		return 0, fmt.Errorf("offset %d doesn't match the upload's offset %d", offset, current)
+	}
+
+	remaining := upload.Length - offset
+	if size > remaining {
+		return 0, fmt.Errorf("chunk of %d bytes exceeds the upload's length of %d bytes", size, upload.Length)
+	}
+
+	f, err := os.OpenFile(s.DataPath(upload), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	// a byte more than remains is read to detect the chunks of an unknown size which are too long
+	n, copyErr := io.Copy(f, io.LimitReader(r, remaining+1))
+
+	if err = f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	if n > remaining {
+		if err = os.Truncate(s.DataPath(upload), offset); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("chunk exceeds the upload's length of %d bytes", upload.Length)
+	}
+
+	// the offset is advanced by the bytes which have made it to the disk, also if the chunk has failed
+	info, err := os.Stat(s.DataPath(upload))
+	if err != nil {
+		return 0, err
+	}
+	n = info.Size() - offset
+	upload.Advance(n, time.Now())
+	if err = s.save(upload); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	return n, copyErr
+}
+
+// Remove deletes the upload session and its data.
+func (s *UploadStore) Remove(upload *model.Upload) error {
+	s.lock.Lock()
+	delete(s.uploads, upload.ID)
+	s.lock.Unlock()
+
+	if err := os.Remove(s.DataPath(upload)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.metadataPath(upload)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ByOwner returns the upload sessions of the owner.
+func (s *UploadStore) ByOwner(owner string) []*model.Upload {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var uploads []*model.Upload
+	for _, upload := range s.uploads {
+		if upload.Owner == owner {
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads
+}
+
+// ClearExpired removes sessions which haven't received any data for longer than the given duration.
+func (s *UploadStore) ClearExpired(ttl time.Duration) error {
+	s.lock.Lock()
+	var expired []*model.Upload
+	for _, upload := range s.uploads {
+		if upload.Copy().UpdatedAt.Add(ttl).Before(time.Now()) {
+			expired = append(expired, upload)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, upload := range expired {
+		if !upload.TryLock() {
+			continue
+		}
+		err := s.Remove(upload)
+		upload.Unlock()
+		if err != nil {
+			return fmt.Errorf("cannot remove upload %s: %s", upload.ID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// DataPath is the path of the file holding the received bytes of the upload.
+func (s *UploadStore) DataPath(upload *model.Upload) string {
+	return path.Join(s.dir, upload.ID+".part")
+}
+
+func (s *UploadStore) metadataPath(upload *model.Upload) string {
+	return path.Join(s.dir, upload.ID+".json")
+}
+
+func (s *UploadStore) save(upload *model.Upload) error {
+	return dumpJSON(s.metadataPath(upload), upload.Copy(), s.logger)
+}
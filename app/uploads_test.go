@@ -0,0 +1,244 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestUploads(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	app.config.PublicBaseURL = ts.URL
+	defer ts.Close()
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := len(eventLog) / 2
+
+	do := func(method, url string, body []byte, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = res.Body.Close() })
+		return res
+	}
+
+	expectStatus := func(res *http.Response, status int) {
+		t.Helper()
+		if res.StatusCode != status {
+			t.Fatalf("%s %s: expected status code %d, got %d", res.Request.Method, res.Request.URL, status, res.StatusCode)
+		}
+	}
+
+	// creating a session
+
+	metadata := func(pairs ...string) string {
+		var fields []string
+		for i := 0; i < len(pairs); i += 2 {
+			fields = append(fields, pairs[i]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[i+1])))
+		}
+		return strings.Join(fields, ",")
+	}
+
+	// the settings of the job are checked like the ones of POST /jobs
+
+	res := do("POST", ts.URL+"/uploads", nil, map[string]string{
+		"Tus-Resumable":   tusVersion,
+		"Upload-Length":   strconv.Itoa(len(eventLog)),
+		"Upload-Metadata": metadata("filename", "manual_log_5.csv", "timezone", "Mars/Olympus"),
+	})
+	expectStatus(res, http.StatusBadRequest)
+
+	res = do("POST", ts.URL+"/uploads", nil, map[string]string{
+		"Tus-Resumable": tusVersion,
+		"Upload-Length": strconv.Itoa(len(eventLog)),
+		"Upload-Metadata": metadata("filename", "manual_log_5.csv", "timezone", "Europe/Tallinn", "force", "true",
+			"pinned", "true", "preprocessing", `{"min_case_length":2}`),
+	})
+	expectStatus(res, http.StatusCreated)
+
+	var upload model.Upload
+	if err = json.NewDecoder(res.Body).Decode(&upload); err != nil {
+		t.Fatal(err)
+	}
+	uploadURL := res.Header.Get("Location")
+
+	// sending the first chunk slowly, the offset can be asked for in the meantime
+
+	body, bodyWriter := io.Pipe()
+	req, err := http.NewRequest("PATCH", uploadURL, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	patched := make(chan *http.Response)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		patched <- res
+	}()
+	for i := 0; i < half; i += 100 {
+		end := i + 100
+		if end > half {
+			end = half
+		}
+		if _, err = bodyWriter.Write(eventLog[i:end]); err != nil {
+			t.Fatal(err)
+		}
+		expectStatus(do("HEAD", uploadURL, nil, nil), http.StatusOK)
+		expectStatus(do("GET", uploadURL, nil, nil), http.StatusOK)
+	}
+	_ = bodyWriter.Close()
+	res = <-patched
+	_ = res.Body.Close()
+	expectStatus(res, http.StatusNoContent)
+
+	// checking the offset
+
+	res = do("HEAD", uploadURL, nil, nil)
+	expectStatus(res, http.StatusOK)
+	if res.Header.Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Fatalf("expected offset %d, got %s", half, res.Header.Get("Upload-Offset"))
+	}
+
+	// chunks which go past the upload's length are rejected and leave the offset as it is, whether their size is
+	// declared or not
+
+	tooLong := append(append([]byte{}, eventLog[half:]...), '\n')
+	res = do("PATCH", uploadURL, tooLong, map[string]string{"Upload-Offset": strconv.Itoa(half)})
+	expectStatus(res, http.StatusBadRequest)
+
+	req, err = http.NewRequest("PATCH", uploadURL, io.MultiReader(bytes.NewReader(tooLong)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Upload-Offset", strconv.Itoa(half))
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	expectStatus(res, http.StatusBadRequest)
+
+	res = do("HEAD", uploadURL, nil, nil)
+	if res.Header.Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Fatalf("expected offset %d after a rejected chunk, got %s", half, res.Header.Get("Upload-Offset"))
+	}
+	if info, err := os.Stat(app.uploads.DataPath(&upload)); err != nil || info.Size() != int64(half) {
+		t.Fatalf("expected %d bytes of data after a rejected chunk, got %v", half, info)
+	}
+
+	// finalizing too early and sending a chunk with a wrong offset
+
+	res = do("POST", uploadURL+"/finalize", nil, nil)
+	expectStatus(res, http.StatusConflict)
+
+	res = do("PATCH", uploadURL, eventLog[half:], map[string]string{"Upload-Offset": "0"})
+	expectStatus(res, http.StatusConflict)
+
+	// sending the rest with PUT and Content-Range
+
+	res = do("PUT", uploadURL, eventLog[half:], map[string]string{
+		"Content-Range": fmt.Sprintf("bytes %d-%d/%d", half, len(eventLog)-1, len(eventLog)),
+	})
+	expectStatus(res, http.StatusNoContent)
+
+	// the upload keeps its data if the job can't be queued
+
+	blocker := &model.Job{ID: "blocker", Status: model.JobStatusPending}
+	if err = app.queue.Add(blocker); err != nil {
+		t.Fatal(err)
+	}
+	app.config.MaxPendingJobs = 1
+	res = do("POST", uploadURL+"/finalize", nil, nil)
+	expectStatus(res, http.StatusTooManyRequests)
+	if b, err := os.ReadFile(app.uploads.DataPath(&upload)); err != nil || !bytes.Equal(b, eventLog) {
+		t.Fatalf("expected the upload to keep its data, got %d bytes, %v", len(b), err)
+	}
+	res = do("HEAD", uploadURL, nil, nil)
+	expectStatus(res, http.StatusOK)
+	if res.Header.Get("Upload-Offset") != strconv.Itoa(len(eventLog)) {
+		t.Fatalf("expected offset %d, got %s", len(eventLog), res.Header.Get("Upload-Offset"))
+	}
+	app.config.MaxPendingJobs = 0
+	if err = app.queue.Remove(blocker, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// finalizing into a job
+
+	res = do("POST", uploadURL+"/finalize", nil, nil)
+	expectStatus(res, http.StatusCreated)
+
+	var response model.ApiSingleJobResponse
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	job := app.queue.FindByID(response.ID)
+	defer func() {
+		if err := app.queue.Remove(job, true); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if job.EventLogName != "manual_log_5.csv" {
+		t.Fatalf("expected event log name manual_log_5.csv, got %s", job.EventLogName)
+	}
+
+	// the job gets the settings of the session like the one of an event log uploaded to POST /jobs
+	if job.Timezone != "Europe/Tallinn" || !job.Force || !job.Pinned {
+		t.Fatalf("unexpected settings: timezone %q, force %v, pinned %v", job.Timezone, job.Force, job.Pinned)
+	}
+	if job.Preprocessing == nil || job.Preprocessing.MinCaseLength != 2 {
+		t.Fatalf("unexpected preprocessing %+v", job.Preprocessing)
+	}
+	if job.AnalysisKind() != model.AnalysisKindWaitingTime {
+		t.Fatalf("unexpected kind %s", job.AnalysisKind())
+	}
+
+	b, err := os.ReadFile(path.Join(job.Dir, job.EventLogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, eventLog) {
+		t.Fatalf("stored event log differs from the uploaded one")
+	}
+
+	if app.uploads.Get(upload.ID) != nil {
+		t.Fatalf("upload session should be removed after finalizing")
+	}
+
+	res = do("HEAD", uploadURL, nil, nil)
+	expectStatus(res, http.StatusNotFound)
+}
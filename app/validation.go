@@ -0,0 +1,254 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+// maxDiagnostics limits the number of problems reported for one event log.
+const maxDiagnostics = 100
+
+// canonicalColumnMapping is used for event logs submitted without a column mapping.
+var canonicalColumnMapping = model.ColumnMapping{
+	Case:           canonicalCaseColumn,
+	Activity:       canonicalActivityColumn,
+	Resource:       canonicalResourceColumn,
+	StartTimestamp: canonicalStartColumn,
+	EndTimestamp:   canonicalEndColumn,
+}
+
+// eventLogValidation collects the problems found in an event log.
+type eventLogValidation struct {
+	Diagnostics []*model.EventLogDiagnostic
+	Truncated   bool
+}
+
+func (v *eventLogValidation) add(diagnostic *model.EventLogDiagnostic) {
+	if len(v.Diagnostics) >= maxDiagnostics {
+		v.Truncated = true
+		return
+	}
+	v.Diagnostics = append(v.Diagnostics, diagnostic)
+}
+
+// Valid reports whether no problems have been found.
+func (v *eventLogValidation) Valid() bool {
+	return len(v.Diagnostics) == 0
+}
+
+// Error summarizes the problems for the job's error message.
+func (v *eventLogValidation) Error() string {
+	if v.Valid() {
+		return ""
+	}
+
+	first := v.Diagnostics[0]
+	message := first.Message
+	if first.Row > 0 {
+		message = fmt.Sprintf("line %d: %s", first.Row, message)
+	}
+
+	count := fmt.Sprint(len(v.Diagnostics))
+	if v.Truncated {
+		count = "more than " + count
+	}
+	return fmt.Sprintf("event log is invalid, %s problem(s) found, the first one is %s", count, message)
+}
+
+// Response returns the body of the 422 response.
+func (v *eventLogValidation) Response() model.ApiResponseValidationError {
+	return model.ApiResponseValidationError{
+		Error:       v.Error(),
+		Diagnostics: v.Diagnostics,
+		Truncated:   v.Truncated,
+	}
+}
+
+// validateColumnMapping checks a column mapping on its own. It's the only check possible before the event log is
+// available. No mapping is valid, the canonical columns are used then.
+func validateColumnMapping(columnMapping *model.ColumnMapping) *eventLogValidation {
+	validation := &eventLogValidation{}
+	if columnMapping == nil {
+		return validation
+	}
+
+	for _, diagnostic := range columnMapping.Validate() {
+		validation.add(diagnostic)
+	}
+	return validation
+}
+
+// validateEventLog reads the CSV event log at filePath and checks that the mapped columns exist, the timestamps parse,
+// every event starts before it ends and belongs to a case and the log isn't empty. The returned error is set only if the
+// file can't be read at all.
+func validateEventLog(filePath string, columnMapping *model.ColumnMapping) (*eventLogValidation, error) {
+	validation := validateColumnMapping(columnMapping)
+	if !validation.Valid() {
+		return validation, nil
+	}
+
+	if columnMapping == nil {
+		columnMapping = &canonicalColumnMapping
+	}
+	columns := columnMapping.Roles()
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		validation.add(&model.EventLogDiagnostic{Message: "event log is empty"})
+		return validation, nil
+	} else if err != nil {
+		validation.add(&model.EventLogDiagnostic{Row: 1, Message: fmt.Sprintf("invalid CSV header: %s", err.Error())})
+		return validation, nil
+	}
+
+	indices := map[string]int{}
+	for i, name := range header {
+		// Excel and others prepend a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\xef\xbb\xbf")
+		}
+		indices[strings.TrimSpace(name)] = i
+	}
+
+	index := map[string]int{}
+	for _, key := range sortedColumnMappingKeys(columns) {
+		i, ok := indices[columns[key]]
+		if !ok {
+			validation.add(&model.EventLogDiagnostic{
+				Row:     1,
+				Column:  columns[key],
+				Message: fmt.Sprintf("%s column %q is missing", key, columns[key]),
+			})
+			continue
+		}
+		index[key] = i
+	}
+	for _, column := range append(append([]string{}, columnMapping.CaseAttributes...), columnMapping.EventAttributes...) {
+		if _, ok := indices[column]; !ok {
+			validation.add(&model.EventLogDiagnostic{
+				Row:     1,
+				Column:  column,
+				Message: fmt.Sprintf("attribute column %q is missing", column),
+			})
+		}
+	}
+	if !validation.Valid() {
+		return validation, nil
+	}
+
+	parsers := map[string]timestampParser{}
+	for _, key := range []string{model.ColumnMappingStartTimestamp, model.ColumnMappingEndTimestamp} {
+		parsers[key] = newTimestampParser(columnMapping, columns[key], nil)
+	}
+
+	events := 0
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+			validation.add(&model.EventLogDiagnostic{
+				Row:     parseErr.StartLine,
+				Message: fmt.Sprintf("expected %d fields, got %d", len(header), len(record)),
+			})
+			continue
+		} else if parseErr != nil {
+			validation.add(&model.EventLogDiagnostic{Row: parseErr.StartLine, Message: fmt.Sprintf("invalid CSV: %s", parseErr.Err.Error())})
+			return validation, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		line, _ := r.FieldPos(0)
+		events++
+
+		caseID := record[index[model.ColumnMappingCase]]
+		if strings.TrimSpace(caseID) == "" {
+			validation.add(&model.EventLogDiagnostic{
+				Row:     line,
+				Column:  columns[model.ColumnMappingCase],
+				Message: "event doesn't belong to a case",
+			})
+		}
+
+		if strings.TrimSpace(record[index[model.ColumnMappingActivity]]) == "" {
+			validation.add(&model.EventLogDiagnostic{
+				Row:     line,
+				Column:  columns[model.ColumnMappingActivity],
+				Case:    caseID,
+				Message: "activity is empty",
+			})
+		}
+
+		var times [2]time.Time
+		valid := true
+		for i, key := range []string{model.ColumnMappingStartTimestamp, model.ColumnMappingEndTimestamp} {
+			value := record[index[key]]
+			if times[i], err = parsers[key].Parse(value); err != nil {
+				valid = false
+				message := fmt.Sprintf("%s is not a valid timestamp", key)
+				var timestampErr *timestampError
+				if errors.As(err, &timestampErr) && timestampErr.Ambiguous {
+					message = fmt.Sprintf("%s is ambiguous, %s", key, timestampErr.Reason)
+				}
+				validation.add(&model.EventLogDiagnostic{
+					Row:     line,
+					Column:  columns[key],
+					Case:    caseID,
+					Value:   value,
+					Message: message,
+				})
+			}
+		}
+
+		if valid && times[0].After(times[1]) {
+			validation.add(&model.EventLogDiagnostic{
+				Row:     line,
+				Column:  columns[model.ColumnMappingStartTimestamp],
+				Case:    caseID,
+				Value:   record[index[model.ColumnMappingStartTimestamp]],
+				Message: "event starts after it ends",
+			})
+		}
+
+		if validation.Truncated {
+			return validation, nil
+		}
+	}
+
+	if events == 0 {
+		validation.add(&model.EventLogDiagnostic{Message: "event log has no events"})
+	}
+
+	return validation, nil
+}
+
+func sortedColumnMappingKeys(columnMapping map[string]string) []string {
+	keys := make([]string, 0, len(columnMapping))
+	for k := range columnMapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
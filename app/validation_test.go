@@ -0,0 +1,309 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestValidateEventLog(t *testing.T) {
+	mapping := &model.ColumnMapping{
+		Case:           "case_id",
+		Activity:       "activity",
+		Resource:       "resource",
+		StartTimestamp: "start_timestamp",
+		EndTimestamp:   "end_timestamp",
+	}
+
+	tests := []struct {
+		name          string
+		file          string
+		content       string
+		columnMapping *model.ColumnMapping
+		want          []model.EventLogDiagnostic
+	}{
+		{
+			name: "sample log",
+			file: "../assets/samples/manual_log_5.csv",
+		},
+		{
+			name:          "sample log with mapping",
+			file:          "../assets/samples/manual_log_5_columns.csv",
+			columnMapping: mapping,
+		},
+		{
+			name: "missing columns",
+			file: "../assets/samples/manual_log_5_columns.csv",
+			want: []model.EventLogDiagnostic{
+				{Row: 1, Column: "concept:name", Message: `activity column "concept:name" is missing`},
+				{Row: 1, Column: "case:concept:name", Message: `case column "case:concept:name" is missing`},
+				{Row: 1, Column: "time:timestamp", Message: `end_timestamp column "time:timestamp" is missing`},
+				{Row: 1, Column: "org:resource", Message: `resource column "org:resource" is missing`},
+			},
+		},
+		{
+			name:          "incomplete mapping",
+			file:          "../assets/samples/manual_log_5_columns.csv",
+			columnMapping: &model.ColumnMapping{Case: "case_id", Activity: "activity", Resource: "resource"},
+			want: []model.EventLogDiagnostic{
+				{Column: "start_timestamp", Message: `column mapping for "start_timestamp" is required`},
+				{Column: "end_timestamp", Message: `column mapping for "end_timestamp" is required`},
+			},
+		},
+		{
+			name: "timestamp formats and attributes",
+			content: "Case ID,Activity & Step,Start,End,Resource,Region\n" +
+				"0,A,16/05/2022 10:00,16/05/2022 10:15,Marcus,EU\n" +
+				"0,B,2022-05-16T12:00:00,16/05/2022 12:30,Anya,EU\n",
+			columnMapping: &model.ColumnMapping{
+				Case:             "Case ID",
+				Activity:         "Activity & Step",
+				Resource:         "Resource",
+				StartTimestamp:   "Start",
+				EndTimestamp:     "End",
+				CaseAttributes:   []string{"Region"},
+				TimestampFormats: map[string]string{"Start": "%d/%m/%Y %H:%M", "End": "%d/%m/%Y %H:%M"},
+			},
+			want: []model.EventLogDiagnostic{
+				{Row: 3, Column: "Start", Case: "0", Value: "2022-05-16T12:00:00", Message: "start_timestamp is not a valid timestamp"},
+			},
+		},
+		{
+			name: "missing attribute column",
+			file: "../assets/samples/manual_log_5_columns.csv",
+			columnMapping: &model.ColumnMapping{
+				Case:            "case_id",
+				Activity:        "activity",
+				Resource:        "resource",
+				StartTimestamp:  "start_timestamp",
+				EndTimestamp:    "end_timestamp",
+				EventAttributes: []string{"cost"},
+			},
+			want: []model.EventLogDiagnostic{
+				{Row: 1, Column: "cost", Message: `attribute column "cost" is missing`},
+			},
+		},
+		{
+			name: "invalid rows",
+			content: "case_id,activity,start_timestamp,end_timestamp,resource\n" +
+				"0,A,2022-05-16T10:00:00.000,2022-05-16T10:15:00.000,Marcus\n" +
+				"0,B,2022-05-16 12:30:00,2022-05-16 12:00:00,Anya\n" +
+				",C,2022-05-16T13:00:00Z,2022-05-16T13:30:00Z,Anya\n" +
+				"1,A,yesterday,2022-05-16T10:15:00+02:00,Marcus\n" +
+				"1,B\n",
+			columnMapping: mapping,
+			want: []model.EventLogDiagnostic{
+				{Row: 3, Column: "start_timestamp", Case: "0", Value: "2022-05-16 12:30:00", Message: "event starts after it ends"},
+				{Row: 4, Column: "case_id", Message: "event doesn't belong to a case"},
+				{Row: 5, Column: "start_timestamp", Case: "1", Value: "yesterday", Message: "start_timestamp is not a valid timestamp"},
+				{Row: 6, Message: "expected 5 fields, got 2"},
+			},
+		},
+		{
+			name: "ambiguous timestamps",
+			content: "case_id,activity,start_timestamp,end_timestamp,resource\n" +
+				"0,A,05/06/2022 10:00,1652685300000,Marcus\n",
+			columnMapping: mapping,
+			want: []model.EventLogDiagnostic{
+				{
+					Row: 2, Column: "start_timestamp", Case: "0", Value: "05/06/2022 10:00",
+					Message: "start_timestamp is ambiguous, the order of day and month depends on the locale, give the column's format in timestamp_formats",
+				},
+			},
+		},
+		{
+			name:          "no events",
+			content:       "case_id,activity,start_timestamp,end_timestamp,resource\n",
+			columnMapping: mapping,
+			want: []model.EventLogDiagnostic{
+				{Message: "event log has no events"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := tt.file
+			if filePath == "" {
+				filePath = path.Join(t.TempDir(), "log.csv")
+				if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			validation, err := validateEventLog(filePath, tt.columnMapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(validation.Diagnostics) != len(tt.want) {
+				t.Fatalf("expected %d diagnostics, got %d: %s", len(tt.want), len(validation.Diagnostics), validation.Error())
+			}
+			for i, diagnostic := range validation.Diagnostics {
+				if *diagnostic != tt.want[i] {
+					t.Fatalf("unexpected diagnostic\n got: %+v\nwant: %+v", *diagnostic, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPostJobFromBody_InvalidEventLog(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	body := "case:concept:name,concept:name,start_timestamp,time:timestamp,org:resource\n0,A,2022-05-16T10:30:00,2022-05-16T10:00:00,Cole\n"
+	res, err := http.Post(ts.URL+"/jobs", "text/csv", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status code %d, got %d", http.StatusUnprocessableEntity, res.StatusCode)
+	}
+
+	var response model.ApiResponseValidationError
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Diagnostics) != 1 || response.Diagnostics[0].Row != 2 {
+		t.Fatalf("unexpected diagnostics %+v", response.Diagnostics)
+	}
+
+	if len(app.queue.Jobs) != 0 {
+		t.Fatalf("expected no jobs in the queue, got %d", len(app.queue.Jobs))
+	}
+
+	entries, err := os.ReadDir(app.config.ResultsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Fatalf("expected the job's directory to be removed, found %s", entry.Name())
+		}
+	}
+}
+
+func TestPostJob_EventLogURL(t *testing.T) {
+	logs := map[string]string{
+		"/valid.csv":   "case:concept:name,concept:name,start_timestamp,time:timestamp,org:resource\n0,A,2022-05-16T10:00:00,2022-05-16T10:30:00,Cole\n",
+		"/invalid.csv": "case:concept:name,concept:name,start_timestamp,time:timestamp,org:resource\n0,A,2022-05-16T10:30:00,2022-05-16T10:00:00,Cole\n",
+	}
+	logServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := logs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		_, _ = w.Write([]byte(content))
+	}))
+	defer logServer.Close()
+
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.DownloadAllowList = []string{"127.0.0.1"}
+	if app.fetcher, err = NewFetcher(app.config, app.logger); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	post := func(name string) *http.Response {
+		body := `{"event_log":"` + logServer.URL + name + `"}`
+		res, err := http.Post(ts.URL+"/jobs", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	t.Run("invalid event log", func(t *testing.T) {
+		res := post("/invalid.csv")
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status code %d, got %d", http.StatusUnprocessableEntity, res.StatusCode)
+		}
+
+		var response model.ApiResponseValidationError
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Diagnostics) != 1 || response.Diagnostics[0].Row != 2 {
+			t.Fatalf("unexpected diagnostics %+v", response.Diagnostics)
+		}
+
+		if len(app.queue.Jobs) != 0 {
+			t.Fatalf("expected no jobs in the queue, got %d", len(app.queue.Jobs))
+		}
+
+		entries, err := os.ReadDir(app.config.ResultsDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				t.Fatalf("expected the job's directory to be removed, found %s", entry.Name())
+			}
+		}
+	})
+
+	t.Run("missing event log", func(t *testing.T) {
+		res := post("/missing.csv")
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, res.StatusCode)
+		}
+		if len(app.queue.Jobs) != 0 {
+			t.Fatalf("expected no jobs in the queue, got %d", len(app.queue.Jobs))
+		}
+	})
+
+	t.Run("valid event log", func(t *testing.T) {
+		res := post("/valid.csv")
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, res.StatusCode)
+		}
+
+		var response model.ApiSingleJobResponse
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		job := app.queue.FindByID(response.ID)
+		defer func() {
+			if err := app.queue.Remove(job, true); err != nil {
+				t.Fatal(err)
+			}
+		}()
+
+		// the worker analyses the downloaded event log instead of downloading it again
+		if !job.EventLogDownloaded() {
+			t.Fatal("expected the event log to be downloaded")
+		}
+		if _, err := os.Stat(path.Join(job.Dir, job.EventLogFileName())); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
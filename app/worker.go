@@ -0,0 +1,330 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+	"github.com/google/uuid"
+)
+
+// Worker runs the analyses of an application with Configuration.RemoteWorkers on another machine. It leases an
+// analysis, downloads the event log, runs the analysis like the application would, see runAnalysis, uploads the files
+// the analysis has written and completes the lease, renewing the lease with heartbeats meanwhile. The worker is
+// configured like the application, with Configuration.ServerURL and Configuration.WorkerToken to reach it.
+type Worker struct {
+	ID string
+
+	// app runs the analyses locally
+	app    *Application
+	server *url.URL
+	client *http.Client
+}
+
+func NewWorker(config *Configuration) (*Worker, error) {
+	server, err := url.Parse(config.ServerURL)
+	if err != nil || (server.Scheme != "http" && server.Scheme != "https") || server.Host == "" {
+		return nil, fmt.Errorf("server_url must be an absolute http or https URL to run a worker")
+	}
+	if config.WorkerToken == "" {
+		return nil, fmt.Errorf("worker_token is required to run a worker")
+	}
+	if err = mkdir(config.WorkerDir); err != nil {
+		return nil, err
+	}
+
+	local := *config
+	local.RemoteWorkers = false
+
+	hostname, _ := os.Hostname()
+	return &Worker{
+		ID: fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8]),
+		app: &Application{
+			config:   &local,
+			logger:   log.New(os.Stdout, "", log.Ldate|log.Ltime),
+			analyses: newAnalysisDefinitions(),
+			runner:   &ExecRunner{GracePeriod: local.AnalysisGracePeriod},
+		},
+		server: server,
+		client: &http.Client{},
+	}, nil
+}
+
+// Run leases and runs analyses one after another until ctx is done. A lease which is being worked on then is
+// abandoned, it expires and the analysis is handed out to another worker.
+func (w *Worker) Run(ctx context.Context) {
+	w.app.logger.Printf("Worker %s started, leasing analyses from %s", w.ID, w.server)
+
+	for ctx.Err() == nil {
+		leased, err := w.work(ctx)
+		if err != nil {
+			w.app.logger.Printf("Worker %s error: %s", w.ID, err.Error())
+		}
+		if leased && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.app.config.WorkerPollInterval):
+		}
+	}
+
+	w.app.logger.Printf("Worker %s stopped", w.ID)
+}
+
+// work leases an analysis and runs it. It reports whether there's been an analysis to run.
+func (w *Worker) work(ctx context.Context) (bool, error) {
+	lease, err := w.lease(ctx)
+	if err != nil || lease == nil {
+		return false, err
+	}
+	w.app.logger.Printf("Job %s leased, attempt %d", lease.JobID, lease.Attempt)
+
+	result := w.process(ctx, lease)
+	if ctx.Err() != nil {
+		w.app.logger.Printf("Job %s abandoned, the worker is stopping", lease.JobID)
+		return true, nil
+	}
+	if errors.Is(result, errLeaseGone) {
+		w.app.logger.Printf("Job %s stopped, its lease is gone", lease.JobID)
+		return true, nil
+	}
+
+	var completion model.LeaseCompletion
+	if result != nil {
+		w.app.logger.Printf("Job %s failed; %s", lease.JobID, result.Error())
+		completion.Error = result.Error()
+	} else {
+		w.app.logger.Printf("Job %s completed", lease.JobID)
+	}
+
+	body, err := json.Marshal(&completion)
+	if err != nil {
+		return true, err
+	}
+	res, err := w.request(ctx, "POST", "/workers/leases/"+lease.ID+"/completion", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	return true, checkWorkerResponse(res, http.StatusNoContent)
+}
+
+// process runs the analysis of the lease in a directory of its own and uploads its output. It returns errLeaseGone if
+// the lease has been lost meanwhile.
+func (w *Worker) process(ctx context.Context, lease *model.Lease) error {
+	dir, err := os.MkdirTemp(w.app.config.WorkerDir, lease.JobID+"-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			w.app.logger.Printf("error removing the directory of job %s: %s", lease.JobID, err.Error())
+		}
+	}()
+
+	// the heartbeats stop the analysis when the lease is lost, the goroutine is done before process returns
+	ctx, cancel := context.WithCancel(ctx)
+	lost := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		w.heartbeat(ctx, lease, func() {
+			close(lost)
+			cancel()
+		})
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	err = w.run(ctx, lease, dir)
+	select {
+	case <-lost:
+		return errLeaseGone
+	default:
+		return err
+	}
+}
+
+// run downloads the event log of the lease into dir, runs the analysis and uploads the files it has written.
+func (w *Worker) run(ctx context.Context, lease *model.Lease, dir string) error {
+	eventLogName := sanitizeFileName(lease.EventLogName)
+	if err := w.download(ctx, lease.ID, path.Join(dir, eventLogName)); err != nil {
+		return fmt.Errorf("error downloading event log: %s", err.Error())
+	}
+
+	job := &model.Job{
+		ID:            lease.JobID,
+		Dir:           dir,
+		Kind:          lease.Kind,
+		Params:        lease.Params,
+		ColumnMapping: lease.ColumnMapping,
+	}
+	if err := w.app.runAnalysis(ctx, eventLogName, job); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == eventLogName || name == columnsFileName {
+			continue
+		}
+		// the application accepts only the names it would have given the files itself
+		if name != sanitizeFileName(name) {
+			w.app.logger.Printf("Job %s output file %q skipped", lease.JobID, name)
+			continue
+		}
+		if err = w.upload(ctx, lease.ID, path.Join(dir, name)); err != nil {
+			return fmt.Errorf("error uploading %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// heartbeat renews the lease every heartbeat interval until ctx is done, and calls lost if the lease is gone.
+func (w *Worker) heartbeat(ctx context.Context, lease *model.Lease, lost func()) {
+	interval := time.Duration(lease.HeartbeatInterval * float64(time.Second))
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		res, err := w.request(ctx, "POST", "/workers/leases/"+lease.ID+"/heartbeat", nil)
+		if err == nil {
+			err = checkWorkerResponse(res, http.StatusOK)
+			res.Body.Close()
+		}
+		if errors.Is(err, errLeaseGone) {
+			lost()
+			return
+		}
+		// the lease is kept until it expires, the next heartbeat may get through
+		if err != nil && ctx.Err() == nil {
+			w.app.logger.Printf("Job %s heartbeat failed; %s", lease.JobID, err.Error())
+		}
+	}
+}
+
+// lease asks the application for an analysis, it returns nil if there's none.
+func (w *Worker) lease(ctx context.Context) (*model.Lease, error) {
+	body, err := json.Marshal(&model.LeaseRequest{WorkerID: w.ID})
+	if err != nil {
+		return nil, err
+	}
+	res, err := w.request(ctx, "POST", "/workers/leases", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if err = checkWorkerResponse(res, http.StatusCreated); err != nil {
+		return nil, err
+	}
+
+	var lease model.Lease
+	if err = json.NewDecoder(res.Body).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("invalid lease: %s", err.Error())
+	}
+	return &lease, nil
+}
+
+// download saves the event log of the lease to filePath.
+func (w *Worker) download(ctx context.Context, leaseID, filePath string) error {
+	res, err := w.request(ctx, "GET", "/workers/leases/"+leaseID+"/event-log", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err = checkWorkerResponse(res, http.StatusOK); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, res.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// upload sends the file to the job's directory of the lease.
+func (w *Worker) upload(ctx context.Context, leaseID, filePath string) error {
+	f, err := openRegularFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res, err := w.request(ctx, "PUT", "/workers/leases/"+leaseID+"/files/"+path.Base(filePath), f)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkWorkerResponse(res, http.StatusNoContent)
+}
+
+// request sends a request with the worker token to the path relative to the application's URL.
+func (w *Worker) request(ctx context.Context, method, p string, body io.Reader) (*http.Response, error) {
+	u := *w.server
+	u.Path = strings.TrimSuffix(u.Path, "/") + p
+	u.RawPath = ""
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+w.app.config.WorkerToken)
+	if method == "POST" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return w.client.Do(req)
+}
+
+// checkWorkerResponse returns errLeaseGone for 410, and the error of the response if its status isn't the expected
+// one.
+func checkWorkerResponse(res *http.Response, statusCode int) error {
+	if res.StatusCode == statusCode {
+		return nil
+	}
+	if res.StatusCode == http.StatusGone {
+		return errLeaseGone
+	}
+
+	var apiError model.ApiResponseError
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&apiError); err == nil && apiError.Error != "" {
+		return fmt.Errorf("%s: %s", res.Status, apiError.Error)
+	}
+	return fmt.Errorf("unexpected status %s", res.Status)
+}
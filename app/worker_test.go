@@ -0,0 +1,180 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AutomatedProcessImprovement/waiting-time-backend/model"
+)
+
+func TestWorker(t *testing.T) {
+	app, err := makeTestApplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	app.config.QueuePath = path.Join(t.TempDir(), "queue.gob")
+	app.config.RemoteWorkers = true
+	app.config.WorkerToken = "secret"
+	app.leases = NewLeaseStore(300*time.Millisecond, 3)
+	app.initializeRouter()
+
+	ts := httptest.NewServer(app.GetRouter())
+	defer ts.Close()
+
+	// the workers run on the same machine, but share nothing with the application besides its URL
+	startWorker := func(t *testing.T, run func(ctx context.Context, cmd *AnalysisCommand) error) *fakeRunner {
+		config := DefaultConfiguration()
+		config.ServerURL = ts.URL
+		config.WorkerToken = "secret"
+		config.WorkerDir = t.TempDir()
+		config.WorkerPollInterval = 20 * time.Millisecond
+
+		worker, err := NewWorker(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runner := &fakeRunner{run: run}
+		worker.app.runner = runner
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.Run(ctx)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			wg.Wait()
+		})
+		return runner
+	}
+
+	eventLog, err := os.ReadFile("../assets/samples/manual_log_5.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit := func() *model.Job {
+		res, err := http.Post(ts.URL+"/jobs?kind=calendar_discovery", "text/csv", bytes.NewReader(eventLog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, res.StatusCode)
+		}
+
+		var response model.ApiSingleJobResponse
+		if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		job := app.queue.FindByID(response.ID)
+		job.Force = true
+		t.Cleanup(func() {
+			if err := app.queue.Remove(job, true); err != nil {
+				t.Fatal(err)
+			}
+		})
+		return job
+	}
+	workerRequest := func(method, p, token string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+p, strings.NewReader(`{"worker_id":"dead"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		if res := workerRequest("POST", "/workers/leases", "wrong"); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status code %d, got %d", http.StatusUnauthorized, res.StatusCode)
+		}
+		if res := workerRequest("POST", "/workers/leases", "secret"); res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, res.StatusCode)
+		}
+	})
+
+	t.Run("dead worker's lease expires", func(t *testing.T) {
+		job := submit()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			app.processJob(job)
+		}()
+
+		// a worker leases the analysis and dies
+		deadline := time.Now().Add(5 * time.Second)
+		for workerRequest("POST", "/workers/leases", "secret").StatusCode != http.StatusCreated {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the analysis to be leased")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// another one takes over after the lease has expired, its analysis outlives a few heartbeats
+		runner := startWorker(t, func(ctx context.Context, cmd *AnalysisCommand) error {
+			if _, err := os.Stat(cmd.Args[1]); err != nil {
+				return fmt.Errorf("expected the event log to be downloaded: %s", err.Error())
+			}
+			time.Sleep(500 * time.Millisecond)
+			return os.WriteFile(cmd.Args[2], []byte(`[{"id":"Anya_calendar","name":"Anya_calendar","time_periods":[]}]`), 0644)
+		})
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("expected the job to be processed")
+		}
+		if job.Status != model.JobStatusCompleted || job.ResultFile == nil {
+			t.Fatalf("expected a completed job with a result file, got status %s and error %q", job.Status, job.Error)
+		}
+		if len(runner.commands) != 1 || strings.HasPrefix(runner.commands[0].Args[1], job.Dir) {
+			t.Fatalf("expected the analysis to run in the worker's directory, got %+v", runner.commands)
+		}
+
+		var result model.CalendarDiscoveryResult
+		res, err := http.Get(ts.URL + "/jobs/" + job.ID + "/calendars")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Calendars) != 1 || result.Calendars[0].ID != "Anya_calendar" {
+			t.Fatalf("expected the uploaded calendars, got %+v", result.Calendars)
+		}
+	})
+
+	t.Run("failed analysis fails the job", func(t *testing.T) {
+		startWorker(t, func(ctx context.Context, cmd *AnalysisCommand) error {
+			fmt.Fprint(cmd.Stderr, "no resources found")
+			return fmt.Errorf("exit status 1")
+		})
+
+		job := submit()
+		app.processJob(job)
+
+		if job.Status != model.JobStatusFailed || !strings.Contains(job.Error, "no resources found") {
+			t.Fatalf("expected a failed job with the worker's error, got status %s and error %q", job.Status, job.Error)
+		}
+	})
+}
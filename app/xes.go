@@ -0,0 +1,296 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns of the canonical CSV event log which the analysis reads without a column mapping.
+const (
+	canonicalCaseColumn     = "case:concept:name"
+	canonicalActivityColumn = "concept:name"
+	canonicalResourceColumn = "org:resource"
+	canonicalStartColumn    = "start_timestamp"
+	canonicalEndColumn      = "time:timestamp"
+
+	canonicalTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// XES attribute keys with a special meaning.
+const (
+	xesConceptName = "concept:name"
+	xesInstance    = "concept:instance"
+	xesResource    = "org:resource"
+	xesTimestamp   = "time:timestamp"
+	xesLifecycle   = "lifecycle:transition"
+)
+
+var isoTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02T15:04:05.999999999",
+}
+
+type xesEvent struct {
+	attributes map[string]string
+	timestamp  time.Time
+}
+
+type xesTrace struct {
+	attributes map[string]string
+	events     []xesEvent
+}
+
+// xesToCSV converts an XES event log into the canonical CSV event log. Events with the lifecycle "start" are merged
+// with the following "complete" events of the same activity (and activity instance, if given) into one row with both
+// timestamps. Events without a lifecycle and unmatched "complete" events get the same start and end time, other
+// lifecycle transitions are skipped. Trace attributes become "case:"-prefixed columns, other event attributes are kept
+// as they are.
+//
+// The file is read twice, first to collect the attribute keys for the CSV header, then to write the rows, so that only
+// one trace at a time is kept in memory.
+func xesToCSV(srcPath, dstPath string) error {
+	traceKeys := map[string]bool{}
+	eventKeys := map[string]bool{}
+
+	err := readXESFile(srcPath, func(trace *xesTrace) error {
+		for k := range trace.attributes {
+			traceKeys[k] = true
+		}
+		for _, event := range trace.events {
+			for k := range event.attributes {
+				eventKeys[k] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(traceKeys, xesConceptName)
+	for _, k := range []string{xesConceptName, xesResource, xesTimestamp, xesLifecycle} {
+		delete(eventKeys, k)
+	}
+
+	traceColumns := sortedKeys(traceKeys)
+	eventColumns := sortedKeys(eventKeys)
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	buf := bufio.NewWriter(out)
+	w := csv.NewWriter(buf)
+
+	header := []string{canonicalCaseColumn, canonicalActivityColumn, canonicalResourceColumn, canonicalStartColumn, canonicalEndColumn}
+	for _, k := range traceColumns {
+		header = append(header, "case:"+k)
+	}
+	header = append(header, eventColumns...)
+	if err = w.Write(header); err != nil {
+		return err
+	}
+
+	traceIndex := 0
+	err = readXESFile(srcPath, func(trace *xesTrace) error {
+		caseID, ok := trace.attributes[xesConceptName]
+		if !ok {
+			caseID = strconv.Itoa(traceIndex)
+		}
+		traceIndex++
+
+		for _, activity := range mergeXESLifecycle(trace.events) {
+			record := []string{
+				caseID,
+				activity.attributes[xesConceptName],
+				activity.attributes[xesResource],
+				activity.start.Format(canonicalTimeLayout),
+				activity.end.Format(canonicalTimeLayout),
+			}
+			for _, k := range traceColumns {
+				record = append(record, trace.attributes[k])
+			}
+			for _, k := range eventColumns {
+				record = append(record, activity.attributes[k])
+			}
+
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+type xesActivity struct {
+	attributes map[string]string
+	start      time.Time
+	end        time.Time
+}
+
+func mergeXESLifecycle(events []xesEvent) []*xesActivity {
+	var activities []*xesActivity
+	started := map[string][]*xesActivity{}
+
+	for _, event := range events {
+		key := event.attributes[xesConceptName] + "\x00" + event.attributes[xesInstance]
+
+		switch strings.ToLower(event.attributes[xesLifecycle]) {
+		case "start":
+			activity := &xesActivity{attributes: event.attributes, start: event.timestamp, end: event.timestamp}
+			activities = append(activities, activity)
+			started[key] = append(started[key], activity)
+
+		case "complete", "":
+			if pending := started[key]; len(pending) > 0 {
+				activity := pending[0]
+				started[key] = pending[1:]
+				activity.end = event.timestamp
+				// the complete event often carries the resource and other attributes
+				for k, v := range event.attributes {
+					activity.attributes[k] = v
+				}
+				continue
+			}
+			activities = append(activities, &xesActivity{attributes: event.attributes, start: event.timestamp, end: event.timestamp})
+		}
+	}
+
+	return activities
+}
+
+func readXESFile(filePath string, onTrace func(trace *xesTrace) error) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readXES(bufio.NewReader(f), onTrace)
+}
+
+// readXES streams an XES document and calls onTrace for every trace. Global attributes, extensions and classifiers are
+// ignored, as well as nested attributes.
+func readXES(r io.Reader, onTrace func(trace *xesTrace) error) error {
+	decoder := xml.NewDecoder(r)
+
+	var (
+		trace *xesTrace
+		event *xesEvent
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid XES document: %s", err.Error())
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "log":
+				continue
+			case "trace":
+				trace = &xesTrace{attributes: map[string]string{}}
+				continue
+			case "event":
+				if trace == nil {
+					return fmt.Errorf("invalid XES document: event outside of a trace")
+				}
+				event = &xesEvent{attributes: map[string]string{}}
+				continue
+			}
+
+			// the element is an attribute or a log-level element such as a global or an extension
+			if trace != nil {
+				key, value := xmlAttr(t, "key"), xmlAttr(t, "value")
+
+				if event != nil {
+					if key == xesTimestamp && t.Name.Local == "date" {
+						if event.timestamp, err = parseISOTimestamp(value); err != nil {
+							return err
+						}
+					}
+					if t.Name.Local != "list" && t.Name.Local != "container" {
+						event.attributes[key] = value
+					}
+				} else if t.Name.Local != "list" && t.Name.Local != "container" {
+					trace.attributes[key] = value
+				}
+			}
+
+			if err = decoder.Skip(); err != nil {
+				return fmt.Errorf("invalid XES document: %s", err.Error())
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "event":
+				if event.timestamp.IsZero() {
+					return fmt.Errorf("event %q in trace %q has no %s", event.attributes[xesConceptName], trace.attributes[xesConceptName], xesTimestamp)
+				}
+				trace.events = append(trace.events, *event)
+				event = nil
+			case "trace":
+				if err = onTrace(trace); err != nil {
+					return err
+				}
+				trace = nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseISOTimestamp(value string) (time.Time, error) {
+	for _, layout := range isoTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/csv"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+const testXES = `<?xml version="1.0" encoding="UTF-8" ?>
+<log xes.version="1.0" xmlns="http://www.xes-standard.org/">
+	<extension name="Concept" prefix="concept" uri="http://www.xes-standard.org/concept.xesext"/>
+	<global scope="event">
+		<string key="concept:name" value="__INVALID__"/>
+	</global>
+	<string key="concept:name" value="test log"/>
+	<trace>
+		<string key="concept:name" value="case-1"/>
+		<string key="variant" value="v1"/>
+		<event>
+			<string key="concept:name" value="A"/>
+			<string key="lifecycle:transition" value="start"/>
+			<date key="time:timestamp" value="2022-05-16T10:00:00.000+02:00"/>
+		</event>
+		<event>
+			<string key="concept:name" value="A"/>
+			<string key="org:resource" value="Marcus"/>
+			<string key="lifecycle:transition" value="complete"/>
+			<date key="time:timestamp" value="2022-05-16T10:15:00.000+02:00"/>
+			<int key="cost" value="10"/>
+		</event>
+		<event>
+			<string key="concept:name" value="B"/>
+			<string key="org:resource" value="Anya"/>
+			<string key="lifecycle:transition" value="schedule"/>
+			<date key="time:timestamp" value="2022-05-16T11:00:00.000+02:00"/>
+		</event>
+		<event>
+			<string key="concept:name" value="B"/>
+			<string key="org:resource" value="Anya"/>
+			<string key="lifecycle:transition" value="complete"/>
+			<date key="time:timestamp" value="2022-05-16T12:30:00Z"/>
+			<list key="details">
+				<values><string key="x" value="y"/></values>
+			</list>
+		</event>
+	</trace>
+	<trace>
+		<event>
+			<string key="concept:name" value="A"/>
+			<date key="time:timestamp" value="2022-05-17T09:00:00.000+02:00"/>
+		</event>
+	</trace>
+</log>
+`
+
+func TestXESToCSV(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "log.xml")
+	dst := path.Join(dir, "log.csv")
+
+	if err := os.WriteFile(src, []byte(testXES), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if reader, err := detectEventLogFormat(src, "", ""); err != nil || reader == nil || reader.Format() != "xes" {
+		t.Fatalf("expected the file to be detected as XES, got %v, %v", reader, err)
+	}
+
+	if err := xesToCSV(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"case:concept:name", "concept:name", "org:resource", "start_timestamp", "time:timestamp", "case:variant", "cost"},
+		{"case-1", "A", "Marcus", "2022-05-16T10:00:00.000+02:00", "2022-05-16T10:15:00.000+02:00", "v1", "10"},
+		{"case-1", "B", "Anya", "2022-05-16T12:30:00.000Z", "2022-05-16T12:30:00.000Z", "v1", ""},
+		{"1", "A", "", "2022-05-17T09:00:00.000+02:00", "2022-05-17T09:00:00.000+02:00", "", ""},
+	}
+
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("unexpected CSV\n got: %v\nwant: %v", records, want)
+	}
+}
+
+func TestXESToCSV_MissingTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "log.xes")
+
+	xes := `<log><trace><event><string key="concept:name" value="A"/></event></trace></log>`
+	if err := os.WriteFile(src, []byte(xes), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := xesToCSV(src, path.Join(dir, "log.csv")); err == nil {
+		t.Fatalf("expected an error for an event without a timestamp")
+	}
+}
@@ -0,0 +1,122 @@
+"""Runs the discoveries of the PIX framework for the analysis jobs of the backend besides the waiting time analysis.
+
+Every discovery reads the event log with the column mapping from a JSON file and the parameters given as JSON and writes
+its result as a JSON list to the output path. The file is replaced atomically, so that the backend never reads a partially written
+result.
+"""
+import argparse
+import json
+import os
+import tempfile
+
+import pandas as pd
+from pix_framework.discovery.batch_processing.batch_characteristics import (
+    discover_batch_processing_and_characteristics,
+)
+from pix_framework.discovery.prioritization.discovery import discover_priority_rules
+from pix_framework.discovery.resource_calendar_and_performance.calendar_discovery_parameters import (
+    CalendarDiscoveryParameters,
+    CalendarType,
+)
+from pix_framework.discovery.resource_calendar_and_performance.crisp.discovery import (
+    discover_crisp_resource_calendars_per_profile,
+)
+from pix_framework.discovery.resource_profiles import discover_differentiated_resource_profiles
+from pix_framework.enhancement.start_time_estimator.config import (
+    ConcurrencyOracleType,
+    Configuration,
+    ReEstimationMethod,
+    ResourceAvailabilityType,
+)
+from pix_framework.enhancement.start_time_estimator.estimator import StartTimeEstimator
+from pix_framework.io.event_log import EventLogIDs, read_csv_log
+
+
+def discover_calendars(event_log, log_ids, parameters):
+    profiles = discover_differentiated_resource_profiles(event_log, log_ids)
+    discovery_parameters = CalendarDiscoveryParameters(
+        discovery_type=CalendarType.DIFFERENTIATED_BY_RESOURCE,
+        granularity=parameters["granularity"],
+        confidence=parameters["confidence"],
+        support=parameters["support"],
+        participation=parameters["participation"],
+    )
+    calendars = discover_crisp_resource_calendars_per_profile(event_log, log_ids, discovery_parameters, profiles)
+    return [calendar.to_dict() for calendar in calendars]
+
+
+def estimate_start_times(event_log, log_ids):
+    configuration = Configuration(
+        log_ids=log_ids,
+        concurrency_oracle_type=ConcurrencyOracleType.HEURISTICS,
+        re_estimation_method=ReEstimationMethod.MODE,
+        resource_availability_type=ResourceAvailabilityType.SIMPLE,
+    )
+    return StartTimeEstimator(event_log, configuration).estimate()
+
+
+def discover_batching(event_log, log_ids, parameters):
+    return discover_batch_processing_and_characteristics(
+        event_log=estimate_start_times(event_log, log_ids),
+        log_ids=log_ids,
+        batch_min_size=parameters["min_batch_size"],
+        max_sequential_gap=pd.Timedelta(seconds=parameters.get("max_sequential_gap", 0)),
+        min_batch_instances=parameters["min_batch_instances"],
+        min_rule_support=parameters["min_rule_support"],
+    )
+
+
+def discover_prioritization(event_log, log_ids, parameters):
+    mapped = {log_ids.case, log_ids.activity, log_ids.start_time, log_ids.end_time, log_ids.resource}
+    attributes = parameters.get("attributes") or [column for column in event_log.columns if column not in mapped]
+    if not attributes:
+        return []
+    return discover_priority_rules(event_log=estimate_start_times(event_log, log_ids), attributes=attributes)
+
+
+DISCOVERIES = {
+    "calendars": discover_calendars,
+    "batching": discover_batching,
+    "prioritization": discover_prioritization,
+}
+
+
+def write_atomically(output_path, result):
+    output_dir = os.path.dirname(os.path.abspath(output_path))
+    fd, tmp_path = tempfile.mkstemp(dir=output_dir, suffix=".tmp")
+    try:
+        with os.fdopen(fd, "w") as f:
+            json.dump(result, f, default=str)
+        os.replace(tmp_path, output_path)
+    except BaseException:
+        os.remove(tmp_path)
+        raise
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("discovery", choices=sorted(DISCOVERIES))
+    parser.add_argument("--log_path", required=True)
+    parser.add_argument("--output_path", required=True)
+    parser.add_argument("--parameters_json", required=True)
+    parser.add_argument("--columns_path", required=True)
+    args = parser.parse_args()
+
+    parameters = json.loads(args.parameters_json)
+    with open(args.columns_path) as f:
+        columns = json.load(f)
+
+    log_ids = EventLogIDs(
+        case=columns["case"],
+        activity=columns["activity"],
+        start_time=columns["start_timestamp"],
+        end_time=columns["end_timestamp"],
+        resource=columns["resource"],
+    )
+    event_log = read_csv_log(log_path=args.log_path, log_ids=log_ids, sort=False)
+
+    write_atomically(args.output_path, DISCOVERIES[args.discovery](event_log, log_ids, parameters))
+
+
+if __name__ == "__main__":
+    main()
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -8,25 +9,29 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 )
 
 //go:generate swagger generate spec -o app/spec/swagger.json -m
 
 func main() {
-	// Command line flags
-	port := flag.Uint("port", 8080, "Port to listen on")
-	host := flag.String("host", "localhost", "Host to listen on")
-	sleep := flag.Int("sleep", 5, "Seconds for a worker to sleep if there is no pending jobs")
-	dev := flag.Bool("dev", false, "Run in development mode")
-	flag.Parse()
-
-	// Configure the application
-	config := app.DefaultConfiguration()
-	config.QueueSleepTime = time.Duration(*sleep) * time.Second
-	config.Host = *host
-	config.Port = *port
-	config.DevelopmentMode = *dev
+	// "config print" shows the effective configuration without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	// "worker" runs the analyses of a server with remote workers
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		os.Exit(workerCommand(os.Args[2:]))
+	}
+
+	// Configure the application from the defaults, the configuration file, the environment and the flags
+	config, err := app.LoadConfiguration(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		log.Fatal("error loading configuration; ", err)
+	}
 
 	// Initialize the application
 	a, err := app.NewApplication(config)
@@ -45,13 +50,11 @@ func main() {
 	log.Printf("Server started at %s", addr)
 	log.Printf("Development mode: %v", config.DevelopmentMode)
 	// Database connection check.
-	connStr := os.Getenv("DATABASE_URL")
-
-	if connStr == "" {
+	if config.DatabaseURL == "" {
 		log.Fatalf("DATABASE_URL is not set")
 	}
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", config.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Failed to open a DB connection: %v", err)
 	}
@@ -65,3 +68,51 @@ func main() {
 	fmt.Println("Successfully connected to the database!")
 	log.Fatal(http.ListenAndServe(addr, router))
 }
+
+// configCommand runs the "config" subcommand and returns the exit code.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: waiting-time-backend config print [-config file] [flags]")
+		return 2
+	}
+
+	config, err := app.LoadConfiguration(args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	b, err := config.YAML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, _ = os.Stdout.Write(b)
+
+	return 0
+}
+
+// workerCommand runs the "worker" subcommand until it's interrupted and returns the exit code.
+func workerCommand(args []string) int {
+	config, err := app.LoadConfiguration(args, os.LookupEnv)
+	if err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	worker, err := app.NewWorker(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	worker.Run(ctx)
+
+	return 0
+}
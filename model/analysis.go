@@ -0,0 +1,168 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// AnalysisKind is the kind of analysis a job runs on its event log.
+type AnalysisKind string
+
+var (
+	// AnalysisKindWaitingTime is the waiting time analysis, the kind of the jobs which don't have one.
+	AnalysisKindWaitingTime = AnalysisKind("waiting_time")
+	// AnalysisKindCalendarDiscovery discovers the resources' availability calendars, see CalendarDiscovery.
+	AnalysisKindCalendarDiscovery = AnalysisKind("calendar_discovery")
+	// AnalysisKindBatchingDiscovery discovers the batching strategies of the activities, see BatchingDiscovery.
+	AnalysisKindBatchingDiscovery = AnalysisKind("batching_discovery")
+	// AnalysisKindPrioritizationDiscovery discovers the rules the cases are prioritized by, see
+	// PrioritizationDiscovery.
+	AnalysisKindPrioritizationDiscovery = AnalysisKind("prioritization_discovery")
+)
+
+// DecodeAnalysisParams decodes the parameters of an analysis into v and validates them. Unknown keys are rejected, so
+// that a misspelled parameter isn't silently ignored. Empty or null parameters leave v untouched.
+func DecodeAnalysisParams(params json.RawMessage, v interface{ Validate() error }) error {
+	if len(bytes.TrimSpace(params)) > 0 && !bytes.Equal(bytes.TrimSpace(params), []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(params))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return err
+		}
+	}
+	return v.Validate()
+}
+
+// BatchingDiscovery are the parameters of a batching discovery job, omitted parameters get the defaults.
+//
+// swagger:model
+type BatchingDiscovery struct {
+	// MinBatchSize is the minimum number of activity instances executed together to be taken for a batch, 2 by
+	// default.
+	MinBatchSize int `json:"min_batch_size,omitempty"`
+	// MaxSequentialGap is the longest gap in seconds between the activity instances of a sequential batch, 0 by
+	// default, i.e., they have to follow each other immediately.
+	MaxSequentialGap float64 `json:"max_sequential_gap,omitempty"`
+	// MinBatchInstances is the minimum number of batches an activity has to be executed in to get a strategy, 1 by
+	// default.
+	MinBatchInstances int `json:"min_batch_instances,omitempty"`
+	// MinRuleSupport is the minimum share of the batches a firing rule has to cover, 0.1 by default.
+	MinRuleSupport float64 `json:"min_rule_support,omitempty"`
+}
+
+// Defaults of the batching discovery.
+const (
+	BatchingDiscoveryMinBatchSize      = 2
+	BatchingDiscoveryMinBatchInstances = 1
+	BatchingDiscoveryMinRuleSupport    = 0.1
+)
+
+// WithDefaults returns a copy of the parameters with the defaults in place of the omitted ones.
+func (b *BatchingDiscovery) WithDefaults() *BatchingDiscovery {
+	d := *b
+	if d.MinBatchSize == 0 {
+		d.MinBatchSize = BatchingDiscoveryMinBatchSize
+	}
+	if d.MinBatchInstances == 0 {
+		d.MinBatchInstances = BatchingDiscoveryMinBatchInstances
+	}
+	if d.MinRuleSupport == 0 {
+		d.MinRuleSupport = BatchingDiscoveryMinRuleSupport
+	}
+	return &d
+}
+
+// Validate checks the ranges of the parameters.
+func (b *BatchingDiscovery) Validate() error {
+	if b.MinBatchSize < 0 || b.MinBatchSize == 1 {
+		return fmt.Errorf("min_batch_size must be at least 2")
+	}
+	if b.MaxSequentialGap < 0 {
+		return fmt.Errorf("max_sequential_gap must not be negative")
+	}
+	if b.MinBatchInstances < 0 {
+		return fmt.Errorf("min_batch_instances must not be negative")
+	}
+	if b.MinRuleSupport < 0 || b.MinRuleSupport > 1 {
+		return fmt.Errorf("min_rule_support must be between 0 and 1")
+	}
+	return nil
+}
+
+// PrioritizationDiscovery are the parameters of a prioritization discovery job.
+//
+// swagger:model
+type PrioritizationDiscovery struct {
+	// Attributes are the columns of the event log the rules are discovered on. All columns besides the mapped ones are
+	// used by default.
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// WithDefaults returns a copy of the parameters, there are no defaults to fill in.
+func (p *PrioritizationDiscovery) WithDefaults() *PrioritizationDiscovery {
+	d := *p
+	return &d
+}
+
+// Validate checks that the attributes are named.
+func (p *PrioritizationDiscovery) Validate() error {
+	for _, attribute := range p.Attributes {
+		if attribute == "" {
+			return fmt.Errorf("attributes must not be empty")
+		}
+	}
+	return nil
+}
+
+// DiscoveredRule is a condition on an attribute of the cases, e.g., "size" ">=" "5". A batch fires or a case is
+// prioritized when all the conditions of one of the rule's lists hold.
+//
+// swagger:model
+type DiscoveredRule struct {
+	Attribute  string      `json:"attribute"`
+	Comparison string      `json:"comparison"`
+	Value      interface{} `json:"value"`
+}
+
+// BatchingStrategy is how an activity is executed in batches.
+//
+// swagger:model
+type BatchingStrategy struct {
+	Activity  string   `json:"activity"`
+	Resources []string `json:"resources"`
+	// Type is "Sequential", "Concurrent" or "Parallel".
+	Type string `json:"type"`
+	// BatchFrequency is the share of the activity's instances executed in batches.
+	BatchFrequency float64 `json:"batch_frequency"`
+	// SizeDistribution is the number of batches of every size.
+	SizeDistribution map[string]float64 `json:"size_distribution"`
+	// DurationDistribution is the scaling of the activity's duration for every batch size.
+	DurationDistribution map[string]float64  `json:"duration_distribution"`
+	FiringRules          [][]*DiscoveredRule `json:"firing_rules"`
+}
+
+// BatchingDiscoveryResult is the result of a batching discovery job with the parameters it's been discovered with.
+//
+// swagger:model
+type BatchingDiscoveryResult struct {
+	Parameters *BatchingDiscovery  `json:"parameters"`
+	Strategies []*BatchingStrategy `json:"strategies"`
+}
+
+// PriorityLevel are the rules which give the cases a priority, the lower the level, the higher the priority.
+//
+// swagger:model
+type PriorityLevel struct {
+	PriorityLevel int                 `json:"priority_level"`
+	Rules         [][]*DiscoveredRule `json:"rules"`
+}
+
+// PrioritizationDiscoveryResult is the result of a prioritization discovery job with the parameters it's been
+// discovered with.
+//
+// swagger:model
+type PrioritizationDiscoveryResult struct {
+	Parameters     *PrioritizationDiscovery `json:"parameters"`
+	PriorityLevels []*PriorityLevel         `json:"priority_levels"`
+}
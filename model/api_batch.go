@@ -0,0 +1,28 @@
+package model
+
+// ApiBatchRequest is a request's body for POST /batches with the jobs given like for POST /jobs.
+//
+// swagger:model
+type ApiBatchRequest struct {
+	Jobs []*ApiRequest `json:"jobs"`
+	// CallbackEndpoint is sent an ApiBatchCallbackRequest once all jobs have finished.
+	CallbackEndpoint string `json:"callback_endpoint,omitempty"`
+}
+
+// ApiBatchResponse is a response for a batch with its progress and jobs.
+//
+// swagger:model
+type ApiBatchResponse struct {
+	*Batch
+	Jobs []*Job `json:"jobs"`
+}
+
+// ApiBatchCallbackRequest is a body for POST request to the callback endpoint of a batch once all its jobs have
+// finished.
+//
+// swagger:model
+type ApiBatchCallbackRequest struct {
+	BatchID  string         `json:"batch_id"`
+	Status   string         `json:"status"`
+	Progress *BatchProgress `json:"progress"`
+}
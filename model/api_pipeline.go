@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ApiPipelineRequest is a request's body for POST /pipelines.
+//
+// swagger:model
+type ApiPipelineRequest struct {
+	Jobs []*ApiPipelineJob `json:"jobs"`
+}
+
+// ApiPipelineJob is a job of a pipeline. Its depends_on and input_from name other jobs of the pipeline or are the IDs of
+// jobs in the queue.
+//
+// swagger:model
+type ApiPipelineJob struct {
+	// Name identifies the job within the pipeline.
+	Name string `json:"name"`
+	ApiRequest
+}
+
+func (j *ApiPipelineJob) UnmarshalJSON(data []byte) error {
+	var named struct {
+		Name *string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	if named.Name == nil || *named.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	j.Name = *named.Name
+
+	return j.ApiRequest.UnmarshalJSON(data)
+}
+
+// ApiPipelineResponse is a response for POST /pipelines with the jobs by their names.
+//
+// swagger:model
+type ApiPipelineResponse struct {
+	Jobs map[string]*Job `json:"jobs"`
+}
@@ -4,17 +4,39 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"time"
 )
 
 // ApiRequest is a request's body for POST /jobs.
 //
 // swagger:model
 type ApiRequest struct {
-	EventLogURL          string            `json:"event_log,omitempty"`
-	EventLogURL_         *URL              `json:"-"`
-	CallbackEndpointURL  string            `json:"callback_endpoint,omitempty"`
-	CallbackEndpointURL_ *URL              `json:"-"`
-	ColumnMapping        map[string]string `json:"column_mapping,omitempty"`
+	EventLogURL          string         `json:"event_log,omitempty"`
+	EventLogURL_         *URL           `json:"-"`
+	CallbackEndpointURL  string         `json:"callback_endpoint,omitempty"`
+	CallbackEndpointURL_ *URL           `json:"-"`
+	ColumnMapping        *ColumnMapping `json:"column_mapping,omitempty"`
+	EventLogFormat       string         `json:"event_log_format,omitempty"`
+	OCELObjectType       string         `json:"ocel_object_type,omitempty"`
+	AutoMap              bool           `json:"auto_map,omitempty"`
+	Preprocessing        *Preprocessing `json:"preprocessing,omitempty"`
+	// Timezone is the IANA name of the timezone of timestamps without an offset, UTC by default.
+	Timezone string `json:"timezone,omitempty"`
+	// Force runs the analysis even if the same event log has been analysed before.
+	Force bool `json:"force,omitempty"`
+	// RetainUntil is when the job and its results are deleted, the global job retention applies if it's omitted.
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+	// Pinned keeps the job and its results regardless of the retention.
+	Pinned bool `json:"pinned,omitempty"`
+	// Kind is the kind of analysis, the waiting time analysis by default.
+	Kind AnalysisKind `json:"kind,omitempty"`
+	// Params are the parameters of the kind of analysis, e.g., CalendarDiscovery for "calendar_discovery".
+	Params json.RawMessage `json:"params,omitempty"`
+	// DependsOn are the IDs of the jobs which have to complete before the job runs.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// InputFrom is the ID of the job among DependsOn whose analysed event log the job analyses, the event log is
+	// omitted then.
+	InputFrom string `json:"input_from,omitempty"`
 }
 
 func (r *ApiRequest) UnmarshalJSON(data []byte) error {
@@ -25,16 +47,43 @@ func (r *ApiRequest) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	eventLog, ok := jsonData["event_log"].(string)
-	if !ok {
-		return fmt.Errorf("event_log is not a string")
+	// input_from is optional, it replaces the event log
+	if inputFrom, ok := jsonData["input_from"]; ok {
+		inputFromStr, ok := inputFrom.(string)
+		if !ok {
+			return fmt.Errorf("input_from is not a string")
+		}
+		r.InputFrom = inputFromStr
 	}
-	r.EventLogURL = eventLog
-	u, err := url.Parse(eventLog)
-	if err != nil {
-		return err
+
+	var u *url.URL
+	if eventLog, ok := jsonData["event_log"]; ok || r.InputFrom == "" {
+		eventLogStr, ok := eventLog.(string)
+		if !ok {
+			return fmt.Errorf("event_log is not a string")
+		}
+		r.EventLogURL = eventLogStr
+		u, err = url.Parse(eventLogStr)
+		if err != nil {
+			return err
+		}
+		r.EventLogURL_ = &URL{URL: u}
+	}
+
+	// depends_on is optional
+	if dependsOn, ok := jsonData["depends_on"]; ok && dependsOn != nil {
+		ids, ok := dependsOn.([]interface{})
+		if !ok {
+			return fmt.Errorf("depends_on is not a list")
+		}
+		for _, id := range ids {
+			idStr, ok := id.(string)
+			if !ok {
+				return fmt.Errorf("depends_on is not a list of strings")
+			}
+			r.DependsOn = append(r.DependsOn, idStr)
+		}
 	}
-	r.EventLogURL_ = &URL{URL: u}
 
 	// callback_endpoint is optional
 	callbackEndpoint, ok := jsonData["callback_endpoint"]
@@ -53,20 +102,125 @@ func (r *ApiRequest) UnmarshalJSON(data []byte) error {
 
 	// column_mapping is optional
 	mapping, ok := jsonData["column_mapping"]
-	if ok {
-		mappingMap, ok := mapping.(map[string]interface{})
-		if !ok {
+	if ok && mapping != nil {
+		if _, ok := mapping.(map[string]interface{}); !ok {
 			return fmt.Errorf("column_mapping is not a valid dictionary")
 		}
-		mappingMapStr := map[string]string{}
-		for k, v := range mappingMap {
-			vStr, ok := v.(string)
-			if !ok {
-				return fmt.Errorf("column_mapping value is not a string: %v", v)
-			}
-			mappingMapStr[k] = vStr
+		b, err := json.Marshal(mapping)
+		if err != nil {
+			return err
+		}
+		var columnMapping ColumnMapping
+		if err = json.Unmarshal(b, &columnMapping); err != nil {
+			return fmt.Errorf("column_mapping is invalid: %s", err.Error())
+		}
+		r.ColumnMapping = &columnMapping
+	}
+
+	// event_log_format is optional, the format is detected otherwise
+	if format, ok := jsonData["event_log_format"]; ok {
+		formatStr, ok := format.(string)
+		if !ok {
+			return fmt.Errorf("event_log_format is not a string")
+		}
+		r.EventLogFormat = formatStr
+	}
+
+	// ocel_object_type is optional
+	if objectType, ok := jsonData["ocel_object_type"]; ok {
+		objectTypeStr, ok := objectType.(string)
+		if !ok {
+			return fmt.Errorf("ocel_object_type is not a string")
+		}
+		r.OCELObjectType = objectTypeStr
+	}
+
+	// auto_map is optional, the column mapping is inferred from the event log if it's set and no mapping is given
+	if autoMap, ok := jsonData["auto_map"]; ok {
+		autoMapBool, ok := autoMap.(bool)
+		if !ok {
+			return fmt.Errorf("auto_map is not a boolean")
+		}
+		r.AutoMap = autoMapBool
+	}
+
+	// force is optional
+	if force, ok := jsonData["force"]; ok {
+		forceBool, ok := force.(bool)
+		if !ok {
+			return fmt.Errorf("force is not a boolean")
+		}
+		r.Force = forceBool
+	}
+
+	// pinned is optional
+	if pinned, ok := jsonData["pinned"]; ok {
+		pinnedBool, ok := pinned.(bool)
+		if !ok {
+			return fmt.Errorf("pinned is not a boolean")
+		}
+		r.Pinned = pinnedBool
+	}
+
+	// retain_until is optional
+	if retainUntil, ok := jsonData["retain_until"]; ok && retainUntil != nil {
+		retainUntilStr, ok := retainUntil.(string)
+		if !ok {
+			return fmt.Errorf("retain_until is not a string")
+		}
+		t, err := time.Parse(time.RFC3339, retainUntilStr)
+		if err != nil {
+			return fmt.Errorf("retain_until is invalid: %s", err.Error())
+		}
+		r.RetainUntil = &t
+	}
+
+	// timezone is optional
+	if timezone, ok := jsonData["timezone"]; ok {
+		timezoneStr, ok := timezone.(string)
+		if !ok {
+			return fmt.Errorf("timezone is not a string")
+		}
+		r.Timezone = timezoneStr
+	}
+
+	// preprocessing is optional
+	preprocessing, ok := jsonData["preprocessing"]
+	if ok && preprocessing != nil {
+		if _, ok := preprocessing.(map[string]interface{}); !ok {
+			return fmt.Errorf("preprocessing is not a valid dictionary")
+		}
+		b, err := json.Marshal(preprocessing)
+		if err != nil {
+			return err
+		}
+		var p Preprocessing
+		if err = json.Unmarshal(b, &p); err != nil {
+			return fmt.Errorf("preprocessing is invalid: %s", err.Error())
+		}
+		r.Preprocessing = &p
+	}
+
+	// kind is optional
+	if kind, ok := jsonData["kind"]; ok {
+		kindStr, ok := kind.(string)
+		if !ok {
+			return fmt.Errorf("kind is not a string")
+		}
+		r.Kind = AnalysisKind(kindStr)
+	}
+
+	// params are optional, they're validated with the kind of analysis
+	params, ok := jsonData["params"]
+	if ok && params != nil {
+		if _, ok := params.(map[string]interface{}); !ok {
+			return fmt.Errorf("params is not a valid dictionary")
+		}
+		b, err := json.Marshal(params)
+		if err != nil {
+			return err
 		}
-		r.ColumnMapping = mappingMapStr
+		r.Params = b
 	}
 
 	return nil
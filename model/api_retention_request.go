@@ -0,0 +1,24 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// ApiRetentionRequest is a request's body for PUT /jobs/{id}/retention. It replaces the job's retention settings.
+//
+// swagger:model
+type ApiRetentionRequest struct {
+	// RetainUntil is when the job and its results are deleted, the global job retention applies if it's omitted.
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+	// Pinned keeps the job and its results regardless of the retention.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// ValidateRetainUntil checks that the time a job is kept until isn't in the past. A nil time is valid.
+func ValidateRetainUntil(retainUntil *time.Time) error {
+	if retainUntil != nil && retainUntil.Before(time.Now()) {
+		return fmt.Errorf("retain_until must be in the future")
+	}
+	return nil
+}
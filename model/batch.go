@@ -0,0 +1,113 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// Batch groups the jobs submitted together with POST /batches.
+//
+// swagger:model
+type Batch struct {
+	ID string `json:"id"`
+	// Status is computed from the jobs' statuses: "pending" until a job starts, "running" until all jobs have finished,
+	// then "completed", or "failed" if a job has failed or has been deleted.
+	Status   JobStatus      `json:"status,omitempty"`
+	Progress *BatchProgress `json:"progress,omitempty"`
+	JobIDs   []string       `json:"job_ids"`
+	// CallbackEndpoint is sent an ApiBatchCallbackRequest once all jobs have finished.
+	CallbackEndpoint    string     `json:"callback_endpoint,omitempty"`
+	CallbackEndpointURL *URL       `json:"-"`
+	Owner               string     `json:"owner,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	CompletedAt         *time.Time `json:"finished_at,omitempty"`
+
+	lock sync.Mutex
+}
+
+// BatchProgress counts the jobs of a batch by their status.
+//
+// swagger:model
+type BatchProgress struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Completed int `json:"completed"`
+	Duplicate int `json:"duplicate"`
+	Failed    int `json:"failed"`
+	// Deleted counts the jobs which aren't in the queue anymore.
+	Deleted int `json:"deleted"`
+	// Finished is the share of the jobs which have finished, between 0 and 1.
+	Finished float64 `json:"finished"`
+}
+
+// Add counts the job, a nil job has been deleted.
+func (p *BatchProgress) Add(job *Job) {
+	p.Total++
+	switch {
+	case job == nil:
+		p.Deleted++
+	case job.Status == JobStatusPending:
+		p.Pending++
+	case job.Status == JobStatusRunning:
+		p.Running++
+	case job.Status == JobStatusCompleted:
+		p.Completed++
+	case job.Status == JobStatusDuplicate:
+		p.Duplicate++
+	default:
+		p.Failed++
+	}
+	p.Finished = float64(p.Total-p.Pending-p.Running) / float64(p.Total)
+}
+
+// Status returns the status of the batch with the counted jobs.
+func (p *BatchProgress) Status() JobStatus {
+	switch {
+	case p.Pending == p.Total:
+		return JobStatusPending
+	case p.Pending+p.Running > 0:
+		return JobStatusRunning
+	case p.Failed+p.Deleted > 0:
+		return JobStatusFailed
+	default:
+		return JobStatusCompleted
+	}
+}
+
+// Finish sets when the batch has finished and reports whether it hasn't been set before, so that the batch's callback
+// is sent once.
+func (b *Batch) Finish(t time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.CompletedAt != nil {
+		return false
+	}
+	b.CompletedAt = &t
+	return true
+}
+
+// Copy returns a copy of the batch with its own slices and pointed-to values, e.g., to add the progress to a response
+// without touching the batch in the queue.
+func (b *Batch) Copy() *Batch {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := &Batch{
+		ID:                  b.ID,
+		Status:              b.Status,
+		JobIDs:              copyStrings(b.JobIDs),
+		CallbackEndpoint:    b.CallbackEndpoint,
+		CallbackEndpointURL: b.CallbackEndpointURL.Copy(),
+		Owner:               b.Owner,
+		CreatedAt:           b.CreatedAt,
+		CompletedAt:         copyTime(b.CompletedAt),
+	}
+	if b.Progress != nil {
+		progress := *b.Progress
+		c.Progress = &progress
+	}
+
+	return c
+}
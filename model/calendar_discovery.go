@@ -0,0 +1,95 @@
+package model
+
+import (
+	"fmt"
+)
+
+// CalendarDiscovery are the parameters of a calendar discovery job, which discovers the resources' availability
+// calendars. A calendar is discovered for every resource from the time slots of the week it has worked in. The
+// thresholds are the ones of the discovery in the PIX framework, omitted parameters get the defaults.
+//
+// swagger:model
+type CalendarDiscovery struct {
+	// Granularity is the length of the calendar's time slots in minutes, it has to divide a day. It's 60 by default.
+	Granularity int `json:"granularity,omitempty"`
+	// Confidence is the minimum share of a time slot's weeks the resource has worked in, 0.6 by default.
+	Confidence float64 `json:"confidence,omitempty"`
+	// Support is the minimum share of the resource's work covered by the calendar, 0.2 by default.
+	Support float64 `json:"support,omitempty"`
+	// Participation is the minimum share of the log's activity instances a resource has to perform to get its own
+	// calendar, 0.4 by default.
+	Participation float64 `json:"participation,omitempty"`
+}
+
+// Defaults of the calendar discovery.
+const (
+	CalendarDiscoveryGranularity   = 60
+	CalendarDiscoveryConfidence    = 0.6
+	CalendarDiscoverySupport       = 0.2
+	CalendarDiscoveryParticipation = 0.4
+)
+
+// WithDefaults returns a copy of the parameters with the defaults in place of the omitted ones.
+func (c *CalendarDiscovery) WithDefaults() *CalendarDiscovery {
+	d := *c
+	if d.Granularity == 0 {
+		d.Granularity = CalendarDiscoveryGranularity
+	}
+	if d.Confidence == 0 {
+		d.Confidence = CalendarDiscoveryConfidence
+	}
+	if d.Support == 0 {
+		d.Support = CalendarDiscoverySupport
+	}
+	if d.Participation == 0 {
+		d.Participation = CalendarDiscoveryParticipation
+	}
+	return &d
+}
+
+// Validate checks the ranges of the parameters.
+func (c *CalendarDiscovery) Validate() error {
+	if c.Granularity < 0 || c.Granularity > 1440 || (c.Granularity > 0 && 1440%c.Granularity != 0) {
+		return fmt.Errorf("granularity must be a number of minutes which divides a day")
+	}
+	for _, threshold := range []struct {
+		name  string
+		value float64
+	}{
+		{"confidence", c.Confidence},
+		{"support", c.Support},
+		{"participation", c.Participation},
+	} {
+		if threshold.value < 0 || threshold.value > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", threshold.name)
+		}
+	}
+	return nil
+}
+
+// ResourceCalendar is the weekly availability of a resource discovered by a calendar discovery job.
+//
+// swagger:model
+type ResourceCalendar struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	TimePeriods []*CalendarTimePeriod `json:"time_periods"`
+}
+
+// CalendarTimePeriod is a weekly period of availability, e.g., from MONDAY to FRIDAY between 09:00:00 and 17:00:00.
+//
+// swagger:model
+type CalendarTimePeriod struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	BeginTime string `json:"beginTime"`
+	EndTime   string `json:"endTime"`
+}
+
+// CalendarDiscoveryResult is the result of a calendar discovery job with the parameters it's been discovered with.
+//
+// swagger:model
+type CalendarDiscoveryResult struct {
+	Parameters *CalendarDiscovery  `json:"parameters"`
+	Calendars  []*ResourceCalendar `json:"calendars"`
+}
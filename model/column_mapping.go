@@ -0,0 +1,304 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ColumnMapping maps the columns of a CSV event log to the roles the analysis needs. All the roles are required,
+// additional case and event attributes are optional. Timestamp formats are hints for the timestamp columns given in the
+// strftime syntax, e.g., "%d/%m/%Y %H:%M:%S", and keyed by the column name.
+//
+// The same settings can be given as a JSON object or as form values, see ParseColumnMapping.
+//
+// swagger:model
+type ColumnMapping struct {
+	Case             string            `json:"case,omitempty"`
+	Activity         string            `json:"activity,omitempty"`
+	Resource         string            `json:"resource,omitempty"`
+	StartTimestamp   string            `json:"start_timestamp,omitempty"`
+	EndTimestamp     string            `json:"end_timestamp,omitempty"`
+	CaseAttributes   []string          `json:"case_attributes,omitempty"`
+	EventAttributes  []string          `json:"event_attributes,omitempty"`
+	TimestampFormats map[string]string `json:"timestamp_formats,omitempty"`
+}
+
+// Column mapping keys shared by the JSON object and the form values.
+const (
+	ColumnMappingCase             = "case"
+	ColumnMappingActivity         = "activity"
+	ColumnMappingResource         = "resource"
+	ColumnMappingStartTimestamp   = "start_timestamp"
+	ColumnMappingEndTimestamp     = "end_timestamp"
+	ColumnMappingCaseAttributes   = "case_attributes"
+	ColumnMappingEventAttributes  = "event_attributes"
+	ColumnMappingTimestampFormats = "timestamp_formats"
+)
+
+// ParseColumnMapping reads a column mapping from query string or multipart form values. The roles are given with their
+// keys, e.g., "case=Case ID", attributes are repeated, e.g., "case_attributes=Region&case_attributes=Channel", and
+// timestamp formats are given per column, e.g., "timestamp_formats[Start Time]=%d/%m/%Y %H:%M". It returns nil if the
+// values have none of the keys.
+func ParseColumnMapping(values url.Values) *ColumnMapping {
+	m := &ColumnMapping{
+		Case:            values.Get(ColumnMappingCase),
+		Activity:        values.Get(ColumnMappingActivity),
+		Resource:        values.Get(ColumnMappingResource),
+		StartTimestamp:  values.Get(ColumnMappingStartTimestamp),
+		EndTimestamp:    values.Get(ColumnMappingEndTimestamp),
+		CaseAttributes:  values[ColumnMappingCaseAttributes],
+		EventAttributes: values[ColumnMappingEventAttributes],
+	}
+
+	prefix := ColumnMappingTimestampFormats + "["
+	for key := range values {
+		if strings.HasPrefix(key, prefix) && strings.HasSuffix(key, "]") {
+			if m.TimestampFormats == nil {
+				m.TimestampFormats = map[string]string{}
+			}
+			m.TimestampFormats[key[len(prefix):len(key)-1]] = values.Get(key)
+		}
+	}
+
+	if m.IsEmpty() {
+		return nil
+	}
+	return m
+}
+
+// UnmarshalJSON rejects unknown keys, so that a misspelled role isn't silently ignored.
+func (m *ColumnMapping) UnmarshalJSON(data []byte) error {
+	type columnMapping ColumnMapping
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var v columnMapping
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+
+	*m = ColumnMapping(v)
+	return nil
+}
+
+// IsEmpty reports whether no columns are mapped.
+func (m *ColumnMapping) IsEmpty() bool {
+	return m == nil || m.Case == "" && m.Activity == "" && m.Resource == "" && m.StartTimestamp == "" &&
+		m.EndTimestamp == "" && len(m.CaseAttributes) == 0 && len(m.EventAttributes) == 0 && len(m.TimestampFormats) == 0
+}
+
+// Copy returns a copy of the column mapping with its own attributes and timestamp formats.
+func (m *ColumnMapping) Copy() *ColumnMapping {
+	if m == nil {
+		return nil
+	}
+
+	c := *m
+	c.CaseAttributes = copyStrings(m.CaseAttributes)
+	c.EventAttributes = copyStrings(m.EventAttributes)
+	if m.TimestampFormats != nil {
+		c.TimestampFormats = make(map[string]string, len(m.TimestampFormats))
+		for column, format := range m.TimestampFormats {
+			c.TimestampFormats[column] = format
+		}
+	}
+	return &c
+}
+
+// Roles returns the columns of the roles keyed by the role names the analysis expects.
+func (m *ColumnMapping) Roles() map[string]string {
+	return map[string]string{
+		ColumnMappingCase:           m.Case,
+		ColumnMappingActivity:       m.Activity,
+		ColumnMappingResource:       m.Resource,
+		ColumnMappingStartTimestamp: m.StartTimestamp,
+		ColumnMappingEndTimestamp:   m.EndTimestamp,
+	}
+}
+
+// ColumnMappingFromRoles returns a column mapping with the columns of the roles keyed like in Roles, e.g., to read the
+// column mappings kept as plain maps by earlier versions. It returns nil if no columns are mapped.
+func ColumnMappingFromRoles(roles map[string]string) *ColumnMapping {
+	m := &ColumnMapping{
+		Case:           roles[ColumnMappingCase],
+		Activity:       roles[ColumnMappingActivity],
+		Resource:       roles[ColumnMappingResource],
+		StartTimestamp: roles[ColumnMappingStartTimestamp],
+		EndTimestamp:   roles[ColumnMappingEndTimestamp],
+	}
+
+	if m.IsEmpty() {
+		return nil
+	}
+	return m
+}
+
+// TimestampLayout returns the Go time layout for a timestamp column if a format has been given for it.
+func (m *ColumnMapping) TimestampLayout(column string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+
+	format, ok := m.TimestampFormats[column]
+	if !ok {
+		return "", false
+	}
+
+	layout, err := StrftimeLayout(format)
+	return layout, err == nil
+}
+
+// Validate checks that the roles are mapped, the attribute columns are distinct from them and the timestamp formats are
+// valid and belong to the timestamp columns. It returns the problems found.
+func (m *ColumnMapping) Validate() []*EventLogDiagnostic {
+	var diagnostics []*EventLogDiagnostic
+
+	roles := []struct{ key, column string }{
+		{ColumnMappingCase, m.Case},
+		{ColumnMappingActivity, m.Activity},
+		{ColumnMappingResource, m.Resource},
+		{ColumnMappingStartTimestamp, m.StartTimestamp},
+		{ColumnMappingEndTimestamp, m.EndTimestamp},
+	}
+
+	used := map[string]string{}
+	for _, role := range roles {
+		if strings.TrimSpace(role.column) == "" {
+			diagnostics = append(diagnostics, &EventLogDiagnostic{
+				Column:  role.key,
+				Message: fmt.Sprintf("column mapping for %q is required", role.key),
+			})
+			continue
+		}
+		used[role.column] = role.key
+	}
+
+	attributes := []struct {
+		key     string
+		columns []string
+	}{
+		{ColumnMappingCaseAttributes, m.CaseAttributes},
+		{ColumnMappingEventAttributes, m.EventAttributes},
+	}
+
+	for _, attribute := range attributes {
+		for _, column := range attribute.columns {
+			if strings.TrimSpace(column) == "" {
+				diagnostics = append(diagnostics, &EventLogDiagnostic{
+					Column:  attribute.key,
+					Message: fmt.Sprintf("%s contains an empty column name", attribute.key),
+				})
+				continue
+			}
+			if key, ok := used[column]; ok {
+				diagnostics = append(diagnostics, &EventLogDiagnostic{
+					Column:  column,
+					Message: fmt.Sprintf("column %q in %s is already mapped to %s", column, attribute.key, key),
+				})
+				continue
+			}
+			used[column] = attribute.key
+		}
+	}
+
+	for _, column := range sortedStringKeys(m.TimestampFormats) {
+		if column != m.StartTimestamp && column != m.EndTimestamp {
+			diagnostics = append(diagnostics, &EventLogDiagnostic{
+				Column:  column,
+				Message: fmt.Sprintf("timestamp format is given for %q which isn't a timestamp column", column),
+			})
+			continue
+		}
+		if _, err := StrftimeLayout(m.TimestampFormats[column]); err != nil {
+			diagnostics = append(diagnostics, &EventLogDiagnostic{
+				Column:  column,
+				Value:   m.TimestampFormats[column],
+				Message: fmt.Sprintf("invalid timestamp format: %s", err.Error()),
+			})
+		}
+	}
+
+	return diagnostics
+}
+
+// strftimeDirectives maps strftime directives to Go time layout elements. Months, days and hours are parsed with or
+// without a leading zero like strptime does.
+var strftimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "1",
+	'd': "2",
+	'e': "_2",
+	'H': "15",
+	'I': "3",
+	'M': "04",
+	'S': "05",
+	'f': "999999",
+	'p': "PM",
+	'b': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// StrftimeLayout converts a strftime format into a Go time layout. Literal text must not contain digits or letters
+// other than "T", because they could be taken for layout elements.
+func StrftimeLayout(format string) (string, error) {
+	if format == "" {
+		return "", fmt.Errorf("format is empty")
+	}
+
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+
+		if c != '%' {
+			if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' && c != 'T' {
+				return "", fmt.Errorf("literal %q isn't supported in %q", c, format)
+			}
+			layout.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(format) {
+			return "", fmt.Errorf("format %q ends with %%", format)
+		}
+
+		// "%:z" is the offset with a colon
+		if format[i] == ':' && i+1 < len(format) && format[i+1] == 'z' {
+			i++
+			layout.WriteString("-07:00")
+			continue
+		}
+
+		element, ok := strftimeDirectives[format[i]]
+		if !ok {
+			return "", fmt.Errorf("directive %%%c isn't supported", format[i])
+		}
+		// Go parses fractional seconds only after a period
+		if format[i] == 'f' && !strings.HasSuffix(layout.String(), ".") {
+			return "", fmt.Errorf("%%f must follow a period in %q", format)
+		}
+		layout.WriteString(element)
+	}
+
+	return layout.String(), nil
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
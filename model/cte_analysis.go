@@ -0,0 +1,23 @@
+package model
+
+// CteAnalysis is the cycle time efficiency (CTE) of a job's process, i.e., the share of the processing time in the
+// cycle time, and the CTE the process would reach if waiting times were eliminated. The CTEs are between 0 and 1, they're
+// 0 if the process has neither processing nor waiting time. Times are in seconds.
+//
+// swagger:model
+type CteAnalysis struct {
+	TotalPt               float64 `json:"total_pt"`
+	TotalWt               float64 `json:"total_wt"`
+	TotalBatchingWt       float64 `json:"total_batching_wt"`
+	TotalPrioritizationWt float64 `json:"total_prioritization_wt"`
+	TotalContentionWt     float64 `json:"total_contention_wt"`
+	TotalUnavailabilityWt float64 `json:"total_unavailability_wt"`
+	TotalExtraneousWt     float64 `json:"total_extraneous_wt"`
+	ProcessCTE            float64 `json:"process_cte"`
+	// PotentialCTE is the process CTE if the waiting times of each cause were eliminated.
+	PotentialCTE *JobCteImpact `json:"potential_cte"`
+	// Transitions are the activity transitions ordered by the process CTE if their waiting times were eliminated, the
+	// largest improvement first. Their CTE impacts are the process CTEs if the transition's waiting times of each cause
+	// were eliminated.
+	Transitions []*JobResultReportItem `json:"transitions,omitempty"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// EventLogDiagnostic describes a problem found in an event log before the analysis.
+//
+// swagger:model
+type EventLogDiagnostic struct {
+	// Line number in the CSV file, the header is line 1. Zero for problems of the whole log.
+	Row     int    `json:"row,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Case    string `json:"case,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// ApiResponseValidationError is a response to a job with an event log that can't be analysed.
+//
+// swagger:model
+type ApiResponseValidationError struct {
+	Error       string                `json:"error,omitempty"`
+	Diagnostics []*EventLogDiagnostic `json:"diagnostics"`
+	// Truncated is set when there are more problems than reported.
+	Truncated bool `json:"truncated,omitempty"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// EventLogDownload describes the response received when the event log was downloaded from the job's URL.
+//
+// swagger:model
+type EventLogDownload struct {
+	URL             string    `json:"url,omitempty"`
+	StatusCode      int       `json:"status_code,omitempty"`
+	ContentType     string    `json:"content_type,omitempty"`
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	ContentLength   int64     `json:"content_length,omitempty"`
+	ETag            string    `json:"etag,omitempty"`
+	LastModified    string    `json:"last_modified,omitempty"`
+	AcceptRanges    bool      `json:"accept_ranges,omitempty"`
+	Size            int64     `json:"size,omitempty"`
+	Attempts        int       `json:"attempts,omitempty"`
+	DownloadedAt    time.Time `json:"downloaded_at,omitempty"`
+}
+
+// Resumable reports whether an interrupted download can be continued with a range request. It requires the server to
+// support ranges and a validator to make sure the remote file hasn't changed in between.
+func (d *EventLogDownload) Resumable() bool {
+	if d == nil || !d.AcceptRanges {
+		return false
+	}
+
+	strongETag := d.ETag != "" && !strings.HasPrefix(d.ETag, "W/")
+	return strongETag || d.LastModified != ""
+}
+
+// Copy returns a copy of the download's metadata.
+func (d *EventLogDownload) Copy() *EventLogDownload {
+	if d == nil {
+		return nil
+	}
+
+	c := *d
+	return &c
+}
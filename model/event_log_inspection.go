@@ -0,0 +1,41 @@
+package model
+
+// EventLogInspection is a profile of a sample of an event log with a proposed column mapping.
+//
+// swagger:model
+type EventLogInspection struct {
+	EventLogFormat string           `json:"event_log_format"`
+	SampledRows    int              `json:"sampled_rows"`
+	Columns        []*ColumnProfile `json:"columns"`
+	// ColumnMapping is the proposed mapping, the roles without a suitable column are empty.
+	ColumnMapping *ColumnMapping `json:"column_mapping"`
+	// Confidence is a score between 0 and 1 for every role of the proposed mapping.
+	Confidence map[string]float64 `json:"confidence"`
+	// Candidates are the columns considered for every role, the best first.
+	Candidates map[string][]*ColumnCandidate `json:"candidates"`
+	// Complete is set if every role has been mapped.
+	Complete bool `json:"complete"`
+}
+
+// ColumnProfile describes the values of a column in the sample.
+//
+// swagger:model
+type ColumnProfile struct {
+	Name     string `json:"name"`
+	Empty    int    `json:"empty"`
+	Distinct int    `json:"distinct"`
+	// TimestampRatio is the share of non-empty values which are timestamps.
+	TimestampRatio float64 `json:"timestamp_ratio"`
+	// TimestampFormat is the strftime format of the values if they aren't in ISO 8601.
+	TimestampFormat string   `json:"timestamp_format,omitempty"`
+	NumericRatio    float64  `json:"numeric_ratio"`
+	Examples        []string `json:"examples,omitempty"`
+}
+
+// ColumnCandidate is a column considered for a role of the column mapping.
+//
+// swagger:model
+type ColumnCandidate struct {
+	Column string  `json:"column"`
+	Score  float64 `json:"score"`
+}
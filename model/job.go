@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"path"
@@ -22,26 +23,58 @@ var (
 //
 // swagger:model
 type Job struct {
-	ID                      string            `json:"id,omitempty"`
-	Status                  JobStatus         `json:"status,omitempty"`
-	Error                   string            `json:"error,omitempty"`
-	Result                  *JobResult        `json:"result,omitempty"`
-	ReportCSV               *URL              `json:"report_csv,omitempty"`
-	CallbackEndpoint        string            `json:"callback_endpoint,omitempty"`
-	CallbackEndpointURL     *URL              `json:"-"`
-	EventLog                string            `json:"event_log,omitempty"`
-	EventLogURL             *URL              `json:"-"`
-	EventLogMD5             string            `json:"event_log_md5,omitempty"`
-	EventLogFromRequestBody bool              `json:"-"`
-	CreatedAt               time.Time         `json:"created_at,omitempty"`
-	CompletedAt             *time.Time        `json:"finished_at,omitempty"`
-	ColumnMapping           map[string]string `json:"column_mapping,omitempty"`
+	ID                      string                `json:"id,omitempty"`
+	Status                  JobStatus             `json:"status,omitempty"`
+	Error                   string                `json:"error,omitempty"`
+	Result                  *JobResult            `json:"result,omitempty"`
+	ReportCSV               *URL                  `json:"report_csv,omitempty"`
+	CallbackEndpoint        string                `json:"callback_endpoint,omitempty"`
+	CallbackEndpointURL     *URL                  `json:"-"`
+	EventLog                string                `json:"event_log,omitempty"`
+	EventLogURL             *URL                  `json:"-"`
+	EventLogMD5             string                `json:"event_log_md5,omitempty"`
+	CacheKey                string                `json:"cache_key,omitempty"`
+	DuplicateOf             string                `json:"duplicate_of,omitempty"`
+	Force                   bool                  `json:"force,omitempty"`
+	EventLogName            string                `json:"event_log_name,omitempty"`
+	EventLogFormat          string                `json:"event_log_format,omitempty"`
+	OCELObjectType          string                `json:"ocel_object_type,omitempty"`
+	AutoMap                 bool                  `json:"auto_map,omitempty"`
+	Timezone                string                `json:"timezone,omitempty"`
+	EventLogFromRequestBody bool                  `json:"-"`
+	Download                *EventLogDownload     `json:"download,omitempty"`
+	Diagnostics             []*EventLogDiagnostic `json:"diagnostics,omitempty"`
+	CreatedAt               time.Time             `json:"created_at,omitempty"`
+	CompletedAt             *time.Time            `json:"finished_at,omitempty"`
+	ColumnMapping           *ColumnMapping        `json:"column_mapping,omitempty"`
+	Preprocessing           *Preprocessing        `json:"preprocessing,omitempty"`
+	PreprocessingSummary    *PreprocessingSummary `json:"preprocessing_summary,omitempty"`
+	RetainUntil             *time.Time            `json:"retain_until,omitempty"`
+	Pinned                  bool                  `json:"pinned,omitempty"`
+	EventLogDeletedAt       *time.Time            `json:"event_log_deleted_at,omitempty"`
+	Owner                   string                `json:"owner,omitempty"`
+	// ProcessingTime is the total duration of the activities in the analysed event log in seconds. The report has only
+	// the waiting times, the processing time is kept for the CTE once the event log is deleted.
+	ProcessingTime float64 `json:"processing_time,omitempty"`
+	// Kind is the kind of analysis the job runs, jobs without one run the waiting time analysis.
+	Kind AnalysisKind `json:"kind,omitempty"`
+	// Params are the parameters of the kind of analysis with the defaults filled in.
+	Params json.RawMessage `json:"params,omitempty"`
+	// ResultFile links to the output of the analyses other than the waiting time one, which links to ReportCSV.
+	ResultFile *URL `json:"result_file,omitempty"`
+	// DependsOn are the IDs of the jobs which have to complete before the job runs, the job fails if one of them fails.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// InputFrom is the ID of the job among DependsOn whose analysed event log, i.e., normalized and preprocessed, the
+	// job analyses instead of an event log of its own.
+	InputFrom string `json:"input_from,omitempty"`
+	// BatchID is the ID of the batch the job has been submitted with.
+	BatchID string `json:"batch_id,omitempty"`
 
 	lock sync.Mutex
 	Dir  string `json:"-"`
 }
 
-func NewJob(eventLog *URL, callback *URL, columnMapping map[string]string, basedir string) (*Job, error) {
+func NewJob(eventLog *URL, callback *URL, columnMapping *ColumnMapping, basedir string) (*Job, error) {
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
@@ -69,10 +102,25 @@ func (j *Job) Validate() error {
 		return fmt.Errorf("job status is required")
 	}
 
-	if j.EventLogURL.String() == "" || j.EventLog == "" {
+	if j.InputFrom != "" {
+		if j.EventLog != "" {
+			return fmt.Errorf("job event log and input_from can't be given together")
+		}
+		if !j.HasDependency(j.InputFrom) {
+			return fmt.Errorf("job input_from must be one of depends_on")
+		}
+	} else if j.EventLogURL.String() == "" || j.EventLog == "" {
 		return fmt.Errorf("job event log is required")
 	}
 
+	seen := map[string]bool{}
+	for _, id := range j.DependsOn {
+		if id == "" || id == j.ID || seen[id] {
+			return fmt.Errorf("job depends_on must list other jobs once each")
+		}
+		seen[id] = true
+	}
+
 	if j.CreatedAt.IsZero() {
 		return fmt.Errorf("job .CreatedAt timestamp is required")
 	}
@@ -80,6 +128,57 @@ func (j *Job) Validate() error {
 	return nil
 }
 
+// HasDependency reports whether the job depends on the job with the given ID.
+func (j *Job) HasDependency(id string) bool {
+	for _, dependency := range j.DependsOn {
+		if dependency == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalysisKind returns the kind of analysis the job runs, the waiting time analysis for the jobs without one.
+func (j *Job) AnalysisKind() AnalysisKind {
+	if j.Kind == "" {
+		return AnalysisKindWaitingTime
+	}
+	return j.Kind
+}
+
+// EventLogFileName returns the name of the event log file in the job's directory. Jobs submitted before the name was
+// stored use the last element of the event log URL.
+func (j *Job) EventLogFileName() string {
+	if j.EventLogName != "" {
+		return j.EventLogName
+	}
+
+	if j.EventLogURL == nil || j.EventLogURL.URL == nil {
+		return ""
+	}
+
+	return path.Base(j.EventLogURL.URL.Path)
+}
+
+// EventLogDownloaded reports whether the event log has been downloaded from the job's URL into the job's directory.
+func (j *Job) EventLogDownloaded() bool {
+	return j.Download != nil && !j.Download.DownloadedAt.IsZero()
+}
+
+func (j *Job) SetEventLogName(name string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.EventLogName = name
+}
+
+func (j *Job) SetEventLogFormat(format string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.EventLogFormat = format
+}
+
 func (j *Job) SetStatus(status JobStatus) {
 	j.lock.Lock()
 	defer j.lock.Unlock()
@@ -108,9 +207,171 @@ func (j *Job) SetReportCSV(url *URL) {
 	j.ReportCSV = url
 }
 
+func (j *Job) SetResultFile(url *URL) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.ResultFile = url
+}
+
 func (j *Job) SetCompletedAt(t time.Time) {
 	j.lock.Lock()
 	defer j.lock.Unlock()
 
 	j.CompletedAt = &t
 }
+
+func (j *Job) SetDownload(download *EventLogDownload) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.Download = download
+}
+
+func (j *Job) SetDiagnostics(diagnostics []*EventLogDiagnostic) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.Diagnostics = diagnostics
+}
+
+func (j *Job) SetColumnMapping(columnMapping *ColumnMapping) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.ColumnMapping = columnMapping
+}
+
+func (j *Job) SetPreprocessingSummary(summary *PreprocessingSummary) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.PreprocessingSummary = summary
+}
+
+func (j *Job) SetCacheKey(cacheKey string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.CacheKey = cacheKey
+}
+
+// SetDuplicateOf marks the job as a duplicate of the original job and links to the original's result, report and
+// result file.
+func (j *Job) SetDuplicateOf(original *Job) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.DuplicateOf = original.ID
+	j.Status = JobStatusDuplicate
+	j.Result = original.Result
+	j.ReportCSV = original.ReportCSV
+	j.ResultFile = original.ResultFile
+	j.ProcessingTime = original.ProcessingTime
+}
+
+// SetRetention sets when the job expires, nil for the global default, and whether it's kept regardless.
+func (j *Job) SetRetention(retainUntil *time.Time, pinned bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.RetainUntil = retainUntil
+	j.Pinned = pinned
+}
+
+func (j *Job) SetEventLogDeletedAt(t time.Time) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.EventLogDeletedAt = &t
+}
+
+func (j *Job) SetProcessingTime(seconds float64) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.ProcessingTime = seconds
+}
+
+// Copy returns a copy of the job with its own slices, maps and pointed-to values, e.g., to change the links in
+// a response without touching the job in the queue.
+func (j *Job) Copy() *Job {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	c := &Job{
+		ID:                      j.ID,
+		Status:                  j.Status,
+		Error:                   j.Error,
+		Result:                  j.Result.Copy(),
+		ReportCSV:               j.ReportCSV.Copy(),
+		CallbackEndpoint:        j.CallbackEndpoint,
+		CallbackEndpointURL:     j.CallbackEndpointURL.Copy(),
+		EventLog:                j.EventLog,
+		EventLogURL:             j.EventLogURL.Copy(),
+		EventLogMD5:             j.EventLogMD5,
+		CacheKey:                j.CacheKey,
+		DuplicateOf:             j.DuplicateOf,
+		Force:                   j.Force,
+		EventLogName:            j.EventLogName,
+		EventLogFormat:          j.EventLogFormat,
+		OCELObjectType:          j.OCELObjectType,
+		AutoMap:                 j.AutoMap,
+		Timezone:                j.Timezone,
+		EventLogFromRequestBody: j.EventLogFromRequestBody,
+		Download:                j.Download.Copy(),
+		CreatedAt:               j.CreatedAt,
+		CompletedAt:             copyTime(j.CompletedAt),
+		ColumnMapping:           j.ColumnMapping.Copy(),
+		Preprocessing:           j.Preprocessing.Copy(),
+		RetainUntil:             copyTime(j.RetainUntil),
+		Pinned:                  j.Pinned,
+		EventLogDeletedAt:       copyTime(j.EventLogDeletedAt),
+		Owner:                   j.Owner,
+		ProcessingTime:          j.ProcessingTime,
+		Kind:                    j.Kind,
+		Params:                  copyBytes(j.Params),
+		ResultFile:              j.ResultFile.Copy(),
+		DependsOn:               copyStrings(j.DependsOn),
+		InputFrom:               j.InputFrom,
+		BatchID:                 j.BatchID,
+		Dir:                     j.Dir,
+	}
+	if j.Diagnostics != nil {
+		c.Diagnostics = make([]*EventLogDiagnostic, len(j.Diagnostics))
+		for i, diagnostic := range j.Diagnostics {
+			if diagnostic != nil {
+				diagnosticCopy := *diagnostic
+				c.Diagnostics[i] = &diagnosticCopy
+			}
+		}
+	}
+	if j.PreprocessingSummary != nil {
+		summary := *j.PreprocessingSummary
+		c.PreprocessingSummary = &summary
+	}
+
+	return c
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -10,3 +10,13 @@ type JobCteImpact struct {
 	UnavailabilityImpact float64 `json:"unavailability_impact,omitempty"`
 	ExtraneousImpact     float64 `json:"extraneous_impact,omitempty"`
 }
+
+// Copy returns a copy of the CTE impact.
+func (i *JobCteImpact) Copy() *JobCteImpact {
+	if i == nil {
+		return nil
+	}
+
+	c := *i
+	return &c
+}
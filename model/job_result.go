@@ -22,3 +22,29 @@ type JobResult struct {
 	CTEImpact              *JobCteImpact          `json:"cte_impact"`
 	PerCaseWT              []*JobPerCaseWT        `json:"per_case_wt"`
 }
+
+// Copy returns a copy of the result with its own report and measures per case.
+func (r *JobResult) Copy() *JobResult {
+	if r == nil {
+		return nil
+	}
+
+	c := *r
+	if r.Report != nil {
+		c.Report = make([]*JobResultReportItem, len(r.Report))
+		for i, item := range r.Report {
+			c.Report[i] = item.Copy()
+		}
+	}
+	c.CTEImpact = r.CTEImpact.Copy()
+	if r.PerCaseWT != nil {
+		c.PerCaseWT = make([]*JobPerCaseWT, len(r.PerCaseWT))
+		for i, item := range r.PerCaseWT {
+			if item != nil {
+				itemCopy := *item
+				c.PerCaseWT[i] = &itemCopy
+			}
+		}
+	}
+	return &c
+}
@@ -18,3 +18,21 @@ type JobResultReportItem struct {
 	CTEImpactTotal   float64                 `json:"cte_impact_total"`
 	CTEImpact        *JobCteImpact           `json:"cte_impact"`
 }
+
+// Copy returns a copy of the report item with its own waiting times by resource.
+func (i *JobResultReportItem) Copy() *JobResultReportItem {
+	if i == nil {
+		return nil
+	}
+
+	c := *i
+	if i.WtByResource != nil {
+		c.WtByResource = make([]JobResultResourceItem, len(i.WtByResource))
+		for j, resourceItem := range i.WtByResource {
+			resourceItem.CTEImpact = resourceItem.CTEImpact.Copy()
+			c.WtByResource[j] = resourceItem
+		}
+	}
+	c.CTEImpact = i.CTEImpact.Copy()
+	return &c
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Lease is the analysis of a job handed out to a remote worker. The worker renews the lease with heartbeats while it
+// runs the analysis, uploads the files the analysis has written and completes the lease. A lease which isn't renewed
+// before it expires is handed out again.
+//
+// swagger:model
+type Lease struct {
+	ID       string `json:"id"`
+	JobID    string `json:"job_id"`
+	WorkerID string `json:"worker_id"`
+	// Attempt counts the leases of the job's analysis, it's more than 1 if earlier ones have expired.
+	Attempt       int             `json:"attempt"`
+	Kind          AnalysisKind    `json:"kind"`
+	Params        json.RawMessage `json:"params,omitempty"`
+	ColumnMapping *ColumnMapping  `json:"column_mapping,omitempty"`
+	// EventLogName is the name of the event log file, it's downloaded from the lease's event log endpoint.
+	EventLogName string    `json:"event_log_name"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	// HeartbeatInterval is how often in seconds the worker has to renew the lease.
+	HeartbeatInterval float64 `json:"heartbeat_interval"`
+}
+
+// LeaseRequest is the request of a worker for a lease.
+//
+// swagger:model
+type LeaseRequest struct {
+	// WorkerID names the worker in the logs and in the errors of the jobs.
+	WorkerID string `json:"worker_id"`
+}
+
+// LeaseCompletion completes a lease, Error is set if the analysis has failed.
+//
+// swagger:model
+type LeaseCompletion struct {
+	Error string `json:"error,omitempty"`
+}
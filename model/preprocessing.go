@@ -0,0 +1,144 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Preprocessing filters an event log before the analysis. The event filters, i.e., the activity and resource lists and
+// the time range in the "events" mode, are applied first, then the cases are filtered by the time range in the other
+// modes, by their length and finally sampled.
+//
+// swagger:model
+type Preprocessing struct {
+	TimeRange *TimeRange `json:"time_range,omitempty"`
+	// IncludeActivities keeps only the events of these activities.
+	IncludeActivities []string `json:"include_activities,omitempty"`
+	ExcludeActivities []string `json:"exclude_activities,omitempty"`
+	// IncludeResources keeps only the events performed by these resources.
+	IncludeResources []string `json:"include_resources,omitempty"`
+	ExcludeResources []string `json:"exclude_resources,omitempty"`
+	// MinCaseLength drops the cases with fewer events after the event filters.
+	MinCaseLength int `json:"min_case_length,omitempty"`
+	// CaseSampleRatio is the share of cases kept, between 0 and 1. The same seed keeps the same cases.
+	CaseSampleRatio float64 `json:"case_sample_ratio,omitempty"`
+	Seed            int64   `json:"seed,omitempty"`
+}
+
+// TimeRange limits an event log to a period. Both bounds are optional and inclusive.
+//
+// swagger:model
+type TimeRange struct {
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	// Mode is one of "events" (default), which keeps the events within the range, "contained", which keeps the cases
+	// within the range, and "intersecting", which keeps the cases with an event within the range.
+	Mode string `json:"mode,omitempty"`
+}
+
+const (
+	TimeRangeModeEvents       = "events"
+	TimeRangeModeContained    = "contained"
+	TimeRangeModeIntersecting = "intersecting"
+)
+
+// PreprocessingSummary records the effect of the preprocessing on the event log.
+//
+// swagger:model
+type PreprocessingSummary struct {
+	CasesBefore  int `json:"cases_before"`
+	CasesAfter   int `json:"cases_after"`
+	EventsBefore int `json:"events_before"`
+	EventsAfter  int `json:"events_after"`
+	// UnfilteredEventLogName is the name of the original event log kept in the job's directory.
+	UnfilteredEventLogName string `json:"unfiltered_event_log_name"`
+}
+
+// UnmarshalJSON rejects unknown keys, so that a misspelled filter isn't silently ignored.
+func (p *Preprocessing) UnmarshalJSON(data []byte) error {
+	type preprocessing Preprocessing
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var v preprocessing
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+
+	*p = Preprocessing(v)
+	return nil
+}
+
+// Validate checks the ranges of the settings and that no value is both included and excluded.
+func (p *Preprocessing) Validate() error {
+	if p.TimeRange != nil {
+		switch p.TimeRange.Mode {
+		case "", TimeRangeModeEvents, TimeRangeModeContained, TimeRangeModeIntersecting:
+		default:
+			return fmt.Errorf("time_range mode %q is unknown, expected %s, %s or %s",
+				p.TimeRange.Mode, TimeRangeModeEvents, TimeRangeModeContained, TimeRangeModeIntersecting)
+		}
+
+		if p.TimeRange.From != nil && p.TimeRange.To != nil && p.TimeRange.From.After(*p.TimeRange.To) {
+			return fmt.Errorf("time_range starts after it ends")
+		}
+	}
+
+	if p.MinCaseLength < 0 {
+		return fmt.Errorf("min_case_length must not be negative")
+	}
+
+	if p.CaseSampleRatio < 0 || p.CaseSampleRatio > 1 {
+		return fmt.Errorf("case_sample_ratio must be between 0 and 1")
+	}
+
+	if value, ok := intersection(p.IncludeActivities, p.ExcludeActivities); ok {
+		return fmt.Errorf("activity %q is both included and excluded", value)
+	}
+	if value, ok := intersection(p.IncludeResources, p.ExcludeResources); ok {
+		return fmt.Errorf("resource %q is both included and excluded", value)
+	}
+
+	return nil
+}
+
+// Copy returns a copy of the preprocessing with its own filters.
+func (p *Preprocessing) Copy() *Preprocessing {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	if p.TimeRange != nil {
+		c.TimeRange = &TimeRange{From: copyTime(p.TimeRange.From), To: copyTime(p.TimeRange.To), Mode: p.TimeRange.Mode}
+	}
+	c.IncludeActivities = copyStrings(p.IncludeActivities)
+	c.ExcludeActivities = copyStrings(p.ExcludeActivities)
+	c.IncludeResources = copyStrings(p.IncludeResources)
+	c.ExcludeResources = copyStrings(p.ExcludeResources)
+	return &c
+}
+
+// TimeRangeMode returns the mode of the time range with the default applied.
+func (p *Preprocessing) TimeRangeMode() string {
+	if p.TimeRange == nil || p.TimeRange.Mode == "" {
+		return TimeRangeModeEvents
+	}
+	return p.TimeRange.Mode
+}
+
+func intersection(a, b []string) (string, bool) {
+	values := map[string]bool{}
+	for _, v := range a {
+		values[v] = true
+	}
+	for _, v := range b {
+		if values[v] {
+			return v, true
+		}
+	}
+	return "", false
+}
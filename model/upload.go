@@ -0,0 +1,96 @@
+package model
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Upload is a resumable upload session of an event log. The log is sent in chunks and the session is finalized into
+// a job when all the bytes have been received. The session keeps the settings of the job like POST /jobs takes them.
+//
+// swagger:model
+type Upload struct {
+	ID               string          `json:"id"`
+	Offset           int64           `json:"offset"`
+	Length           int64           `json:"length"`
+	FileName         string          `json:"filename,omitempty"`
+	CallbackEndpoint string          `json:"callback_endpoint,omitempty"`
+	ColumnMapping    *ColumnMapping  `json:"column_mapping,omitempty"`
+	EventLogFormat   string          `json:"event_log_format,omitempty"`
+	OCELObjectType   string          `json:"ocel_object_type,omitempty"`
+	AutoMap          bool            `json:"auto_map,omitempty"`
+	Preprocessing    *Preprocessing  `json:"preprocessing,omitempty"`
+	Timezone         string          `json:"timezone,omitempty"`
+	Force            bool            `json:"force,omitempty"`
+	RetainUntil      *time.Time      `json:"retain_until,omitempty"`
+	Pinned           bool            `json:"pinned,omitempty"`
+	Kind             AnalysisKind    `json:"kind,omitempty"`
+	Params           json.RawMessage `json:"params,omitempty"`
+	Owner            string          `json:"owner,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+
+	lock sync.Mutex
+	// offsetLock guards Offset and UpdatedAt, which are read while a chunk is being written under lock
+	offsetLock sync.Mutex
+}
+
+// Complete reports whether all the bytes of the event log have been received.
+func (u *Upload) Complete() bool {
+	return u.CurrentOffset() == u.Length
+}
+
+// CurrentOffset returns the number of bytes received so far.
+func (u *Upload) CurrentOffset() int64 {
+	u.offsetLock.Lock()
+	defer u.offsetLock.Unlock()
+
+	return u.Offset
+}
+
+// Advance adds n received bytes to the offset.
+func (u *Upload) Advance(n int64, t time.Time) {
+	u.offsetLock.Lock()
+	defer u.offsetLock.Unlock()
+
+	u.Offset += n
+	u.UpdatedAt = t
+}
+
+// Copy returns a copy of the upload with its own pointed-to values, e.g., to encode it while a chunk is being written.
+func (u *Upload) Copy() *Upload {
+	u.offsetLock.Lock()
+	defer u.offsetLock.Unlock()
+
+	return &Upload{
+		ID:               u.ID,
+		Offset:           u.Offset,
+		Length:           u.Length,
+		FileName:         u.FileName,
+		CallbackEndpoint: u.CallbackEndpoint,
+		ColumnMapping:    u.ColumnMapping.Copy(),
+		EventLogFormat:   u.EventLogFormat,
+		OCELObjectType:   u.OCELObjectType,
+		AutoMap:          u.AutoMap,
+		Preprocessing:    u.Preprocessing.Copy(),
+		Timezone:         u.Timezone,
+		Force:            u.Force,
+		RetainUntil:      copyTime(u.RetainUntil),
+		Pinned:           u.Pinned,
+		Kind:             u.Kind,
+		Params:           copyBytes(u.Params),
+		Owner:            u.Owner,
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
+	}
+}
+
+// TryLock locks the upload for writing and reports whether it succeeded. Only one chunk can be written at a time.
+func (u *Upload) TryLock() bool {
+	return u.lock.TryLock()
+}
+
+func (u *Upload) Unlock() {
+	u.lock.Unlock()
+}
@@ -40,3 +40,16 @@ func (u *URL) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(u.URL.String())
 }
+
+// Copy returns a copy of the URL.
+func (u *URL) Copy() *URL {
+	if u == nil {
+		return nil
+	}
+	if u.URL == nil {
+		return &URL{}
+	}
+
+	c := *u.URL
+	return &URL{URL: &c}
+}
@@ -0,0 +1,21 @@
+package model
+
+// Usage is the storage and the queue used by an owner with the limits which apply to it. Zero limits aren't enforced.
+//
+// swagger:model
+type Usage struct {
+	Owner        string `json:"owner"`
+	Jobs         int    `json:"jobs"`
+	PendingJobs  int    `json:"pending_jobs"`
+	Uploads      int    `json:"uploads"`
+	StorageBytes int64  `json:"storage_bytes"`
+
+	StorageQuota    int64 `json:"storage_quota,omitempty"`
+	MaxPendingJobs  int   `json:"max_pending_jobs,omitempty"`
+	MaxUploads      int   `json:"max_uploads,omitempty"`
+	MaxEventLogSize int64 `json:"max_event_log_size,omitempty"`
+
+	// DiskFreeBytes is the space left for all owners, submissions are rejected when it falls to MinFreeDiskSpace.
+	DiskFreeBytes    int64 `json:"disk_free_bytes"`
+	MinFreeDiskSpace int64 `json:"min_free_disk_space,omitempty"`
+}
@@ -0,0 +1,73 @@
+package model
+
+// WaitingTimes are waiting times in seconds by cause.
+//
+// swagger:model
+type WaitingTimes struct {
+	TotalWt          float64 `json:"total_wt"`
+	BatchingWt       float64 `json:"batching_wt"`
+	PrioritizationWt float64 `json:"prioritization_wt"`
+	ContentionWt     float64 `json:"contention_wt"`
+	UnavailabilityWt float64 `json:"unavailability_wt"`
+	ExtraneousWt     float64 `json:"extraneous_wt"`
+}
+
+// JobOverview summarizes the waiting times of a job's process. The averages are per transition instance, i.e., per
+// waiting between two activities in a case.
+//
+// swagger:model
+type JobOverview struct {
+	NumCases               int `json:"num_cases"`
+	NumActivities          int `json:"num_activities"`
+	NumTransitions         int `json:"num_transitions"`
+	NumTransitionInstances int `json:"num_transition_instances"`
+	// ProcessingTime is the total duration of the activities in seconds, it's omitted if it isn't known.
+	ProcessingTime float64       `json:"processing_time,omitempty"`
+	Totals         *WaitingTimes `json:"totals"`
+	Averages       *WaitingTimes `json:"averages"`
+}
+
+// WaitingTimeBreakdown breaks the waiting time of a cause, or the total waiting time, down by transition, resource
+// and case. It covers the whole process or the transitions between the given activities. The groups are ordered by the
+// waiting time of the cause, the largest first.
+//
+// swagger:model
+type WaitingTimeBreakdown struct {
+	Cause          string `json:"cause"`
+	SourceActivity string `json:"source_activity,omitempty"`
+	TargetActivity string `json:"target_activity,omitempty"`
+	// NumCases are the cases with the selected transitions and NumCasesAffected are those which waited for the cause.
+	NumCases               int `json:"num_cases"`
+	NumCasesAffected       int `json:"num_cases_affected"`
+	NumTransitionInstances int `json:"num_transition_instances"`
+	// Wt and AvgWt are the waiting time of the cause in the selected transitions, ProcessWt and ProcessAvgWt in the
+	// whole process. The averages are per transition instance.
+	Wt           float64 `json:"wt"`
+	AvgWt        float64 `json:"avg_wt"`
+	ProcessWt    float64 `json:"process_wt"`
+	ProcessAvgWt float64 `json:"process_avg_wt"`
+	// Totals are the waiting times of all causes in the selected transitions.
+	Totals        *WaitingTimes       `json:"totals"`
+	Transitions   []*WaitingTimeGroup `json:"transitions"`
+	ResourcePairs []*WaitingTimeGroup `json:"resource_pairs"`
+	// Resources are grouped by the resource of the target activity, which the cases wait for.
+	Resources []*WaitingTimeGroup `json:"resources"`
+	Cases     []*WaitingTimeGroup `json:"cases"`
+}
+
+// WaitingTimeGroup is the waiting time of the transition instances with the same activities, resources or case.
+// Only the fields it's grouped by are set.
+//
+// swagger:model
+type WaitingTimeGroup struct {
+	SourceActivity string `json:"source_activity,omitempty"`
+	TargetActivity string `json:"target_activity,omitempty"`
+	SourceResource string `json:"source_resource,omitempty"`
+	TargetResource string `json:"target_resource,omitempty"`
+	CaseID         string `json:"case_id,omitempty"`
+	Count          int    `json:"count"`
+	// Wt and AvgWt are the waiting time of the selected cause, Totals are the waiting times of all causes.
+	Wt     float64       `json:"wt"`
+	AvgWt  float64       `json:"avg_wt"`
+	Totals *WaitingTimes `json:"totals"`
+}
@@ -1,4 +1,4 @@
 #!/usr/bin/env bash
 
 cd /usr/src/app
-poetry run wta --log_path "$1" --output_dir "$2" --columns_json "$3"
+poetry run wta --log_path "$1" --output_dir "$2" --columns_json "$(cat "$3")"
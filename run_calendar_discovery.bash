@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+
+script_dir="$(cd "$(dirname "$0")" && pwd)"
+
+cd /usr/src/app
+poetry run python "$script_dir/discovery.py" calendars --log_path "$1" --output_path "$2" --parameters_json "$3" --columns_path "$4"